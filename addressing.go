@@ -19,8 +19,43 @@ const (
 	ZeroPage
 	ZeroPageX
 	ZeroPageY
+
+	// 65C02 addressing modes
+	ZeroPageIndirect
+	AbsoluteIndexedIndirect
+	ZeroPageRelative
+
+	// 65C816 addressing modes
+	AbsoluteLong
+	AbsoluteLongX
+	AbsoluteIndirectLong
+	DirectIndirectLong
+	DirectIndirectLongY
+	StackRelative
+	StackRelativeIndirectY
+	RelativeLong
+	BlockMove
 )
 
+// operandSizes holds how many bytes follow the opcode in each 6502 and 65C02
+// addressing mode
+var operandSizes = map[AddressingMode]int{
+	Immediate:               1,
+	Relative:                1,
+	IndexedIndirect:         1,
+	IndirectIndexed:         1,
+	ZeroPage:                1,
+	ZeroPageX:               1,
+	ZeroPageY:               1,
+	ZeroPageIndirect:        1,
+	Indirect:                2,
+	Absolute:                2,
+	AbsoluteX:               2,
+	AbsoluteY:               2,
+	AbsoluteIndexedIndirect: 2,
+	ZeroPageRelative:        2,
+}
+
 // AddressingModeNames is a map of addressing mode names
 var AddressingModeNames = map[AddressingMode]string{
 	Accumulator:     "Accumulator",
@@ -36,6 +71,20 @@ var AddressingModeNames = map[AddressingMode]string{
 	ZeroPage:        "ZeroPage",
 	ZeroPageX:       "ZeroPageX",
 	ZeroPageY:       "ZeroPageY",
+
+	ZeroPageIndirect:        "ZeroPageIndirect",
+	AbsoluteIndexedIndirect: "AbsoluteIndexedIndirect",
+	ZeroPageRelative:        "ZeroPageRelative",
+
+	AbsoluteLong:           "AbsoluteLong",
+	AbsoluteLongX:          "AbsoluteLongX",
+	AbsoluteIndirectLong:   "AbsoluteIndirectLong",
+	DirectIndirectLong:     "DirectIndirectLong",
+	DirectIndirectLongY:    "DirectIndirectLongY",
+	StackRelative:          "StackRelative",
+	StackRelativeIndirectY: "StackRelativeIndirectY",
+	RelativeLong:           "RelativeLong",
+	BlockMove:              "BlockMove",
 }
 
 // --- Addressing modes ---
@@ -83,8 +132,8 @@ func (c *CPU) indirect() uint8 {
 	c.i.temp = (uint16(c.bus.Read(c.r.pc+1)) << 8) | uint16(c.bus.Read(c.r.pc))
 
 	// Get the indirect address
-	if c.i.temp&0x00FF == 0x00FF {
-		// Simulate page boundary bug
+	if c.i.temp&0x00FF == 0x00FF && !c.variant.cmos() {
+		// Simulate the NMOS page boundary bug, which the 65C02 fixed
 		c.i.addr_absolute = uint16(c.bus.Read(c.i.temp&0xFF00))<<8 | uint16(c.bus.Read(c.i.temp))
 	} else {
 		// Proceed as normal
@@ -99,14 +148,14 @@ func (c *CPU) indirect() uint8 {
 // No parameters.
 // Returns a uint8.
 func (c *CPU) relative() uint8 {
-	// Get the relative address
+	// Get the relative address, sign extended
 	c.i.temp = uint16(int8(c.bus.Read(c.r.pc)))
 
 	// Store the address
 	c.i.addr_relative = c.i.temp
 
 	// Increment the program counter
-	c.r.pc += 2
+	c.r.pc++
 
 	return 0
 }
@@ -116,13 +165,17 @@ func (c *CPU) relative() uint8 {
 // No parameters.
 // Return type uint8.
 func (c *CPU) indexedIndirect() uint8 {
-	// Get the address indexed by the X register
-	c.i.temp = uint16(c.bus.Read(c.r.pc))
-	c.i.addr_absolute = uint16((c.i.temp + uint16(c.r.x)&0xFF))
+	// Get the zero page pointer and add the X register, wrapping if necessary
+	c.i.temp = uint16(c.bus.Read(c.r.pc)+c.r.x) & 0x00FF
 
 	// Increment the program counter
 	c.r.pc++
 
+	// Read the address from the pointer, which wraps within the zero page
+	var low uint16 = uint16(c.bus.Read(c.i.temp))
+	var high uint16 = uint16(c.bus.Read((c.i.temp + 1) & 0x00FF))
+	c.i.addr_absolute = high<<8 | low
+
 	return 0
 }
 
@@ -131,13 +184,20 @@ func (c *CPU) indexedIndirect() uint8 {
 // No parameters.
 // Returns uint8.
 func (c *CPU) indirectIndexed() uint8 {
-	// Get the address indexed by the Y register
+	// Get the zero page pointer
 	c.i.temp = uint16(c.bus.Read(c.r.pc))
-	c.i.addr_absolute = uint16((c.i.temp + uint16(c.r.y)&0xFF))
 
 	// Increment the program counter
 	c.r.pc++
 
+	// Read the address from the pointer, which wraps within the zero page
+	var low uint16 = uint16(c.bus.Read(c.i.temp))
+	var high uint16 = uint16(c.bus.Read((c.i.temp + 1) & 0x00FF))
+	c.i.temp = high<<8 | low
+
+	// Add the Y register
+	c.i.addr_absolute = c.i.temp + uint16(c.r.y)
+
 	// Check if the page boundary was crossed, if so add another cycle
 	if c.i.addr_absolute&0xFF00 != c.i.temp&0xFF00 {
 		return 1
@@ -243,3 +303,59 @@ func (c *CPU) zeroPageY() uint8 {
 
 	return 0
 }
+
+// zeroPageIndirect gets the address from a pointer in the zero page (65C02).
+//
+// No parameters.
+// Returns uint8.
+func (c *CPU) zeroPageIndirect() uint8 {
+	// Get the zero page pointer
+	c.i.temp = uint16(c.bus.Read(c.r.pc))
+
+	// Increment the program counter
+	c.r.pc++
+
+	// Read the address from the pointer, which wraps within the zero page
+	var low uint16 = uint16(c.bus.Read(c.i.temp))
+	var high uint16 = uint16(c.bus.Read((c.i.temp + 1) & 0x00FF))
+	c.i.addr_absolute = high<<8 | low
+
+	return 0
+}
+
+// absoluteIndexedIndirect gets the address from a pointer at the absolute
+// address plus the X register (65C02 JMP only).
+//
+// No parameters.
+// Returns uint8.
+func (c *CPU) absoluteIndexedIndirect() uint8 {
+	// Get the absolute address and add the X register
+	c.i.temp = (uint16(c.bus.Read(c.r.pc+1)) << 8) | uint16(c.bus.Read(c.r.pc))
+	c.i.temp += uint16(c.r.x)
+
+	// Increment the program counter
+	c.r.pc += 2
+
+	// Read the address from the pointer
+	c.i.addr_absolute = uint16(c.bus.Read(c.i.temp+1))<<8 | uint16(c.bus.Read(c.i.temp))
+
+	return 0
+}
+
+// zeroPageRelative gets a zero page address followed by a relative address
+// (65C02 BBR and BBS only).
+//
+// No parameters.
+// Returns uint8.
+func (c *CPU) zeroPageRelative() uint8 {
+	// Get the zero page address
+	c.i.addr_absolute = uint16(c.bus.Read(c.r.pc))
+
+	// Get the relative address, sign extended
+	c.i.addr_relative = uint16(int8(c.bus.Read(c.r.pc + 1)))
+
+	// Increment the program counter
+	c.r.pc += 2
+
+	return 0
+}
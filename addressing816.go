@@ -0,0 +1,278 @@
+package goemu6502
+
+// --- 65C816 addressing modes ---
+// Like the 6502 ones, these calculate the effective address of an instruction
+// and return 1 if indexing may cost an extra cycle. On the 65C816 that is the
+// case when indexing crosses a page boundary or the index registers are 16
+// bits wide. A direct page that doesn't start on a page boundary costs an
+// extra cycle too, which is added to the instruction directly.
+
+func (c *CPU816) executeAddressingMode(mode AddressingMode) uint8 {
+	// Data lives in the data bank unless the addressing mode says otherwise
+	c.i.wrap_bank = false
+
+	switch mode {
+	case Immediate:
+		return c.immediate()
+	case ZeroPage:
+		return c.direct()
+	case ZeroPageX:
+		return c.directIndexed(c.r.x)
+	case ZeroPageY:
+		return c.directIndexed(c.r.y)
+	case ZeroPageIndirect:
+		return c.directIndirect()
+	case IndexedIndirect:
+		return c.directIndexedIndirect()
+	case IndirectIndexed:
+		return c.directIndirectIndexed()
+	case DirectIndirectLong:
+		return c.directIndirectLong(0)
+	case DirectIndirectLongY:
+		return c.directIndirectLong(c.r.y)
+	case Absolute:
+		return c.absolute()
+	case AbsoluteX:
+		return c.absoluteIndexed(c.r.x)
+	case AbsoluteY:
+		return c.absoluteIndexed(c.r.y)
+	case AbsoluteLong:
+		return c.absoluteLong(0)
+	case AbsoluteLongX:
+		return c.absoluteLong(c.r.x)
+	case Indirect:
+		return c.absoluteIndirect()
+	case AbsoluteIndexedIndirect:
+		return c.absoluteIndexedIndirect()
+	case AbsoluteIndirectLong:
+		return c.absoluteIndirectLong()
+	case StackRelative:
+		return c.stackRelative()
+	case StackRelativeIndirectY:
+		return c.stackRelativeIndirectIndexed()
+	case Relative:
+		return c.relative()
+	case RelativeLong:
+		return c.relativeLong()
+	case BlockMove:
+		return c.blockMove()
+	default:
+		return 0
+	}
+}
+
+// immediateWide reports whether an instruction's immediate operand is 16
+// bits wide, which depends on the register it works on
+func (c *CPU816) immediateWide(instruction Instruction) bool {
+	switch instruction {
+	case brk, cop, rep, sep, wdm:
+		return false
+	case cpx, cpy, ldx, ldy:
+		return c.x16()
+	default:
+		return c.m16()
+	}
+}
+
+// dataAddress puts a 16-bit address in the data bank
+func (c *CPU816) dataAddress(addr uint16) uint32 {
+	return uint32(c.r.dbr)<<16 | uint32(addr)
+}
+
+// directAddress returns the bank 0 address of offset into the direct page.
+// In emulation mode, a direct page on a page boundary wraps like the 6502's
+// zero page.
+func (c *CPU816) directAddress(offset uint16) uint32 {
+	if c.r.e && c.r.d&0x00FF == 0 {
+		return uint32(c.r.d | offset&0x00FF)
+	}
+	return uint32(c.r.d + offset)
+}
+
+// directOperand fetches a direct page offset, charging the extra cycle for a
+// direct page that doesn't start on a page boundary
+func (c *CPU816) directOperand() uint16 {
+	if c.r.d&0x00FF != 0 {
+		c.status.Cycles++
+	}
+	return uint16(c.fetch())
+}
+
+// readPointer reads a 16-bit pointer from the direct page
+func (c *CPU816) readPointer(addr uint32) uint16 {
+	var low uint16 = uint16(c.read(addr))
+	var high uint16 = uint16(c.read(c.directAddress(uint16(addr) - c.r.d + 1)))
+	return high<<8 | low
+}
+
+// indexed adds an index to a 24-bit base address and reports whether that
+// costs an extra cycle
+func (c *CPU816) indexed(base uint32, index uint16) uint8 {
+	c.i.addr_absolute = (base + uint32(index)) & 0xFFFFFF
+
+	if c.x16() || c.i.addr_absolute&0xFFFF00 != base&0xFFFF00 {
+		return 1
+	}
+	return 0
+}
+
+// immediate points at the operand in the instruction stream
+func (c *CPU816) immediate() uint8 {
+	c.i.addr_absolute = uint32(c.r.pbr)<<16 | uint32(c.r.pc)
+
+	// Advance the program counter past the operand
+	c.r.pc++
+	if c.immediateWide(c.status.currentInstruction.Instruction) {
+		c.r.pc++
+	}
+
+	return 0
+}
+
+// direct gets an address in the direct page
+func (c *CPU816) direct() uint8 {
+	c.i.addr_absolute = c.directAddress(c.directOperand())
+	c.i.wrap_bank = true
+
+	return 0
+}
+
+// directIndexed gets an address in the direct page plus an index
+func (c *CPU816) directIndexed(index uint16) uint8 {
+	c.i.addr_absolute = c.directAddress(c.directOperand() + index)
+	c.i.wrap_bank = true
+
+	return 0
+}
+
+// directIndirect gets the address from a pointer in the direct page
+func (c *CPU816) directIndirect() uint8 {
+	var pointer uint32 = c.directAddress(c.directOperand())
+	c.i.addr_absolute = c.dataAddress(c.readPointer(pointer))
+
+	return 0
+}
+
+// directIndexedIndirect gets the address from a pointer in the direct page,
+// indexed by X
+func (c *CPU816) directIndexedIndirect() uint8 {
+	var pointer uint32 = c.directAddress(c.directOperand() + c.r.x)
+	c.i.addr_absolute = c.dataAddress(c.readPointer(pointer))
+
+	return 0
+}
+
+// directIndirectIndexed gets the address from a pointer in the direct page,
+// then adds Y
+func (c *CPU816) directIndirectIndexed() uint8 {
+	var pointer uint32 = c.directAddress(c.directOperand())
+	return c.indexed(c.dataAddress(c.readPointer(pointer)), c.r.y)
+}
+
+// directIndirectLong gets a 24-bit address from a pointer in the direct
+// page, then adds index
+func (c *CPU816) directIndirectLong(index uint16) uint8 {
+	var pointer uint32 = c.directAddress(c.directOperand())
+
+	var addr uint32 = uint32(c.readPointer(pointer))
+	addr |= uint32(c.read(c.directAddress(uint16(pointer)-c.r.d+2))) << 16
+
+	c.i.addr_absolute = (addr + uint32(index)) & 0xFFFFFF
+
+	return 0
+}
+
+// absolute gets an address in the data bank. Jumps use only the low 16 bits,
+// so they stay in the program bank.
+func (c *CPU816) absolute() uint8 {
+	c.i.addr_absolute = c.dataAddress(c.fetchWord())
+
+	return 0
+}
+
+// absoluteIndexed gets an address in the data bank plus an index, which may
+// carry into the next bank
+func (c *CPU816) absoluteIndexed(index uint16) uint8 {
+	return c.indexed(c.dataAddress(c.fetchWord()), index)
+}
+
+// absoluteLong gets a 24-bit address plus an index
+func (c *CPU816) absoluteLong(index uint16) uint8 {
+	var addr uint32 = uint32(c.fetchWord())
+	addr |= uint32(c.fetch()) << 16
+
+	c.i.addr_absolute = (addr + uint32(index)) & 0xFFFFFF
+
+	return 0
+}
+
+// absoluteIndirect gets the address from a pointer in bank 0 (JMP only)
+func (c *CPU816) absoluteIndirect() uint8 {
+	var pointer uint16 = c.fetchWord()
+	c.i.addr_absolute = uint32(c.r.pbr)<<16 | uint32(c.readWord(uint32(pointer)))
+
+	return 0
+}
+
+// absoluteIndexedIndirect gets the address from a pointer in the program
+// bank, indexed by X (JMP and JSR only)
+func (c *CPU816) absoluteIndexedIndirect() uint8 {
+	var pointer uint16 = c.fetchWord() + c.r.x
+	var bank uint32 = uint32(c.r.pbr) << 16
+
+	var low uint16 = uint16(c.read(bank | uint32(pointer)))
+	var high uint16 = uint16(c.read(bank | uint32(pointer+1)))
+	c.i.addr_absolute = bank | uint32(high<<8|low)
+
+	return 0
+}
+
+// absoluteIndirectLong gets a 24-bit address from a pointer in bank 0 (JML
+// only)
+func (c *CPU816) absoluteIndirectLong() uint8 {
+	var pointer uint16 = c.fetchWord()
+	c.i.addr_absolute = uint32(c.readWord(uint32(pointer))) | uint32(c.read(uint32(pointer+2)))<<16
+
+	return 0
+}
+
+// stackRelative gets an address relative to the stack pointer
+func (c *CPU816) stackRelative() uint8 {
+	c.i.addr_absolute = uint32(c.r.sp + uint16(c.fetch()))
+	c.i.wrap_bank = true
+
+	return 0
+}
+
+// stackRelativeIndirectIndexed gets the address from a pointer relative to
+// the stack pointer, then adds Y
+func (c *CPU816) stackRelativeIndirectIndexed() uint8 {
+	var pointer uint16 = c.r.sp + uint16(c.fetch())
+	var base uint32 = c.dataAddress(c.readWord(uint32(pointer)))
+
+	c.i.addr_absolute = (base + uint32(c.r.y)) & 0xFFFFFF
+
+	return 0
+}
+
+// relative gets an 8-bit branch offset, sign extended
+func (c *CPU816) relative() uint8 {
+	c.i.addr_relative = uint16(int8(c.fetch()))
+
+	return 0
+}
+
+// relativeLong gets a 16-bit branch offset
+func (c *CPU816) relativeLong() uint8 {
+	c.i.addr_relative = c.fetchWord()
+
+	return 0
+}
+
+// blockMove gets the destination and source banks of MVN and MVP, in that
+// order, into temp
+func (c *CPU816) blockMove() uint8 {
+	c.i.temp = c.fetchWord()
+
+	return 0
+}
@@ -0,0 +1,83 @@
+package goemu6502
+
+import "testing"
+
+func TestRelative(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		program []uint8
+		z       bool
+		pc      uint16
+		cycles  int
+	}{
+		{name: "not taken", program: []uint8{0xD0, 0x10}, z: true, pc: 0x0402, cycles: 2},
+		{name: "forward", program: []uint8{0xD0, 0x10}, pc: 0x0412, cycles: 3},
+		{name: "backward", program: []uint8{0xD0, 0xFC}, pc: 0x03FE, cycles: 4},
+		{name: "to itself", program: []uint8{0xD0, 0xFE}, pc: 0x0400, cycles: 3},
+	} {
+		// BNE
+		c, _ := newTestCPU(tt.program...)
+		c.SetFlag(Zero, tt.z)
+
+		cycles := c.Step()
+		if c.PC() != tt.pc || cycles != tt.cycles {
+			t.Errorf("%s: PC = $%04X after %d cycles, want $%04X after %d", tt.name, c.PC(), cycles, tt.pc, tt.cycles)
+		}
+	}
+}
+
+func TestIndirectPointers(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		program []uint8
+		x, y    uint8
+		pointer uint8  // Where the pointer is read from
+		address uint16 // Where it points
+	}{
+		{name: "(zp,X)", program: []uint8{0xA1, 0x10}, x: 0x04, pointer: 0x14, address: 0x1234},
+		{name: "(zp,X) index wrapping", program: []uint8{0xA1, 0xF0}, x: 0x24, pointer: 0x14, address: 0x1234},
+		{name: "(zp,X) pointer wrapping", program: []uint8{0xA1, 0xFB}, x: 0x04, pointer: 0xFF, address: 0x1234},
+		{name: "(zp),Y", program: []uint8{0xB1, 0x14}, y: 0x01, pointer: 0x14, address: 0x1233},
+		{name: "(zp),Y pointer wrapping", program: []uint8{0xB1, 0xFF}, y: 0x01, pointer: 0xFF, address: 0x1233},
+	} {
+		c, bus := newTestCPU(tt.program...)
+		c.SetX(tt.x)
+		c.SetY(tt.y)
+
+		bus.memory[tt.pointer] = uint8(tt.address)
+		bus.memory[uint8(tt.pointer+1)] = uint8(tt.address >> 8)
+		bus.memory[0x1233], bus.memory[0x1234] = 0x11, 0x42
+
+		c.Step()
+		if c.A() != 0x42 {
+			t.Errorf("%s: loaded $%02X, want $42 from $1234", tt.name, c.A())
+		}
+	}
+}
+
+func TestPageCrossingCycles(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		program []uint8
+		cycles  int
+	}{
+		{name: "LDA abs,X", program: []uint8{0xBD, 0x00, 0x12}, cycles: 4},
+		{name: "LDA abs,X crossing", program: []uint8{0xBD, 0xFF, 0x12}, cycles: 5},
+		{name: "STA abs,X", program: []uint8{0x9D, 0x00, 0x12}, cycles: 5},
+		{name: "STA abs,X crossing", program: []uint8{0x9D, 0xFF, 0x12}, cycles: 5},
+		{name: "INC abs,X crossing", program: []uint8{0xFE, 0xFF, 0x12}, cycles: 7},
+		{name: "LDA (zp),Y", program: []uint8{0xB1, 0x10}, cycles: 5},
+		{name: "LDA (zp),Y crossing", program: []uint8{0xB1, 0x12}, cycles: 6},
+		{name: "STA (zp),Y crossing", program: []uint8{0x91, 0x12}, cycles: 6},
+	} {
+		c, bus := newTestCPU(tt.program...)
+		c.SetX(0x01)
+		c.SetY(0x01)
+		bus.memory[0x10], bus.memory[0x11] = 0x00, 0x12
+		bus.memory[0x12], bus.memory[0x13] = 0xFF, 0x12
+
+		if cycles := c.Step(); cycles != tt.cycles {
+			t.Errorf("%s took %d cycles, want %d", tt.name, cycles, tt.cycles)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package goemu6502
+
+import "testing"
+
+// benchmarkProgram adds 1 to every byte of page 2 forever:
+//
+//	0400: LDX #$00
+//	0402: LDA $0200,X
+//	0405: CLC
+//	0406: ADC #$01
+//	0408: STA $0200,X
+//	040B: INX
+//	040C: BNE $0402
+//	040E: JMP $0400
+var benchmarkProgram = []uint8{
+	0xA2, 0x00, 0xBD, 0x00, 0x02, 0x18, 0x69, 0x01, 0x9D, 0x00, 0x02, 0xE8, 0xD0, 0xF4, 0x4C, 0x00, 0x04,
+}
+
+// benchmarkCPU creates a CPU running benchmarkProgram
+func benchmarkCPU(options ...Option) *CPU {
+	bus := &flatBus{}
+	copy(bus.memory[0x0400:], benchmarkProgram)
+
+	c := NewCPU(bus, options...)
+	c.SetPC(0x0400)
+	return c
+}
+
+// reportMHz reports the emulated clock speed
+func reportMHz(b *testing.B, cycles int) {
+	b.ReportMetric(float64(cycles)/b.Elapsed().Seconds()/1e6, "MHz")
+}
+
+// benchmarkRun runs a frame's worth of cycles per iteration
+func benchmarkRun(b *testing.B, options ...Option) {
+	const frame = 29780 // NTSC NES cycles per frame
+
+	c := benchmarkCPU(options...)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	cycles, overshoot := 0, 0
+	for i := 0; i < b.N; i++ {
+		cycles += frame - overshoot
+		overshoot = c.Run(frame - overshoot)
+		cycles += overshoot
+	}
+	reportMHz(b, cycles)
+}
+
+func BenchmarkRun(b *testing.B) {
+	benchmarkRun(b)
+}
+
+func BenchmarkRunCycleExact(b *testing.B) {
+	benchmarkRun(b, WithCycleExact())
+}
+
+func BenchmarkRun65C02(b *testing.B) {
+	benchmarkRun(b, WithVariant(WDC65C02))
+}
+
+// BenchmarkTick measures the cost of driving the CPU one cycle at a time, as
+// machines that interleave devices with the CPU do
+func BenchmarkTick(b *testing.B) {
+	c := benchmarkCPU()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		c.Tick()
+	}
+	reportMHz(b, b.N)
+}
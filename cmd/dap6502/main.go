@@ -0,0 +1,35 @@
+// Command dap6502 is a Debug Adapter Protocol server for 6502 programs, so
+// that they can be debugged from an editor. See package dap for the launch
+// configuration it takes.
+//
+// Usage:
+//
+//	dap6502 [-listen addr]
+//
+// It talks over standard input and output, which is how editors start debug
+// adapters, or listens for connections on a TCP address such as :4711.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/drewwalton19216801/goemu6502/dap"
+)
+
+func main() {
+	listen := flag.String("listen", "", "listen for connections on a TCP `addr` instead of using standard input and output")
+	flag.Parse()
+
+	var err error
+	if *listen != "" {
+		err = dap.ListenAndServe(*listen)
+	} else {
+		err = dap.ServeStdio()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dap6502:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/drewwalton19216801/goemu6502"
+)
+
+// operand is a number written in an instruction's operand
+type operand struct {
+	value uint16
+	short bool // Written with at most two hex digits, so it can be zero page
+}
+
+// assemble encodes one line of assembly, e.g. "lda ($10),y", as it would be
+// at addr on cpu's variant. Numbers are hex, with or without a $.
+func assemble(cpu *goemu6502.CPU, addr uint16, line string) ([]uint8, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("nothing to assemble")
+	}
+	mnemonic := strings.ToLower(fields[0])
+	text := strings.ToUpper(strings.Join(fields[1:], ""))
+
+	// Every addressing mode of the mnemonic, preferring documented opcodes
+	modes := map[goemu6502.AddressingMode]uint8{}
+	for opcode := 0xFF; opcode >= 0; opcode-- {
+		info := cpu.Decode(uint8(opcode))
+		if info.Name() != mnemonic {
+			continue
+		}
+		if existing, ok := modes[info.Mode]; !ok || !cpu.Decode(existing).Documented() || info.Documented() {
+			modes[info.Mode] = uint8(opcode)
+		}
+	}
+	if len(modes) == 0 {
+		return nil, fmt.Errorf("unknown instruction %q", fields[0])
+	}
+
+	// pick returns the first mode the mnemonic has
+	pick := func(candidates ...goemu6502.AddressingMode) (goemu6502.AddressingMode, bool) {
+		for _, mode := range candidates {
+			if _, ok := modes[mode]; ok {
+				return mode, true
+			}
+		}
+		return 0, false
+	}
+
+	_, accumulator := modes[goemu6502.Accumulator]
+
+	var (
+		mode     goemu6502.AddressingMode
+		ok       bool
+		operands []operand
+		err      error
+	)
+
+	switch {
+	case text == "":
+		mode, ok = pick(goemu6502.Implied, goemu6502.Accumulator)
+
+	case text == "A" && accumulator:
+		mode, ok = goemu6502.Accumulator, true
+
+	case strings.HasPrefix(text, "#"):
+		operands, err = parseOperands(text[1:])
+		mode, ok = pick(goemu6502.Immediate)
+
+	case strings.HasPrefix(text, "(") && strings.HasSuffix(text, ",X)"):
+		operands, err = parseOperands(text[1 : len(text)-3])
+		if len(operands) == 1 && operands[0].short {
+			mode, ok = pick(goemu6502.IndexedIndirect, goemu6502.AbsoluteIndexedIndirect)
+		} else {
+			mode, ok = pick(goemu6502.AbsoluteIndexedIndirect)
+		}
+
+	case strings.HasPrefix(text, "(") && strings.HasSuffix(text, "),Y"):
+		operands, err = parseOperands(text[1 : len(text)-3])
+		mode, ok = pick(goemu6502.IndirectIndexed)
+
+	case strings.HasPrefix(text, "(") && strings.HasSuffix(text, ")"):
+		operands, err = parseOperands(text[1 : len(text)-1])
+		if len(operands) == 1 && operands[0].short {
+			mode, ok = pick(goemu6502.ZeroPageIndirect, goemu6502.Indirect)
+		} else {
+			mode, ok = pick(goemu6502.Indirect)
+		}
+
+	case strings.HasSuffix(text, ",X"), strings.HasSuffix(text, ",Y"):
+		zeroPage, absolute := goemu6502.ZeroPageX, goemu6502.AbsoluteX
+		if strings.HasSuffix(text, ",Y") {
+			zeroPage, absolute = goemu6502.ZeroPageY, goemu6502.AbsoluteY
+		}
+		operands, err = parseOperands(text[:len(text)-2])
+		if len(operands) == 1 && operands[0].short {
+			mode, ok = pick(zeroPage, absolute)
+		} else {
+			mode, ok = pick(absolute)
+		}
+
+	default:
+		operands, err = parseOperands(text)
+		switch {
+		case len(operands) == 2:
+			mode, ok = pick(goemu6502.ZeroPageRelative)
+		case len(operands) == 1 && operands[0].short:
+			mode, ok = pick(goemu6502.Relative, goemu6502.ZeroPage, goemu6502.Absolute)
+		default:
+			mode, ok = pick(goemu6502.Relative, goemu6502.Absolute)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s has no such addressing mode", mnemonic)
+	}
+
+	return encode(modes[mode], mode, addr, operands)
+}
+
+// encode lays out an instruction in memory
+func encode(opcode uint8, mode goemu6502.AddressingMode, addr uint16, operands []operand) ([]uint8, error) {
+	want := 1
+	switch mode {
+	case goemu6502.Implied, goemu6502.Accumulator:
+		want = 0
+	case goemu6502.ZeroPageRelative:
+		want = 2
+	}
+	if len(operands) != want {
+		return nil, fmt.Errorf("expected %d operands, got %d", want, len(operands))
+	}
+
+	switch mode {
+	case goemu6502.Implied, goemu6502.Accumulator:
+		return []uint8{opcode}, nil
+
+	case goemu6502.Relative:
+		offset, err := branchOffset(addr+2, operands[0].value)
+		return []uint8{opcode, offset}, err
+
+	case goemu6502.ZeroPageRelative:
+		if operands[0].value > 0xFF {
+			return nil, fmt.Errorf("$%04X is not in zero page", operands[0].value)
+		}
+		offset, err := branchOffset(addr+3, operands[1].value)
+		return []uint8{opcode, uint8(operands[0].value), offset}, err
+
+	case goemu6502.Absolute, goemu6502.AbsoluteX, goemu6502.AbsoluteY,
+		goemu6502.Indirect, goemu6502.AbsoluteIndexedIndirect:
+		value := operands[0].value
+		return []uint8{opcode, uint8(value), uint8(value >> 8)}, nil
+
+	default:
+		if operands[0].value > 0xFF {
+			return nil, fmt.Errorf("$%04X doesn't fit in a byte", operands[0].value)
+		}
+		return []uint8{opcode, uint8(operands[0].value)}, nil
+	}
+}
+
+// branchOffset returns the offset of a branch to target from the end of the
+// branch instruction at next
+func branchOffset(next, target uint16) (uint8, error) {
+	offset := int(int16(target - next))
+	if offset < -128 || offset > 127 {
+		return 0, fmt.Errorf("branch to $%04X is out of range", target)
+	}
+	return uint8(offset), nil
+}
+
+// parseOperands parses the comma separated numbers of an operand
+func parseOperands(text string) ([]operand, error) {
+	var operands []operand
+	for _, field := range strings.Split(text, ",") {
+		value, err := parseAddress(field)
+		if err != nil {
+			return nil, err
+		}
+		digits := strings.TrimPrefix(field, "$")
+		operands = append(operands, operand{value: value, short: len(digits) <= 2})
+	}
+	return operands, nil
+}
+
+// parseAddress parses a hex number, with or without a $
+func parseAddress(text string) (uint16, error) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(text, "$"), 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("bad number %q", text)
+	}
+	return uint16(value), nil
+}
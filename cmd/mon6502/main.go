@@ -0,0 +1,85 @@
+// Command mon6502 is a machine language monitor in the style of VICE and
+// Supermon. It runs a 6502 in 64K of RAM, loaded from files given on the
+// command line, and lets you inspect and change its memory and registers,
+// assemble and disassemble, and step and run it with breakpoints.
+//
+// Usage:
+//
+//	mon6502 [-variant 65C02] [-pc addr] [-load file@addr ...]
+//
+// Type help at the prompt for the commands.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/drewwalton19216801/goemu6502"
+)
+
+// loadFlags are the files to load before starting, as file@addr
+type loadFlags []string
+
+func (f *loadFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *loadFlags) Set(value string) error {
+	if !strings.Contains(value, "@") {
+		return fmt.Errorf("expected file@addr, got %q", value)
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+func main() {
+	var loads loadFlags
+	variantName := flag.String("variant", "6502", "CPU `variant`: 6502, 65C02, R65C02, W65C02S, 2A03 or 6510")
+	pc := flag.String("pc", "", "start at `addr`, with SP at $FD, instead of resetting through the reset vector")
+	flag.Var(&loads, "load", "load `file@addr` into memory before starting; may be repeated")
+	flag.Parse()
+
+	variant, ok := goemu6502.ParseVariant(*variantName)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "mon6502: unknown variant %q\n", *variantName)
+		os.Exit(2)
+	}
+
+	bus := &goemu6502.RAM{}
+	for _, load := range loads {
+		i := strings.LastIndex(load, "@")
+		addr, err := parseAddress(load[i+1:])
+		if err == nil {
+			_, err = bus.LoadFile(load[:i], addr)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "mon6502:", err)
+			os.Exit(1)
+		}
+	}
+
+	cpu := goemu6502.NewCPU(bus, goemu6502.WithVariant(variant))
+	if *pc != "" {
+		addr, err := parseAddress(*pc)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "mon6502:", err)
+			os.Exit(2)
+		}
+		cpu.SetPC(addr)
+		cpu.SetSP(0xFD)
+	} else {
+		// Run the whole reset sequence, leaving PC at the reset vector
+		cpu.Reset()
+		cpu.Step()
+	}
+
+	m := newMonitor(cpu, bus, os.Stdout)
+	m.interrupt = func() (context.Context, context.CancelFunc) {
+		return signal.NotifyContext(context.Background(), os.Interrupt)
+	}
+	m.run(os.Stdin)
+}
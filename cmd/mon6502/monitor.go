@@ -0,0 +1,635 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/drewwalton19216801/goemu6502"
+	"github.com/drewwalton19216801/goemu6502/debugger"
+)
+
+type (
+	// monitor is a machine language monitor for a CPU running in RAM
+	monitor struct {
+		cpu      *goemu6502.CPU
+		bus      *goemu6502.RAM
+		debugger *debugger.Debugger
+		out      io.Writer
+
+		// interrupt returns the context to run the CPU with, which is
+		// cancelled when the user wants it to stop
+		interrupt func() (context.Context, context.CancelFunc)
+
+		dot  uint16 // Where m and d carry on from
+		quit bool
+	}
+
+	// command is something the monitor can do
+	command struct {
+		usage string
+		help  string
+		run   func(m *monitor, args []string) error
+	}
+)
+
+const (
+	// dumpLines and disassembleLines are how much m and d show by default
+	dumpLines        = 8
+	disassembleLines = 16
+)
+
+// commands maps every command to what it does. It is filled in by init,
+// since help refers to it.
+var commands map[string]command
+
+// aliases are other names for commands
+var aliases = map[string]string{
+	"registers":   "r",
+	"mem":         "m",
+	"fill":        "f",
+	"compare":     "c",
+	"hunt":        "h",
+	"disassemble": "d",
+	"assemble":    "a",
+	"step":        "z",
+	"next":        "n",
+	"return":      "ret",
+	"go":          "g",
+	"bk":          "break",
+	"w":           "watch",
+	"bl":          "break",
+	"load":        "l",
+	"save":        "s",
+	"q":           "x",
+	"quit":        "x",
+	"?":           "help",
+}
+
+func init() {
+	commands = map[string]command{
+		"r":       {"r [reg=value ...]", "show or set the registers A, X, Y, P, SP and PC", (*monitor).registers},
+		"m":       {"m [start [end]]", "dump memory in hex", (*monitor).dump},
+		"f":       {"f start end byte ...", "fill memory with a pattern", (*monitor).fill},
+		"c":       {"c start end dest", "compare memory with memory at dest", (*monitor).compare},
+		"h":       {"h start end byte ...", "hunt memory for bytes", (*monitor).hunt},
+		"d":       {"d [start [end]]", "disassemble", (*monitor).disassemble},
+		"a":       {"a addr instruction", "assemble an instruction in place", (*monitor).assemble},
+		"z":       {"z [count]", "step instructions", (*monitor).step},
+		"n":       {"n", "step, running a JSR until it returns", (*monitor).next},
+		"ret":     {"ret", "run until the current subroutine returns", (*monitor).ret},
+		"g":       {"g [addr]", "go, until a breakpoint or Ctrl-C", (*monitor).goCommand},
+		"break":   {"break [addr [if condition]]", "add a breakpoint, or list them", (*monitor).breakCommand},
+		"watch":   {"watch r|w|rw start [end] [if condition]", "add a watchpoint", (*monitor).watch},
+		"del":     {"del id", "delete a breakpoint", (*monitor).deleteBreakpoint},
+		"enable":  {"enable id", "enable a breakpoint", (*monitor).enable},
+		"disable": {"disable id", "disable a breakpoint", (*monitor).disable},
+		"l":       {"l file addr", "load a file into memory", (*monitor).load},
+		"s":       {"s file start end", "save memory to a file", (*monitor).save},
+		"x":       {"x", "leave the monitor", (*monitor).exit},
+		"help":    {"help", "list the commands", (*monitor).help},
+	}
+}
+
+func newMonitor(cpu *goemu6502.CPU, bus *goemu6502.RAM, out io.Writer) *monitor {
+	return &monitor{
+		cpu:      cpu,
+		bus:      bus,
+		debugger: debugger.New(cpu),
+		out:      out,
+		interrupt: func() (context.Context, context.CancelFunc) {
+			return context.WithCancel(context.Background())
+		},
+		dot: cpu.PC(),
+	}
+}
+
+// run reads commands from in until it ends or the user quits
+func (m *monitor) run(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for !m.quit {
+		fmt.Fprintf(m.out, "(C:$%04X) ", m.cpu.PC())
+		if !scanner.Scan() {
+			fmt.Fprintln(m.out)
+			return
+		}
+		if err := m.execute(scanner.Text()); err != nil {
+			fmt.Fprintln(m.out, "error:", err)
+		}
+	}
+}
+
+// execute runs a single command line
+func (m *monitor) execute(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	name := strings.ToLower(fields[0])
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q, try help", fields[0])
+	}
+
+	return cmd.run(m, fields[1:])
+}
+
+// registers shows the registers, after setting any given as reg=value
+func (m *monitor) registers(args []string) error {
+	for _, arg := range args {
+		name, text, ok := strings.Cut(strings.ToUpper(arg), "=")
+		if !ok {
+			return fmt.Errorf("expected reg=value, got %q", arg)
+		}
+		value, err := parseAddress(text)
+		if err != nil {
+			return err
+		}
+		if name != "PC" && value > 0xFF {
+			return fmt.Errorf("$%04X doesn't fit in %s", value, name)
+		}
+
+		switch name {
+		case "A":
+			m.cpu.SetA(uint8(value))
+		case "X":
+			m.cpu.SetX(uint8(value))
+		case "Y":
+			m.cpu.SetY(uint8(value))
+		case "P":
+			m.cpu.SetP(uint8(value))
+		case "SP":
+			m.cpu.SetSP(uint8(value))
+		case "PC":
+			m.cpu.SetPC(value)
+			m.dot = value
+		default:
+			return fmt.Errorf("unknown register %q", name)
+		}
+	}
+
+	r := m.cpu.Registers()
+	fmt.Fprintln(m.out, "  ADDR A  X  Y  SP NV-BDIZC")
+	fmt.Fprintf(m.out, ".;%04X %02X %02X %02X %02X %s\n", r.PC(), r.A(), r.X(), r.Y(), r.SP(), r.Flags())
+	return nil
+}
+
+// dump shows memory in hex and ASCII, 16 bytes to a line
+func (m *monitor) dump(args []string) error {
+	start, end, err := m.addressRange(args, dumpLines*16)
+	if err != nil {
+		return err
+	}
+
+	for addr := int(start); addr <= int(end); addr += 16 {
+		var text strings.Builder
+		fmt.Fprintf(m.out, ">C:%04X ", addr)
+		for i := addr; i < addr+16; i++ {
+			if i > int(end) {
+				fmt.Fprint(m.out, "   ")
+				continue
+			}
+			value := m.bus.Peek(uint16(i))
+			fmt.Fprintf(m.out, " %02X", value)
+			if value >= 0x20 && value < 0x7F {
+				text.WriteByte(value)
+			} else {
+				text.WriteByte('.')
+			}
+		}
+		fmt.Fprintf(m.out, "  %s\n", text.String())
+	}
+
+	m.dot = end + 1
+	return nil
+}
+
+// fill repeats a pattern of bytes from start to end
+func (m *monitor) fill(args []string) error {
+	if len(args) < 3 {
+		return m.usage("f")
+	}
+	start, end, err := m.parseRange(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	pattern, err := parseBytes(args[2:])
+	if err != nil {
+		return err
+	}
+
+	for addr := int(start); addr <= int(end); addr++ {
+		m.bus.Write(uint16(addr), pattern[(addr-int(start))%len(pattern)])
+	}
+	return nil
+}
+
+// compare lists the addresses from start to end whose bytes differ from
+// those at dest
+func (m *monitor) compare(args []string) error {
+	if len(args) != 3 {
+		return m.usage("c")
+	}
+	start, end, err := m.parseRange(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	dest, err := parseAddress(args[2])
+	if err != nil {
+		return err
+	}
+
+	for addr := int(start); addr <= int(end); addr++ {
+		other := dest + uint16(addr-int(start))
+		if a, b := m.bus.Peek(uint16(addr)), m.bus.Peek(other); a != b {
+			fmt.Fprintf(m.out, "$%04X $%02X <> $%04X $%02X\n", addr, a, other, b)
+		}
+	}
+	return nil
+}
+
+// hunt lists the addresses from start to end where the bytes are found
+func (m *monitor) hunt(args []string) error {
+	if len(args) < 3 {
+		return m.usage("h")
+	}
+	start, end, err := m.parseRange(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	pattern, err := parseBytes(args[2:])
+	if err != nil {
+		return err
+	}
+
+	memory := m.bus.Memory[start : int(end)+1]
+	for i := 0; i+len(pattern) <= len(memory); i++ {
+		if bytes.Equal(memory[i:i+len(pattern)], pattern) {
+			fmt.Fprintf(m.out, "$%04X\n", int(start)+i)
+		}
+	}
+	return nil
+}
+
+// disassemble lists the instructions from start to end
+func (m *monitor) disassemble(args []string) error {
+	var start, end uint16
+	var err error
+	switch len(args) {
+	case 0:
+		start = m.dot
+	case 1:
+		start, err = parseAddress(args[0])
+	case 2:
+		start, end, err = m.parseRange(args[0], args[1])
+	default:
+		return m.usage("d")
+	}
+	if err != nil {
+		return err
+	}
+
+	addr := start
+	for lines := 0; len(args) == 2 || lines < disassembleLines; lines++ {
+		next := m.printInstruction(addr)
+		wrapped := next < addr
+		addr = next
+		if len(args) == 2 && (addr > end || wrapped) {
+			break
+		}
+	}
+
+	m.dot = addr
+	return nil
+}
+
+// assemble assembles an instruction at addr, and shows what it became
+func (m *monitor) assemble(args []string) error {
+	if len(args) < 2 {
+		return m.usage("a")
+	}
+	addr, err := parseAddress(args[0])
+	if err != nil {
+		return err
+	}
+
+	code, err := assemble(m.cpu, addr, strings.Join(args[1:], " "))
+	if err != nil {
+		return err
+	}
+	for i, b := range code {
+		m.bus.Write(addr+uint16(i), b)
+	}
+
+	m.dot = m.printInstruction(addr)
+	return nil
+}
+
+// step runs count instructions, one by default
+func (m *monitor) step(args []string) error {
+	count := 1
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return fmt.Errorf("bad count %q", args[0])
+		}
+		count = n
+	}
+
+	for i := 0; i < count; i++ {
+		if stop := m.debugger.Step(); stop.Reason != debugger.ReasonStep {
+			return m.stopped(stop)
+		}
+	}
+	return m.stopped(debugger.Stop{Reason: debugger.ReasonStep, PC: m.cpu.PC()})
+}
+
+// next steps over a JSR, running the subroutine until it returns
+func (m *monitor) next([]string) error {
+	ctx, cancel := m.interrupt()
+	defer cancel()
+
+	return m.stopped(m.debugger.StepOver(ctx))
+}
+
+// ret runs until the current subroutine returns
+func (m *monitor) ret([]string) error {
+	ctx, cancel := m.interrupt()
+	defer cancel()
+
+	return m.stopped(m.debugger.StepOut(ctx))
+}
+
+// goCommand runs from addr, or carries on, until something stops the CPU
+func (m *monitor) goCommand(args []string) error {
+	if len(args) > 0 {
+		addr, err := parseAddress(args[0])
+		if err != nil {
+			return err
+		}
+		m.cpu.SetPC(addr)
+	}
+
+	ctx, cancel := m.interrupt()
+	defer cancel()
+
+	return m.stopped(m.debugger.Continue(ctx))
+}
+
+// breakCommand adds a breakpoint, or lists them all
+func (m *monitor) breakCommand(args []string) error {
+	if len(args) == 0 {
+		for _, b := range m.debugger.Breakpoints() {
+			fmt.Fprintf(m.out, "%s, hit %d times\n", &b, b.Hits)
+		}
+		return nil
+	}
+
+	addr, err := parseAddress(args[0])
+	if err != nil {
+		return err
+	}
+	condition, err := parseCondition(args[1:])
+	if err != nil {
+		return err
+	}
+
+	b, err := m.debugger.Break(addr, condition)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(m.out, b)
+	return nil
+}
+
+// watch adds a watchpoint
+func (m *monitor) watch(args []string) error {
+	if len(args) < 2 {
+		return m.usage("watch")
+	}
+
+	kinds := map[string]debugger.Kind{"r": debugger.Read, "w": debugger.Write, "rw": debugger.Access}
+	kind, ok := kinds[strings.ToLower(args[0])]
+	if !ok {
+		return m.usage("watch")
+	}
+
+	start, err := parseAddress(args[1])
+	if err != nil {
+		return err
+	}
+	end, rest := start, args[2:]
+	if len(rest) > 0 && !strings.EqualFold(rest[0], "if") {
+		if end, err = parseAddress(rest[0]); err != nil {
+			return err
+		}
+		rest = rest[1:]
+	}
+	condition, err := parseCondition(rest)
+	if err != nil {
+		return err
+	}
+
+	b, err := m.debugger.Watch(kind, start, end, condition)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(m.out, b)
+	return nil
+}
+
+func (m *monitor) deleteBreakpoint(args []string) error {
+	id, err := parseID(args)
+	if err != nil {
+		return m.usage("del")
+	}
+	if !m.debugger.Remove(id) {
+		return fmt.Errorf("no breakpoint #%d", id)
+	}
+	return nil
+}
+
+func (m *monitor) enable(args []string) error {
+	return m.setEnabled("enable", args, true)
+}
+
+func (m *monitor) disable(args []string) error {
+	return m.setEnabled("disable", args, false)
+}
+
+func (m *monitor) setEnabled(name string, args []string, enabled bool) error {
+	id, err := parseID(args)
+	if err != nil {
+		return m.usage(name)
+	}
+	if !m.debugger.Enable(id, enabled) {
+		return fmt.Errorf("no breakpoint #%d", id)
+	}
+	return nil
+}
+
+// load loads a file into memory
+func (m *monitor) load(args []string) error {
+	if len(args) != 2 {
+		return m.usage("l")
+	}
+	addr, err := parseAddress(args[1])
+	if err != nil {
+		return err
+	}
+
+	end, err := m.bus.LoadFile(args[0], addr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(m.out, "Loaded $%04X-$%04X\n", addr, end-1)
+	return nil
+}
+
+// save saves memory to a file
+func (m *monitor) save(args []string) error {
+	if len(args) != 3 {
+		return m.usage("s")
+	}
+	start, end, err := m.parseRange(args[1], args[2])
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(args[0], m.bus.Memory[start:int(end)+1], 0o644)
+}
+
+func (m *monitor) exit([]string) error {
+	m.quit = true
+	return nil
+}
+
+func (m *monitor) help([]string) error {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(m.out, "%-40s %s\n", commands[name].usage, commands[name].help)
+	}
+	fmt.Fprintln(m.out, "Numbers are hex. Conditions are like A == $40 && [$D012] > $80.")
+	return nil
+}
+
+// stopped reports why the CPU stopped, and shows the next instruction
+func (m *monitor) stopped(stop debugger.Stop) error {
+	if stop.Reason != debugger.ReasonStep {
+		fmt.Fprintln(m.out, stop)
+	}
+	m.dot = m.printInstruction(stop.PC)
+	return nil
+}
+
+// printInstruction shows the instruction at addr with its bytes, and returns
+// the address of the next one
+func (m *monitor) printInstruction(addr uint16) uint16 {
+	next := m.nextInstruction(addr)
+
+	var code strings.Builder
+	for a := addr; a != next; a++ {
+		fmt.Fprintf(&code, "%02X ", m.bus.Peek(a))
+	}
+	fmt.Fprintf(m.out, ".C:%04X  %-9s  %s\n", addr, code.String(), m.cpu.DisassembleAt(addr))
+
+	return next
+}
+
+// nextInstruction returns the address of the instruction after the one at
+// addr
+func (m *monitor) nextInstruction(addr uint16) uint16 {
+	return addr + uint16(m.cpu.Decode(m.bus.Peek(addr)).Length())
+}
+
+// addressRange parses an optional start and end address. Without an end,
+// the range is length bytes long, stopping short at the top of memory;
+// without a start it carries on from the last one.
+func (m *monitor) addressRange(args []string, length int) (uint16, uint16, error) {
+	// lengthFrom ends the range without wrapping around
+	lengthFrom := func(start uint16) uint16 {
+		return uint16(min(int(start)+length-1, 0xFFFF))
+	}
+
+	switch len(args) {
+	case 0:
+		return m.dot, lengthFrom(m.dot), nil
+	case 1:
+		start, err := parseAddress(args[0])
+		return start, lengthFrom(start), err
+	case 2:
+		return m.parseRange(args[0], args[1])
+	default:
+		return 0, 0, fmt.Errorf("too many addresses")
+	}
+}
+
+// parseRange parses a start and an end address, inclusive
+func (m *monitor) parseRange(startText, endText string) (uint16, uint16, error) {
+	start, err := parseAddress(startText)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseAddress(endText)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("range ends at $%04X before it starts at $%04X", end, start)
+	}
+	return start, end, nil
+}
+
+// usage returns an error showing how to use a command
+func (m *monitor) usage(name string) error {
+	return fmt.Errorf("usage: %s", commands[name].usage)
+}
+
+// parseBytes parses a list of hex bytes
+func parseBytes(args []string) ([]uint8, error) {
+	var values []uint8
+	for _, arg := range args {
+		value, err := parseAddress(arg)
+		if err != nil {
+			return nil, err
+		}
+		if value > 0xFF {
+			return nil, fmt.Errorf("$%04X doesn't fit in a byte", value)
+		}
+		values = append(values, uint8(value))
+	}
+	return values, nil
+}
+
+// parseCondition returns the condition after an "if", if there is one
+func parseCondition(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", nil
+	}
+	if !strings.EqualFold(args[0], "if") || len(args) == 1 {
+		return "", fmt.Errorf("expected if condition")
+	}
+	return strings.Join(args[1:], " "), nil
+}
+
+// parseID parses a breakpoint number, which is decimal
+func parseID(args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("expected a breakpoint number")
+	}
+	return strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+}
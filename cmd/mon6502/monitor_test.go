@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/drewwalton19216801/goemu6502"
+)
+
+func TestAssemble(t *testing.T) {
+	cpu := goemu6502.NewCPU(&goemu6502.RAM{}, goemu6502.WithVariant(goemu6502.WDC65C02))
+
+	for _, tt := range []struct {
+		line string
+		want []uint8
+	}{
+		{line: "nop", want: []uint8{0xEA}},
+		{line: "asl", want: []uint8{0x0A}},
+		{line: "asl a", want: []uint8{0x0A}},
+		{line: "lda #$41", want: []uint8{0xA9, 0x41}},
+		{line: "lda 12", want: []uint8{0xA5, 0x12}},
+		{line: "lda $0012", want: []uint8{0xAD, 0x12, 0x00}},
+		{line: "lda 12,x", want: []uint8{0xB5, 0x12}},
+		{line: "ldx 12,y", want: []uint8{0xB6, 0x12}},
+		{line: "lda 1234,y", want: []uint8{0xB9, 0x34, 0x12}},
+		{line: "lda ($12,x)", want: []uint8{0xA1, 0x12}},
+		{line: "lda ($12),y", want: []uint8{0xB1, 0x12}},
+		{line: "lda ($12)", want: []uint8{0xB2, 0x12}},
+		{line: "jmp ($fffc)", want: []uint8{0x6C, 0xFC, 0xFF}},
+		{line: "jmp ($1234,x)", want: []uint8{0x7C, 0x34, 0x12}},
+		{line: "bne $0400", want: []uint8{0xD0, 0xFE}},
+		{line: "bbr0 $10,$0410", want: []uint8{0x0F, 0x10, 0x0D}},
+	} {
+		got, err := assemble(cpu, 0x0400, tt.line)
+		if err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("%q = % X, %v, want % X", tt.line, got, err, tt.want)
+		}
+	}
+
+	for _, line := range []string{"foo", "lda", "ldx ($12),y", "bne $1000", "lda #$1234"} {
+		if _, err := assemble(cpu, 0x0400, line); err == nil {
+			t.Errorf("%q assembled", line)
+		}
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	// Assemble a subroutine, step over it and run to its RTS
+	script := []string{
+		"a 0400 jsr 0410",
+		"a 0403 nop",
+		"a 0410 ldx #3",
+		"a 0412 dex",
+		"a 0413 bne 0412",
+		"a 0415 rts",
+		"r pc=0400 sp=fd",
+		"n",
+		"r pc=0400",
+		"z",
+		"ret",
+		"break 0412 if X == 1",
+		"r pc=0400",
+		"g",
+		"f 0500 0503 aa bb",
+		"m 0500 0503",
+		"m fff0",
+		"h 0400 04ff ca d0",
+		"x",
+		"never reached",
+	}
+
+	bus := &goemu6502.RAM{}
+	cpu := goemu6502.NewCPU(bus)
+	var out bytes.Buffer
+	m := newMonitor(cpu, bus, &out)
+	m.run(strings.NewReader(strings.Join(script, "\n")))
+
+	for _, want := range []string{
+		".C:0403  EA         nop",
+		".C:0415  60         rts",
+		"breakpoint #1 execute $0412 if X == 1 at $0412",
+		">C:0500  AA BB AA BB",
+		">C:FFF0  00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00  ................\n",
+		"$0412\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "error") {
+		t.Errorf("output has errors:\n%s", out.String())
+	}
+	if cpu.X() != 1 {
+		t.Errorf("X = %d, want 1", cpu.X())
+	}
+}
@@ -0,0 +1,459 @@
+package goemu6502
+
+// --- 65C02 instructions ---
+// The CMOS 65C02 fixes several NMOS bugs, adds a handful of instructions and
+// the (zp) addressing mode, and turns every undocumented opcode into a NOP.
+// Rockwell added the bit instructions and WDC then added WAI and STP, so the
+// tables for the earlier chips are derived from the WDC one.
+
+// InstructionsWDC65C02 is the instruction table for the WDC W65C02S
+var InstructionsWDC65C02 = [256]InstructionInfo{
+	0x69: {adc, 0x69, Immediate, 2, (*CPU).adc},
+	0x65: {adc, 0x65, ZeroPage, 3, (*CPU).adc},
+	0x75: {adc, 0x75, ZeroPageX, 4, (*CPU).adc},
+	0x6D: {adc, 0x6D, Absolute, 4, (*CPU).adc},
+	0x7D: {adc, 0x7D, AbsoluteX, 4, (*CPU).adc},
+	0x79: {adc, 0x79, AbsoluteY, 4, (*CPU).adc},
+	0x61: {adc, 0x61, IndexedIndirect, 6, (*CPU).adc},
+	0x71: {adc, 0x71, IndirectIndexed, 5, (*CPU).adc},
+	0x72: {adc, 0x72, ZeroPageIndirect, 5, (*CPU).adc},
+	0x29: {and, 0x29, Immediate, 2, (*CPU).and},
+	0x25: {and, 0x25, ZeroPage, 3, (*CPU).and},
+	0x35: {and, 0x35, ZeroPageX, 4, (*CPU).and},
+	0x2D: {and, 0x2D, Absolute, 4, (*CPU).and},
+	0x3D: {and, 0x3D, AbsoluteX, 4, (*CPU).and},
+	0x39: {and, 0x39, AbsoluteY, 4, (*CPU).and},
+	0x21: {and, 0x21, IndexedIndirect, 6, (*CPU).and},
+	0x31: {and, 0x31, IndirectIndexed, 5, (*CPU).and},
+	0x32: {and, 0x32, ZeroPageIndirect, 5, (*CPU).and},
+	0x0A: {asl, 0x0A, Accumulator, 2, (*CPU).asl},
+	0x06: {asl, 0x06, ZeroPage, 5, (*CPU).asl},
+	0x16: {asl, 0x16, ZeroPageX, 6, (*CPU).asl},
+	0x0E: {asl, 0x0E, Absolute, 6, (*CPU).asl},
+	0x1E: {asl, 0x1E, AbsoluteX, 6, (*CPU).aslX},
+	0x90: {bcc, 0x90, Relative, 2, (*CPU).bcc},
+	0xB0: {bcs, 0xB0, Relative, 2, (*CPU).bcs},
+	0xF0: {beq, 0xF0, Relative, 2, (*CPU).beq},
+	0x24: {bit, 0x24, ZeroPage, 3, (*CPU).bit},
+	0x2C: {bit, 0x2C, Absolute, 4, (*CPU).bit},
+	0x89: {bit, 0x89, Immediate, 2, (*CPU).bit},
+	0x34: {bit, 0x34, ZeroPageX, 4, (*CPU).bit},
+	0x3C: {bit, 0x3C, AbsoluteX, 4, (*CPU).bit},
+	0x30: {bmi, 0x30, Relative, 2, (*CPU).bmi},
+	0xD0: {bne, 0xD0, Relative, 2, (*CPU).bne},
+	0x10: {bpl, 0x10, Relative, 2, (*CPU).bpl},
+	0x00: {brk, 0x00, Implied, 7, (*CPU).brk},
+	0x50: {bvc, 0x50, Relative, 2, (*CPU).bvc},
+	0x70: {bvs, 0x70, Relative, 2, (*CPU).bvs},
+	0x18: {clc, 0x18, Implied, 2, (*CPU).clc},
+	0xD8: {cld, 0xD8, Implied, 2, (*CPU).cld},
+	0x58: {cli, 0x58, Implied, 2, (*CPU).cli},
+	0xB8: {clv, 0xB8, Implied, 2, (*CPU).clv},
+	0xC9: {cmp, 0xC9, Immediate, 2, (*CPU).cmp},
+	0xC5: {cmp, 0xC5, ZeroPage, 3, (*CPU).cmp},
+	0xD5: {cmp, 0xD5, ZeroPageX, 4, (*CPU).cmp},
+	0xCD: {cmp, 0xCD, Absolute, 4, (*CPU).cmp},
+	0xDD: {cmp, 0xDD, AbsoluteX, 4, (*CPU).cmp},
+	0xD9: {cmp, 0xD9, AbsoluteY, 4, (*CPU).cmp},
+	0xC1: {cmp, 0xC1, IndexedIndirect, 6, (*CPU).cmp},
+	0xD1: {cmp, 0xD1, IndirectIndexed, 5, (*CPU).cmp},
+	0xD2: {cmp, 0xD2, ZeroPageIndirect, 5, (*CPU).cmp},
+	0xE0: {cpx, 0xE0, Immediate, 2, (*CPU).cpx},
+	0xE4: {cpx, 0xE4, ZeroPage, 3, (*CPU).cpx},
+	0xEC: {cpx, 0xEC, Absolute, 4, (*CPU).cpx},
+	0xC0: {cpy, 0xC0, Immediate, 2, (*CPU).cpy},
+	0xC4: {cpy, 0xC4, ZeroPage, 3, (*CPU).cpy},
+	0xCC: {cpy, 0xCC, Absolute, 4, (*CPU).cpy},
+	0xC6: {dec, 0xC6, ZeroPage, 5, (*CPU).dec},
+	0xD6: {dec, 0xD6, ZeroPageX, 6, (*CPU).dec},
+	0xCE: {dec, 0xCE, Absolute, 6, (*CPU).dec},
+	0xDE: {dec, 0xDE, AbsoluteX, 7, (*CPU).dec},
+	0x3A: {dec, 0x3A, Accumulator, 2, (*CPU).dec},
+	0xCA: {dex, 0xCA, Implied, 2, (*CPU).dex},
+	0x88: {dey, 0x88, Implied, 2, (*CPU).dey},
+	0x49: {eor, 0x49, Immediate, 2, (*CPU).eor},
+	0x45: {eor, 0x45, ZeroPage, 3, (*CPU).eor},
+	0x55: {eor, 0x55, ZeroPageX, 4, (*CPU).eor},
+	0x4D: {eor, 0x4D, Absolute, 4, (*CPU).eor},
+	0x5D: {eor, 0x5D, AbsoluteX, 4, (*CPU).eor},
+	0x59: {eor, 0x59, AbsoluteY, 4, (*CPU).eor},
+	0x41: {eor, 0x41, IndexedIndirect, 6, (*CPU).eor},
+	0x51: {eor, 0x51, IndirectIndexed, 5, (*CPU).eor},
+	0x52: {eor, 0x52, ZeroPageIndirect, 5, (*CPU).eor},
+	0xE6: {inc, 0xE6, ZeroPage, 5, (*CPU).inc},
+	0xF6: {inc, 0xF6, ZeroPageX, 6, (*CPU).inc},
+	0xEE: {inc, 0xEE, Absolute, 6, (*CPU).inc},
+	0xFE: {inc, 0xFE, AbsoluteX, 7, (*CPU).inc},
+	0x1A: {inc, 0x1A, Accumulator, 2, (*CPU).inc},
+	0xE8: {inx, 0xE8, Implied, 2, (*CPU).inx},
+	0xC8: {iny, 0xC8, Implied, 2, (*CPU).iny},
+	0x4C: {jmp, 0x4C, Absolute, 3, (*CPU).jmp},
+	0x6C: {jmp, 0x6C, Indirect, 6, (*CPU).jmp},
+	0x7C: {jmp, 0x7C, AbsoluteIndexedIndirect, 6, (*CPU).jmp},
+	0x20: {jsr, 0x20, Absolute, 6, (*CPU).jsr},
+	0xA9: {lda, 0xA9, Immediate, 2, (*CPU).lda},
+	0xA5: {lda, 0xA5, ZeroPage, 3, (*CPU).lda},
+	0xB5: {lda, 0xB5, ZeroPageX, 4, (*CPU).lda},
+	0xAD: {lda, 0xAD, Absolute, 4, (*CPU).lda},
+	0xBD: {lda, 0xBD, AbsoluteX, 4, (*CPU).lda},
+	0xB9: {lda, 0xB9, AbsoluteY, 4, (*CPU).lda},
+	0xA1: {lda, 0xA1, IndexedIndirect, 6, (*CPU).lda},
+	0xB1: {lda, 0xB1, IndirectIndexed, 5, (*CPU).lda},
+	0xB2: {lda, 0xB2, ZeroPageIndirect, 5, (*CPU).lda},
+	0xA2: {ldx, 0xA2, Immediate, 2, (*CPU).ldx},
+	0xA6: {ldx, 0xA6, ZeroPage, 3, (*CPU).ldx},
+	0xB6: {ldx, 0xB6, ZeroPageY, 4, (*CPU).ldx},
+	0xAE: {ldx, 0xAE, Absolute, 4, (*CPU).ldx},
+	0xBE: {ldx, 0xBE, AbsoluteY, 4, (*CPU).ldx},
+	0xA0: {ldy, 0xA0, Immediate, 2, (*CPU).ldy},
+	0xA4: {ldy, 0xA4, ZeroPage, 3, (*CPU).ldy},
+	0xB4: {ldy, 0xB4, ZeroPageX, 4, (*CPU).ldy},
+	0xAC: {ldy, 0xAC, Absolute, 4, (*CPU).ldy},
+	0xBC: {ldy, 0xBC, AbsoluteX, 4, (*CPU).ldy},
+	0x4A: {lsr, 0x4A, Accumulator, 2, (*CPU).lsr},
+	0x46: {lsr, 0x46, ZeroPage, 5, (*CPU).lsr},
+	0x56: {lsr, 0x56, ZeroPageX, 6, (*CPU).lsr},
+	0x4E: {lsr, 0x4E, Absolute, 6, (*CPU).lsr},
+	0x5E: {lsr, 0x5E, AbsoluteX, 6, (*CPU).lsrX},
+	0xEA: {nop, 0xEA, Implied, 2, (*CPU).nop},
+	0x09: {ora, 0x09, Immediate, 2, (*CPU).ora},
+	0x05: {ora, 0x05, ZeroPage, 3, (*CPU).ora},
+	0x15: {ora, 0x15, ZeroPageX, 4, (*CPU).ora},
+	0x0D: {ora, 0x0D, Absolute, 4, (*CPU).ora},
+	0x1D: {ora, 0x1D, AbsoluteX, 4, (*CPU).ora},
+	0x19: {ora, 0x19, AbsoluteY, 4, (*CPU).ora},
+	0x01: {ora, 0x01, IndexedIndirect, 6, (*CPU).ora},
+	0x11: {ora, 0x11, IndirectIndexed, 5, (*CPU).ora},
+	0x12: {ora, 0x12, ZeroPageIndirect, 5, (*CPU).ora},
+	0x48: {pha, 0x48, Implied, 3, (*CPU).pha},
+	0x08: {php, 0x08, Implied, 3, (*CPU).php},
+	0x68: {pla, 0x68, Implied, 4, (*CPU).pla},
+	0x28: {plp, 0x28, Implied, 4, (*CPU).plp},
+	0x2A: {rol, 0x2A, Accumulator, 2, (*CPU).rol},
+	0x26: {rol, 0x26, ZeroPage, 5, (*CPU).rol},
+	0x36: {rol, 0x36, ZeroPageX, 6, (*CPU).rol},
+	0x2E: {rol, 0x2E, Absolute, 6, (*CPU).rol},
+	0x3E: {rol, 0x3E, AbsoluteX, 6, (*CPU).rolX},
+	0x6A: {ror, 0x6A, Accumulator, 2, (*CPU).ror},
+	0x66: {ror, 0x66, ZeroPage, 5, (*CPU).ror},
+	0x76: {ror, 0x76, ZeroPageX, 6, (*CPU).ror},
+	0x6E: {ror, 0x6E, Absolute, 6, (*CPU).ror},
+	0x7E: {ror, 0x7E, AbsoluteX, 6, (*CPU).rorX},
+	0x40: {rti, 0x40, Implied, 6, (*CPU).rti},
+	0x60: {rts, 0x60, Implied, 6, (*CPU).rts},
+	0xE9: {sbc, 0xE9, Immediate, 2, (*CPU).sbc},
+	0xE5: {sbc, 0xE5, ZeroPage, 3, (*CPU).sbc},
+	0xF5: {sbc, 0xF5, ZeroPageX, 4, (*CPU).sbc},
+	0xED: {sbc, 0xED, Absolute, 4, (*CPU).sbc},
+	0xFD: {sbc, 0xFD, AbsoluteX, 4, (*CPU).sbc},
+	0xF9: {sbc, 0xF9, AbsoluteY, 4, (*CPU).sbc},
+	0xE1: {sbc, 0xE1, IndexedIndirect, 6, (*CPU).sbc},
+	0xF1: {sbc, 0xF1, IndirectIndexed, 5, (*CPU).sbc},
+	0xF2: {sbc, 0xF2, ZeroPageIndirect, 5, (*CPU).sbc},
+	0x38: {sec, 0x38, Implied, 2, (*CPU).sec},
+	0xF8: {sed, 0xF8, Implied, 2, (*CPU).sed},
+	0x78: {sei, 0x78, Implied, 2, (*CPU).sei},
+	0x85: {sta, 0x85, ZeroPage, 3, (*CPU).sta},
+	0x95: {sta, 0x95, ZeroPageX, 4, (*CPU).sta},
+	0x8D: {sta, 0x8D, Absolute, 4, (*CPU).sta},
+	0x9D: {sta, 0x9D, AbsoluteX, 5, (*CPU).sta},
+	0x99: {sta, 0x99, AbsoluteY, 5, (*CPU).sta},
+	0x81: {sta, 0x81, IndexedIndirect, 6, (*CPU).sta},
+	0x91: {sta, 0x91, IndirectIndexed, 6, (*CPU).sta},
+	0x92: {sta, 0x92, ZeroPageIndirect, 5, (*CPU).sta},
+	0x86: {stx, 0x86, ZeroPage, 3, (*CPU).stx},
+	0x96: {stx, 0x96, ZeroPageY, 4, (*CPU).stx},
+	0x8E: {stx, 0x8E, Absolute, 4, (*CPU).stx},
+	0x84: {sty, 0x84, ZeroPage, 3, (*CPU).sty},
+	0x94: {sty, 0x94, ZeroPageX, 4, (*CPU).sty},
+	0x8C: {sty, 0x8C, Absolute, 4, (*CPU).sty},
+	0xAA: {tax, 0xAA, Implied, 2, (*CPU).tax},
+	0xA8: {tay, 0xA8, Implied, 2, (*CPU).tay},
+	0xBA: {tsx, 0xBA, Implied, 2, (*CPU).tsx},
+	0x8A: {txa, 0x8A, Implied, 2, (*CPU).txa},
+	0x9A: {txs, 0x9A, Implied, 2, (*CPU).txs},
+	0x98: {tya, 0x98, Implied, 2, (*CPU).tya},
+
+	// New on the 65C02
+	0x80: {bra, 0x80, Relative, 2, (*CPU).bra},
+	0xDA: {phx, 0xDA, Implied, 3, (*CPU).phx},
+	0x5A: {phy, 0x5A, Implied, 3, (*CPU).phy},
+	0xFA: {plx, 0xFA, Implied, 4, (*CPU).plx},
+	0x7A: {ply, 0x7A, Implied, 4, (*CPU).ply},
+	0x64: {stz, 0x64, ZeroPage, 3, (*CPU).stz},
+	0x74: {stz, 0x74, ZeroPageX, 4, (*CPU).stz},
+	0x9C: {stz, 0x9C, Absolute, 4, (*CPU).stz},
+	0x9E: {stz, 0x9E, AbsoluteX, 5, (*CPU).stz},
+	0x14: {trb, 0x14, ZeroPage, 5, (*CPU).trb},
+	0x1C: {trb, 0x1C, Absolute, 6, (*CPU).trb},
+	0x04: {tsb, 0x04, ZeroPage, 5, (*CPU).tsb},
+	0x0C: {tsb, 0x0C, Absolute, 6, (*CPU).tsb},
+
+	// Rockwell and WDC bit instructions
+	0x07: {rmb0, 0x07, ZeroPage, 5, (*CPU).rmb},
+	0x17: {rmb1, 0x17, ZeroPage, 5, (*CPU).rmb},
+	0x27: {rmb2, 0x27, ZeroPage, 5, (*CPU).rmb},
+	0x37: {rmb3, 0x37, ZeroPage, 5, (*CPU).rmb},
+	0x47: {rmb4, 0x47, ZeroPage, 5, (*CPU).rmb},
+	0x57: {rmb5, 0x57, ZeroPage, 5, (*CPU).rmb},
+	0x67: {rmb6, 0x67, ZeroPage, 5, (*CPU).rmb},
+	0x77: {rmb7, 0x77, ZeroPage, 5, (*CPU).rmb},
+	0x87: {smb0, 0x87, ZeroPage, 5, (*CPU).smb},
+	0x97: {smb1, 0x97, ZeroPage, 5, (*CPU).smb},
+	0xA7: {smb2, 0xA7, ZeroPage, 5, (*CPU).smb},
+	0xB7: {smb3, 0xB7, ZeroPage, 5, (*CPU).smb},
+	0xC7: {smb4, 0xC7, ZeroPage, 5, (*CPU).smb},
+	0xD7: {smb5, 0xD7, ZeroPage, 5, (*CPU).smb},
+	0xE7: {smb6, 0xE7, ZeroPage, 5, (*CPU).smb},
+	0xF7: {smb7, 0xF7, ZeroPage, 5, (*CPU).smb},
+	0x0F: {bbr0, 0x0F, ZeroPageRelative, 5, (*CPU).bbr},
+	0x1F: {bbr1, 0x1F, ZeroPageRelative, 5, (*CPU).bbr},
+	0x2F: {bbr2, 0x2F, ZeroPageRelative, 5, (*CPU).bbr},
+	0x3F: {bbr3, 0x3F, ZeroPageRelative, 5, (*CPU).bbr},
+	0x4F: {bbr4, 0x4F, ZeroPageRelative, 5, (*CPU).bbr},
+	0x5F: {bbr5, 0x5F, ZeroPageRelative, 5, (*CPU).bbr},
+	0x6F: {bbr6, 0x6F, ZeroPageRelative, 5, (*CPU).bbr},
+	0x7F: {bbr7, 0x7F, ZeroPageRelative, 5, (*CPU).bbr},
+	0x8F: {bbs0, 0x8F, ZeroPageRelative, 5, (*CPU).bbs},
+	0x9F: {bbs1, 0x9F, ZeroPageRelative, 5, (*CPU).bbs},
+	0xAF: {bbs2, 0xAF, ZeroPageRelative, 5, (*CPU).bbs},
+	0xBF: {bbs3, 0xBF, ZeroPageRelative, 5, (*CPU).bbs},
+	0xCF: {bbs4, 0xCF, ZeroPageRelative, 5, (*CPU).bbs},
+	0xDF: {bbs5, 0xDF, ZeroPageRelative, 5, (*CPU).bbs},
+	0xEF: {bbs6, 0xEF, ZeroPageRelative, 5, (*CPU).bbs},
+	0xFF: {bbs7, 0xFF, ZeroPageRelative, 5, (*CPU).bbs},
+
+	// WDC only
+	0xCB: {wai, 0xCB, Implied, 3, (*CPU).wai},
+	0xDB: {stp, 0xDB, Implied, 3, (*CPU).stp},
+
+	// Unused opcodes are NOPs, some of which still read their operands
+	0x02: {nop, 0x02, Immediate, 2, (*CPU).nop},
+	0x22: {nop, 0x22, Immediate, 2, (*CPU).nop},
+	0x42: {nop, 0x42, Immediate, 2, (*CPU).nop},
+	0x62: {nop, 0x62, Immediate, 2, (*CPU).nop},
+	0x82: {nop, 0x82, Immediate, 2, (*CPU).nop},
+	0xC2: {nop, 0xC2, Immediate, 2, (*CPU).nop},
+	0xE2: {nop, 0xE2, Immediate, 2, (*CPU).nop},
+	0x44: {nop, 0x44, ZeroPage, 3, (*CPU).nop},
+	0x54: {nop, 0x54, ZeroPageX, 4, (*CPU).nop},
+	0xD4: {nop, 0xD4, ZeroPageX, 4, (*CPU).nop},
+	0xF4: {nop, 0xF4, ZeroPageX, 4, (*CPU).nop},
+	0x5C: {nop, 0x5C, Absolute, 8, (*CPU).nop},
+	0xDC: {nop, 0xDC, Absolute, 4, (*CPU).nop},
+	0xFC: {nop, 0xFC, Absolute, 4, (*CPU).nop},
+	0x03: {nop, 0x03, Implied, 1, (*CPU).nop},
+	0x13: {nop, 0x13, Implied, 1, (*CPU).nop},
+	0x23: {nop, 0x23, Implied, 1, (*CPU).nop},
+	0x33: {nop, 0x33, Implied, 1, (*CPU).nop},
+	0x43: {nop, 0x43, Implied, 1, (*CPU).nop},
+	0x53: {nop, 0x53, Implied, 1, (*CPU).nop},
+	0x63: {nop, 0x63, Implied, 1, (*CPU).nop},
+	0x73: {nop, 0x73, Implied, 1, (*CPU).nop},
+	0x83: {nop, 0x83, Implied, 1, (*CPU).nop},
+	0x93: {nop, 0x93, Implied, 1, (*CPU).nop},
+	0xA3: {nop, 0xA3, Implied, 1, (*CPU).nop},
+	0xB3: {nop, 0xB3, Implied, 1, (*CPU).nop},
+	0xC3: {nop, 0xC3, Implied, 1, (*CPU).nop},
+	0xD3: {nop, 0xD3, Implied, 1, (*CPU).nop},
+	0xE3: {nop, 0xE3, Implied, 1, (*CPU).nop},
+	0xF3: {nop, 0xF3, Implied, 1, (*CPU).nop},
+	0x0B: {nop, 0x0B, Implied, 1, (*CPU).nop},
+	0x1B: {nop, 0x1B, Implied, 1, (*CPU).nop},
+	0x2B: {nop, 0x2B, Implied, 1, (*CPU).nop},
+	0x3B: {nop, 0x3B, Implied, 1, (*CPU).nop},
+	0x4B: {nop, 0x4B, Implied, 1, (*CPU).nop},
+	0x5B: {nop, 0x5B, Implied, 1, (*CPU).nop},
+	0x6B: {nop, 0x6B, Implied, 1, (*CPU).nop},
+	0x7B: {nop, 0x7B, Implied, 1, (*CPU).nop},
+	0x8B: {nop, 0x8B, Implied, 1, (*CPU).nop},
+	0x9B: {nop, 0x9B, Implied, 1, (*CPU).nop},
+	0xAB: {nop, 0xAB, Implied, 1, (*CPU).nop},
+	0xBB: {nop, 0xBB, Implied, 1, (*CPU).nop},
+	0xEB: {nop, 0xEB, Implied, 1, (*CPU).nop},
+	0xFB: {nop, 0xFB, Implied, 1, (*CPU).nop},
+}
+
+// InstructionsRockwell65C02 is the instruction table for the Rockwell R65C02,
+// which lacks WAI and STP
+var InstructionsRockwell65C02 = nopsInPlaceOf(InstructionsWDC65C02, func(i Instruction) bool {
+	return i == wai || i == stp
+})
+
+// Instructions65C02 is the instruction table for the basic 65C02, which also
+// lacks the bit instructions
+var Instructions65C02 = nopsInPlaceOf(InstructionsRockwell65C02, func(i Instruction) bool {
+	return i >= rmb0 && i <= bbs7
+})
+
+// nopsInPlaceOf copies an instruction table, replacing the instructions that
+// remove matches with the single cycle NOPs found in their place on older chips
+func nopsInPlaceOf(table [256]InstructionInfo, remove func(Instruction) bool) [256]InstructionInfo {
+	for opcode, info := range table {
+		if remove(info.Instruction) {
+			table[opcode] = InstructionInfo{nop, uint8(opcode), Implied, 1, (*CPU).nop}
+		}
+	}
+	return table
+}
+
+// aslX is asl for the absolute,X mode, which the 65C02 only charges a seventh
+// cycle for when indexing crosses a page
+func (c *CPU) aslX() uint8 {
+	c.asl()
+	return 1
+}
+
+// lsrX is lsr for the absolute,X mode, see aslX
+func (c *CPU) lsrX() uint8 {
+	c.lsr()
+	return 1
+}
+
+// rolX is rol for the absolute,X mode, see aslX
+func (c *CPU) rolX() uint8 {
+	c.rol()
+	return 1
+}
+
+// rorX is ror for the absolute,X mode, see aslX
+func (c *CPU) rorX() uint8 {
+	c.ror()
+	return 1
+}
+
+// bra branches always
+func (c *CPU) bra() uint8 {
+	return c.branch(true)
+}
+
+// phx pushes X register
+func (c *CPU) phx() uint8 {
+	// Push the X register to the stack
+	c.pushByte(c.r.x)
+
+	return 0
+}
+
+// phy pushes Y register
+func (c *CPU) phy() uint8 {
+	// Push the Y register to the stack
+	c.pushByte(c.r.y)
+
+	return 0
+}
+
+// plx pulls X register
+func (c *CPU) plx() uint8 {
+	// Pop the next byte from the stack and store it in the X register
+	c.r.x = c.popByte()
+
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.x == 0)
+	c.setFlag(Negative, c.r.x&0x80 != 0)
+
+	return 0
+}
+
+// ply pulls Y register
+func (c *CPU) ply() uint8 {
+	// Pop the next byte from the stack and store it in the Y register
+	c.r.y = c.popByte()
+
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.y == 0)
+	c.setFlag(Negative, c.r.y&0x80 != 0)
+
+	return 0
+}
+
+// stz stores zero
+func (c *CPU) stz() uint8 {
+	// Store zero at the absolute address
+	c.bus.Write(c.i.addr_absolute, 0x00)
+
+	return 0
+}
+
+// trb tests and resets memory bits with accumulator
+func (c *CPU) trb() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// Set the zero flag if the accumulator and memory have no bits in common
+	c.setFlag(Zero, c.r.a&c.i.fetched == 0)
+
+	// Clear the bits that are set in the accumulator
+	c.bus.Write(c.i.addr_absolute, c.i.fetched&^c.r.a)
+
+	return 0
+}
+
+// tsb tests and sets memory bits with accumulator
+func (c *CPU) tsb() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// Set the zero flag if the accumulator and memory have no bits in common
+	c.setFlag(Zero, c.r.a&c.i.fetched == 0)
+
+	// Set the bits that are set in the accumulator
+	c.bus.Write(c.i.addr_absolute, c.i.fetched|c.r.a)
+
+	return 0
+}
+
+// opcodeBit returns a mask for the bit number encoded in the top of a bit
+// instruction's opcode, e.g. $08 for RMB3 ($37)
+func (c *CPU) opcodeBit() uint8 {
+	return 1 << ((c.i.opcode >> 4) & 0x07)
+}
+
+// rmb resets a memory bit
+func (c *CPU) rmb() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// Clear the bit and store the result in memory
+	c.bus.Write(c.i.addr_absolute, c.i.fetched&^c.opcodeBit())
+
+	return 0
+}
+
+// smb sets a memory bit
+func (c *CPU) smb() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// Set the bit and store the result in memory
+	c.bus.Write(c.i.addr_absolute, c.i.fetched|c.opcodeBit())
+
+	return 0
+}
+
+// bbr branches if a memory bit is reset
+func (c *CPU) bbr() uint8 {
+	return c.branch(c.fetchByte()&c.opcodeBit() == 0)
+}
+
+// bbs branches if a memory bit is set
+func (c *CPU) bbs() uint8 {
+	return c.branch(c.fetchByte()&c.opcodeBit() != 0)
+}
+
+// wai waits for an interrupt. The CPU stops fetching instructions until IRQ
+// or NMI is asserted; if interrupts are disabled, it simply carries on. It
+// doesn't wait at all if one is asserted already.
+func (c *CPU) wai() uint8 {
+	if nmi, irq := c.sampleInterrupts(); !nmi && !irq {
+		c.status.waiting = true
+	}
+
+	return 0
+}
+
+// stp stops the clock until the CPU is reset, which looks the same as a JAM
+// from the outside
+func (c *CPU) stp() uint8 {
+	return c.jam()
+}
@@ -0,0 +1,160 @@
+package goemu6502
+
+import "testing"
+
+func TestCMOSInstructions(t *testing.T) {
+	for _, tt := range []instructionTest{
+		{name: "BRA", program: []uint8{0x80, 0x02}, wantPC: 0x0404, wantCycles: 3},
+		{name: "PHX", program: []uint8{0xDA}, x: 0x42,
+			wantX: 0x42, wantMemory: map[uint16]uint8{0x01FD: 0x42}, wantCycles: 3},
+		{name: "PLY", program: []uint8{0x7A}, memory: map[uint16]uint8{0x01FE: 0x80},
+			wantY: 0x80, wantP: Negative, wantCycles: 4},
+		{name: "STZ abs", program: []uint8{0x9C, 0x00, 0x20}, memory: map[uint16]uint8{0x2000: 0xFF},
+			wantMemory: map[uint16]uint8{0x2000: 0x00}, wantCycles: 4},
+		{name: "TRB zp", program: []uint8{0x14, 0x10}, a: 0x0F, memory: map[uint16]uint8{0x10: 0x3C},
+			wantA: 0x0F, wantMemory: map[uint16]uint8{0x10: 0x30}, wantCycles: 5},
+		{name: "TSB zp", program: []uint8{0x04, 0x10}, a: 0x01, memory: map[uint16]uint8{0x10: 0x02},
+			wantA: 0x01, wantP: Zero, wantMemory: map[uint16]uint8{0x10: 0x03}, wantCycles: 5},
+		{name: "INC A", program: []uint8{0x1A}, a: 0xFF, wantA: 0x00, wantP: Zero, wantCycles: 2},
+		{name: "DEC A", program: []uint8{0x3A}, a: 0x00, wantA: 0xFF, wantP: Negative, wantCycles: 2},
+		{name: "BIT #", program: []uint8{0x89, 0xC0}, a: 0x01, p: Overflow,
+			wantA: 0x01, wantP: Zero | Overflow, wantCycles: 2},
+		{name: "BIT abs,X page cross", program: []uint8{0x3C, 0xFF, 0x20}, a: 0xFF, x: 0x01,
+			memory: map[uint16]uint8{0x2100: 0xC0},
+			wantA:  0xFF, wantX: 0x01, wantP: Negative | Overflow, wantCycles: 5},
+		{name: "LDA (zp)", program: []uint8{0xB2, 0x10},
+			memory: map[uint16]uint8{0x10: 0x00, 0x11: 0x30, 0x3000: 0x42},
+			wantA:  0x42, wantCycles: 5},
+		{name: "STA (zp)", program: []uint8{0x92, 0xFF}, a: 0x42,
+			memory: map[uint16]uint8{0xFF: 0x00, 0x00: 0x30},
+			wantA:  0x42, wantMemory: map[uint16]uint8{0x3000: 0x42}, wantCycles: 5},
+		{name: "JMP ($10FF)", program: []uint8{0x6C, 0xFF, 0x10},
+			memory: map[uint16]uint8{0x10FF: 0x00, 0x1100: 0x30, 0x1000: 0x20},
+			wantPC: 0x3000, wantCycles: 6},
+		{name: "JMP (abs,X)", program: []uint8{0x7C, 0x00, 0x20}, x: 0x02,
+			memory: map[uint16]uint8{0x2002: 0x34, 0x2003: 0x12},
+			wantX:  0x02, wantPC: 0x1234, wantCycles: 6},
+		{name: "ASL abs,X", program: []uint8{0x1E, 0x00, 0x20}, x: 0x01,
+			memory: map[uint16]uint8{0x2001: 0x40},
+			wantX:  0x01, wantP: Negative, wantMemory: map[uint16]uint8{0x2001: 0x80}, wantCycles: 6},
+		{name: "ASL abs,X page cross", program: []uint8{0x1E, 0xFF, 0x20}, x: 0x01,
+			memory: map[uint16]uint8{0x2100: 0x40},
+			wantX:  0x01, wantP: Negative, wantMemory: map[uint16]uint8{0x2100: 0x80}, wantCycles: 7},
+		{name: "RMB3", program: []uint8{0x37, 0x10}, memory: map[uint16]uint8{0x10: 0xFF},
+			wantMemory: map[uint16]uint8{0x10: 0xF7}, wantCycles: 5},
+		{name: "SMB7", program: []uint8{0xF7, 0x10},
+			wantMemory: map[uint16]uint8{0x10: 0x80}, wantCycles: 5},
+		{name: "BBR0 taken", program: []uint8{0x0F, 0x10, 0x05}, memory: map[uint16]uint8{0x10: 0xFE},
+			wantPC: 0x0408, wantCycles: 6},
+		{name: "BBS0 not taken", program: []uint8{0x8F, 0x10, 0x05}, memory: map[uint16]uint8{0x10: 0xFE},
+			wantPC: 0x0403, wantCycles: 5},
+		{name: "BRK clears D", program: []uint8{0x00}, p: Decimal,
+			memory: map[uint16]uint8{0xFFFE: 0x00, 0xFFFF: 0x30},
+			wantP:  InterruptDisable, wantMemory: map[uint16]uint8{0x01FB: Decimal | Break | Unused},
+			wantPC: 0x3000, wantCycles: 7},
+		{name: "NOP ($03)", program: []uint8{0x03}, wantPC: 0x0401, wantCycles: 1},
+		{name: "NOP # ($02)", program: []uint8{0x02, 0xFF}, wantPC: 0x0402, wantCycles: 2},
+		{name: "NOP abs ($5C)", program: []uint8{0x5C, 0x00, 0x20}, wantPC: 0x0403, wantCycles: 8},
+	} {
+		tt.run(t, WithVariant(WDC65C02))
+	}
+}
+
+func TestCMOSDecimalMode(t *testing.T) {
+	for _, tt := range []instructionTest{
+		{name: "ADC 99+01", program: []uint8{0x69, 0x01}, a: 0x99, p: Decimal,
+			wantA: 0x00, wantP: Decimal | Carry | Zero, wantCycles: 3},
+		{name: "ADC 12+34", program: []uint8{0x69, 0x34}, a: 0x12, p: Decimal,
+			wantA: 0x46, wantP: Decimal, wantCycles: 3},
+		{name: "ADC binary", program: []uint8{0x69, 0x01}, a: 0x99,
+			wantA: 0x9A, wantP: Negative, wantCycles: 2},
+		{name: "SBC 12-21", program: []uint8{0xE9, 0x21}, a: 0x12, p: Decimal | Carry,
+			wantA: 0x91, wantP: Decimal | Negative, wantCycles: 3},
+		{name: "SBC 21-21", program: []uint8{0xE9, 0x21}, a: 0x21, p: Decimal | Carry,
+			wantA: 0x00, wantP: Decimal | Carry | Zero, wantCycles: 3},
+	} {
+		tt.run(t, WithVariant(CMOS65C02))
+	}
+}
+
+func TestNMOSIndirectJumpBug(t *testing.T) {
+	tt := instructionTest{name: "JMP ($10FF)", program: []uint8{0x6C, 0xFF, 0x10},
+		memory: map[uint16]uint8{0x10FF: 0x00, 0x1100: 0x30, 0x1000: 0x20},
+		wantPC: 0x2000, wantCycles: 5}
+	tt.run(t)
+}
+
+func TestWAIWaitsForInterrupt(t *testing.T) {
+	// WAI; NOP
+	bus := &flatBus{}
+	copy(bus.memory[0x0400:], []uint8{0xCB, 0xEA})
+	c := NewCPU(bus, WithVariant(WDC65C02))
+	c.SetRegisters(NewRegisters(0, 0, 0, InterruptDisable|Unused, 0xFD, 0x0400))
+
+	c.Step()
+	if !c.Waiting() {
+		t.Fatal("CPU not waiting after WAI")
+	}
+	c.Run(10)
+	if c.PC() != 0x0401 {
+		t.Errorf("PC moved to $%04X while waiting", c.PC())
+	}
+
+	// A masked IRQ wakes the CPU without taking the interrupt
+	c.SetIRQ(c.NewIRQSource(), true)
+	c.Step()
+	if c.Waiting() {
+		t.Fatal("CPU still waiting after IRQ")
+	}
+	if c.PC() != 0x0402 {
+		t.Errorf("PC = $%04X after waking, want $0402", c.PC())
+	}
+}
+
+func TestSTPHaltsUntilReset(t *testing.T) {
+	// STP
+	bus := &flatBus{}
+	bus.memory[0x0400] = 0xDB
+	c := NewCPU(bus, WithVariant(WDC65C02))
+	c.SetPC(0x0400)
+
+	c.Step()
+	if !c.Halted() {
+		t.Fatal("CPU not halted after STP")
+	}
+
+	c.Reset()
+	if c.Halted() {
+		t.Error("CPU still halted after reset")
+	}
+}
+
+func TestVariantTables(t *testing.T) {
+	for _, tt := range []struct {
+		variant    Variant
+		documented int
+	}{
+		{NMOS6502, 151},
+		{CMOS65C02, 178},
+		{Rockwell65C02, 210},
+		{WDC65C02, 212},
+	} {
+		table := tt.variant.instructions()
+
+		documented := 0
+		for opcode, info := range table {
+			if info.Opcode != uint8(opcode) || info.Execute == nil {
+				t.Errorf("%s: $%02X is missing or listed as $%02X", tt.variant, opcode, info.Opcode)
+			}
+			if InstructionNames[info.Instruction] == "" {
+				t.Errorf("%s: $%02X has no name", tt.variant, opcode)
+			}
+			if info.Documented() {
+				documented++
+			}
+		}
+		if documented != tt.documented {
+			t.Errorf("%s: %d documented opcodes, want %d", tt.variant, documented, tt.documented)
+		}
+	}
+}
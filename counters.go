@@ -0,0 +1,60 @@
+package goemu6502
+
+// --- Counters ---
+// The CPU counts the cycles it has run, the instructions it has executed and
+// the interrupts it has taken since it was created. The counts only ever go
+// up, Reset included, which makes them good for timestamping traces,
+// measuring emulated speed and keeping devices in step with the CPU.
+
+// TotalCycles returns how many cycles the CPU has run since it was created,
+// including cycles spent halted, waiting or held by RDY or DMA
+func (c *CPU) TotalCycles() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.status.totalCycles
+}
+
+// TotalInstructions returns how many instructions the CPU has started since
+// it was created. Interrupts are not instructions, but BRK is.
+func (c *CPU) TotalInstructions() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.status.instructions
+}
+
+// InterruptsServiced returns how many IRQs and NMIs the CPU has taken since it
+// was created
+func (c *CPU) InterruptsServiced() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.status.interrupts
+}
+
+// TotalCycles returns how many cycles the CPU has run since it was created
+func (c *CPU816) TotalCycles() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.status.totalCycles
+}
+
+// TotalInstructions returns how many instructions the CPU has started since
+// it was created
+func (c *CPU816) TotalInstructions() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.status.instructions
+}
+
+// InterruptsServiced returns how many IRQs and NMIs the CPU has taken since it
+// was created
+func (c *CPU816) InterruptsServiced() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.status.interrupts
+}
@@ -0,0 +1,55 @@
+package goemu6502
+
+import "testing"
+
+func TestCounters(t *testing.T) {
+	bothModes(t, func(t *testing.T, options ...Option) {
+		// NOP; LDA #$01; NOP
+		c, _ := newInterruptCPU([]uint8{0xEA, 0xA9, 0x01, 0xEA}, options...)
+		irq := c.NewIRQSource()
+
+		c.Step()
+		c.Step()
+		c.SetIRQ(irq, true)
+		c.Step()
+		c.Step()
+		c.SetIRQ(irq, false)
+		c.Step()
+
+		// NOP, LDA and NOP, then the IRQ and the NOP at its handler
+		if cycles := c.TotalCycles(); cycles != 2+2+2+7+2 {
+			t.Errorf("TotalCycles = %d, want %d", cycles, 2+2+2+7+2)
+		}
+		if instructions := c.TotalInstructions(); instructions != 4 {
+			t.Errorf("TotalInstructions = %d, want 4", instructions)
+		}
+		if interrupts := c.InterruptsServiced(); interrupts != 1 {
+			t.Errorf("InterruptsServiced = %d, want 1", interrupts)
+		}
+
+		// The counters survive a save state
+		restored := NewCPU(&flatBus{}, options...)
+		if err := restored.LoadState(c.SaveState()); err != nil {
+			t.Fatal(err)
+		}
+		if restored.TotalCycles() != c.TotalCycles() || restored.TotalInstructions() != 4 || restored.InterruptsServiced() != 1 {
+			t.Errorf("restored counters %d, %d, %d", restored.TotalCycles(), restored.TotalInstructions(), restored.InterruptsServiced())
+		}
+	})
+}
+
+func TestCounters816(t *testing.T) {
+	// NOP; NOP
+	bus := sparseBus{0x8000: 0xEA, 0x8001: 0xEA, vectorReset: 0x00, vectorReset + 1: 0x80}
+	c := NewCPU816(bus)
+	c.Reset()
+	c.Step()
+	c.Step()
+
+	if instructions := c.TotalInstructions(); instructions != 2 {
+		t.Errorf("TotalInstructions = %d, want 2", instructions)
+	}
+	if cycles := c.TotalCycles(); cycles != 4 {
+		t.Errorf("TotalCycles = %d, want 4", cycles)
+	}
+}
@@ -1,6 +1,7 @@
 package goemu6502
 
 import (
+	"context"
 	"fmt"
 	"sync"
 )
@@ -18,12 +19,29 @@ type (
 		addr_relative uint16 // Absolute address following a branch instruction
 		addr_mode     AddressingMode
 		opcode        uint8 // Current opcode
+
+		// Progress through the current instruction in cycle-exact mode
+		cycle        uint8 // Cycle of the instruction, 1 being the opcode fetch
+		operated     bool  // The instruction function has run
+		interrupting bool  // The instruction is really an IRQ or NMI
+
+		vector      uint16 // Vector of the interrupt or BRK in progress
+		branchDelay bool   // A taken branch that stays on its page skips its last poll
+		disabled    bool   // Interrupt disable flag before the instruction ran
 	}
 
 	InternalStatus struct {
-		Cycles                   uint8
-		currentInstruction       InstructionInfo
-		currentInstructionString string
+		Cycles             uint8
+		currentInstruction InstructionInfo
+		instructionPC      uint16 // Address of the current instruction, disassembled on demand
+		halted             bool   // Set by a JAM opcode, cleared by Reset
+		waiting            bool   // Set by WAI, cleared by an interrupt
+		pollIRQ, pollNMI   bool   // Interrupts polled during the instruction, taken when it ends
+		totalCycles        uint64 // Cycles run since the CPU was created
+		instructions       uint64 // Instructions started since the CPU was created
+		interrupts         uint64 // IRQs and NMIs taken since the CPU was created
+		stolenCycles       uint64 // Cycles the CPU was held by RDY or DMA
+		err                error  // Set when the illegal opcode policy stops the CPU
 	}
 
 	CPU struct {
@@ -32,6 +50,26 @@ type (
 		i      InternalRegisters
 		status InternalStatus
 		bus    Bus
+		magic  uint8               // Magic constant used by the unstable ANE and LXA opcodes
+		policy IllegalOpcodePolicy // What to do about undocumented opcodes
+
+		powerOn PowerOn // What the registers hold when the CPU is created
+
+		cycleExact bool      // Spread the bus accesses of each instruction over its cycles
+		pins       inputPins // The IRQ, NMI, RDY and SO inputs
+
+		variant      Variant               // Which member of the 6502 family this is
+		instructions *[256]InstructionInfo // Instruction table for the variant
+
+		port           *ioPort // The 6510's I/O port, nil on other variants
+		portFadeCycles uint64  // How long floating port inputs hold their value
+
+		peeker       Peeker // How debuggers and disassembly see memory, nil if they can't
+		peekFallback bool   // Peek by reading a bus that doesn't implement Peeker
+
+		hooks     hookRegistry // Hooks watching the CPU run
+		onHalt    func(pc uint16, opcode uint8)
+		onIllegal func(err *IllegalOpcodeError)
 	}
 
 	StatusFlag uint8
@@ -49,12 +87,32 @@ const (
 	Negative                    = 1 << 7
 )
 
-func NewCPU(bus Bus) *CPU {
-	return &CPU{
-		r:   Registers{},
-		i:   InternalRegisters{},
-		bus: bus,
+// NewCPU creates a CPU attached to bus, configured by any options given
+func NewCPU(bus Bus, options ...Option) *CPU {
+	c := &CPU{
+		r:     Registers{},
+		i:     InternalRegisters{},
+		bus:   bus,
+		magic: DefaultMagicConstant,
+
+		portFadeCycles: DefaultPortFadeCycles,
+	}
+
+	for _, option := range options {
+		option(c)
 	}
+
+	c.powerOnRegisters()
+	c.instructions = c.variant.instructions()
+	c.peeker = newPeeker(bus, c.peekFallback)
+
+	// The 6510's I/O port intercepts the bus before anything else sees it
+	if c.variant == MOS6510 {
+		c.port = newIOPort(bus, c)
+		c.bus = c.port
+	}
+
+	return c
 }
 
 func (c *CPU) setFlag(flag StatusFlag, value bool) {
@@ -69,86 +127,285 @@ func (c *CPU) getFlag(flag StatusFlag) bool {
 	return (c.r.p & uint8(flag)) != 0
 }
 
+// Reset runs the reset sequence, which takes 7 cycles like an interrupt.
+// Its three pushes are reads, so nothing is written to the stack, but SP is
+// decremented all the same. Then interrupts are disabled and PC is loaded
+// from the reset vector at $FFFC. A, X and Y keep their values.
+//
+// By default the sequence makes its accesses right away, leaving its cycles
+// to run on the next ticks like any instruction. In cycle-exact mode it runs
+// one access per tick, so PC only holds the reset vector once Step has
+// finished it.
 func (c *CPU) Reset() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	c.r.a = 0x00
-	c.r.x = 0x00
-	c.r.y = 0x00
-	// P == 0x00 | U | I
-	c.r.p = 0x00 | uint8(Unused) | uint8(InterruptDisable)
-	// PC == read from 0xFFFC and 0xFFFD
-	c.r.pc = uint16(c.bus.Read(0xFFFC)) | uint16(c.bus.Read(0xFFFD))<<8
+	// Reset is the only way out of a JAM
+	c.status.halted = false
+	c.status.waiting = false
+	c.status.err = nil
+	c.status.pollIRQ = false
+	c.status.pollNMI = false
+	c.hooks.running = false
+
+	// A pending NMI is forgotten, but the lines stay where the devices put them
+	c.pins.mutex.Lock()
+	c.pins.nmiPending = false
+	c.pins.update()
+	c.pins.mutex.Unlock()
+
+	// The 6510's I/O port goes back to all inputs
+	if c.port != nil {
+		c.port.reset()
+	}
+
+	// Reset goes through the motions of an interrupt
+	c.startInstruction()
+	c.i.interrupting = true
+	c.i.vector = vectorReset
+	c.status.Cycles = 7
+
+	if c.cycleExact {
+		// The sequence starts on the next tick
+		c.i.cycle = 0
+		return
+	}
+
+	c.interrupt()
 }
 
-func (c *CPU) interrupt() {
-	// Push the program counter to the stack
-	c.pushWord(c.r.pc)
+func (c *CPU) Complete() bool {
+	return c.status.Cycles == 0
+}
 
-	// Set the break flag to 0
-	c.setFlag(Break, false)
+// Halted reports whether the CPU has locked up executing a JAM opcode. A
+// halted CPU keeps accepting ticks, but does nothing until it is reset.
+func (c *CPU) Halted() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-	// Push the processor status to the stack
-	c.setFlag(Unused, true)
-	c.setFlag(Break, true)
-	c.setFlag(InterruptDisable, true)
-	c.pushByte(c.r.p)
-	c.setFlag(InterruptDisable, false)
+	return c.status.halted
+}
 
-	// Set the program counter to the interrupt vector
-	c.r.pc = uint16(c.bus.Read(0xFFFE)) | uint16(c.bus.Read(0xFFFF))<<8
+// Waiting reports whether the CPU is waiting for an interrupt after a 65C02 WAI
+func (c *CPU) Waiting() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-	// Set cycles to 7
-	c.status.Cycles = 7
+	return c.status.waiting
 }
 
-func Irq(c *CPU) {
-	// If the InterruptDisable flag is not set, push the pc and p to the stack
-	if !c.getFlag(InterruptDisable) {
-		c.interrupt()
-	}
+// OnHalt registers a function to call when the CPU executes a JAM opcode, or
+// STP on the WDC 65C02. It
+// receives the address and value of the opcode. The handler runs while the
+// CPU is locked, so it must not call back into the CPU.
+func (c *CPU) OnHalt(handler func(pc uint16, opcode uint8)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.onHalt = handler
 }
 
-func Nmi(c *CPU) {
-	// This is a non-maskable interrupt
-	c.interrupt()
+// Tick advances the CPU by a single clock cycle
+func (c *CPU) Tick() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.tick()
 }
 
-func (c *CPU) Complete() bool {
-	return c.status.Cycles == 0
+// Step executes exactly one instruction and returns the number of cycles it
+// took. If an instruction is already in progress, Step finishes it instead.
+//
+// While RDY or DMA holds the CPU, Step returns on the first cycle it is held,
+// with the cycles spent so far including that one. No instruction is
+// completed in that case, and Complete reports false if one was left part way
+// through; it carries on with the next Tick or Step once the CPU is released.
+func (c *CPU) Step() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.step()
 }
 
-func (c *CPU) Tick() {
+// Run executes whole instructions until at least the given number of cycles
+// has been spent, and returns by how many cycles the budget was overshot.
+// Subtract the overshoot from the next budget to stay in sync with other
+// devices, e.g.
+//
+//	overshoot = cpu.Run(cyclesPerFrame - overshoot)
+//
+// A budget of zero or less runs nothing, and returns the overshoot that is
+// still left over, -cycles.
+func (c *CPU) Run(cycles int) int {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if c.status.Cycles == 0 {
-		c.status.currentInstructionString = c.DisassembleAt(c.r.pc)
+	spent := 0
+	for spent < cycles {
+		spent += c.step()
+	}
 
-		// Fetch the next instruction
-		c.status.currentInstruction = Instructions[c.bus.Read(c.r.pc)]
-		c.i.opcode = c.status.currentInstruction.Opcode
-		c.r.pc++
+	return spent - cycles
+}
+
+// TryStep executes exactly one instruction like Step. If the illegal opcode
+// policy is IllegalError and the instruction is illegal, nothing is executed
+// and an *IllegalOpcodeError is returned instead.
+func (c *CPU) TryStep() (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.status.err = nil
+	cycles := c.step()
+	return cycles, c.status.err
+}
 
-		// Get the number of cycles for the instruction
-		c.status.Cycles = c.status.currentInstruction.Cycles
+// RunUntil executes whole instructions until predicate returns true or ctx is
+// cancelled, and returns the number of cycles spent. The predicate is checked
+// before every instruction, so it is free to use the public register API. It
+// also stops at an illegal opcode when the policy is IllegalError, returning
+// an *IllegalOpcodeError.
+func (c *CPU) RunUntil(ctx context.Context, predicate func(*CPU) bool) (int, error) {
+	spent := 0
+	done := ctx.Done()
+
+	for !predicate(c) {
+		select {
+		case <-done:
+			return spent, ctx.Err()
+		default:
+		}
+
+		cycles, err := c.TryStep()
+		spent += cycles
+		if err != nil {
+			return spent, err
+		}
+	}
+
+	return spent, nil
+}
+
+func (c *CPU) step() int {
+	cycles := 0
+	for {
+		stolen := c.status.stolenCycles
+		c.tick()
+		cycles++
+
+		// Held cycles don't advance the instruction, so don't wait for it
+		if c.status.Cycles == 0 || c.status.stolenCycles != stolen {
+			return cycles
+		}
+	}
+}
 
-		// Get the addressing mode
-		c.i.addr_mode = c.status.currentInstruction.Mode
+func (c *CPU) tick() {
+	c.status.totalCycles++
 
-		// Get the address of the data that the instruction will operate on
-		var extraCycles = c.executeAddressingMode(c.i.addr_mode)
+	// SO works on the flag directly, whatever the CPU is doing
+	c.setOverflow()
 
-		// Now execute the instruction
-		extraCycles += c.status.currentInstruction.Execute(c)
+	// An interrupt wakes a CPU waiting after WAI
+	if c.status.waiting && !c.status.halted {
+		c.wake()
+	}
+
+	// A halted or waiting CPU burns cycles without touching the bus
+	if c.status.halted || c.status.waiting {
+		if c.status.Cycles > 0 {
+			c.status.Cycles--
+		}
+		c.idleDMA()
+		if c.hooks.active {
+			c.afterInstruction()
+		}
+		return
+	}
+
+	// RDY and DMA hold the CPU without it doing anything at all
+	if c.held() {
+		return
+	}
 
-		// Add any extra cycles
-		c.status.Cycles += extraCycles
+	if c.cycleExact {
+		c.tickExact()
+		c.pollInterrupts()
+		if c.hooks.active {
+			c.afterInstruction()
+		}
+		return
+	}
+
+	c.i.cycle++
+
+	if c.status.Cycles == 0 {
+		c.startInstruction()
+
+		// An interrupt polled by the last instruction goes first
+		if c.startInterrupt() {
+			c.interrupt()
+			c.status.Cycles--
+			c.pollInterrupts()
+			return
+		}
+
+		// Fetch the next instruction
+		if !c.fetchInstruction() {
+			// Only the fetch happened
+			c.status.Cycles = 0
+			return
+		}
+		if c.hooks.active {
+			c.beforeInstruction()
+		}
+
+		// Get the address of the data that the instruction will operate on,
+		// noting whether a page boundary was crossed on the way
+		var addrCycles = c.executeAddressingMode(c.i.addr_mode)
+
+		// Now execute the instruction, which tells us whether it pays
+		// for a page crossing
+		var opCycles = c.status.currentInstruction.Execute(c)
+
+		// Add the extra cycle only if both need it
+		c.status.Cycles += addrCycles & opCycles
 	}
 
 	// Decrement the number of cycles
 	c.status.Cycles--
+
+	c.pollInterrupts()
+	if c.hooks.active {
+		c.afterInstruction()
+	}
+}
+
+// fetchInstruction reads the opcode at PC and decodes it, leaving the
+// instruction's cycle count in Cycles. It returns false if the illegal opcode
+// policy says not to execute it.
+func (c *CPU) fetchInstruction() bool {
+	c.status.instructionPC = c.r.pc
+	c.status.currentInstruction = c.instructions[c.bus.Read(c.r.pc)]
+	c.i.opcode = c.status.currentInstruction.Opcode
+	c.r.pc++
+
+	// Undocumented opcodes are up to the illegal opcode policy
+	if c.policy != IllegalExecute && !c.status.currentInstruction.Documented() {
+		if !c.illegalOpcode() {
+			return false
+		}
+	}
+
+	// Get the number of cycles for the instruction
+	c.status.Cycles = c.status.currentInstruction.Cycles
+
+	// Get the addressing mode
+	c.i.addr_mode = c.status.currentInstruction.Mode
+
+	c.status.instructions++
+	return true
 }
 
 func (c *CPU) executeAddressingMode(mode AddressingMode) uint8 {
@@ -182,6 +439,12 @@ func (c *CPU) executeAddressingMode(mode AddressingMode) uint8 {
 		return c.indirectIndexed()
 	case Relative:
 		return c.relative()
+	case ZeroPageIndirect:
+		return c.zeroPageIndirect()
+	case AbsoluteIndexedIndirect:
+		return c.absoluteIndexedIndirect()
+	case ZeroPageRelative:
+		return c.zeroPageRelative()
 	default:
 		return 0
 	}
@@ -193,41 +456,57 @@ func (c *CPU) getOperandString(mode AddressingMode, address uint16) string {
 	case Implied:
 		return ""
 	case Immediate:
-		return fmt.Sprintf("#$%02X", c.bus.Read(address))
+		return fmt.Sprintf("#$%02X", c.peekByte(address))
 	case ZeroPage:
-		return fmt.Sprintf("$%02X", c.bus.Read(address))
+		return fmt.Sprintf("$%02X", c.peekByte(address))
 	case ZeroPageX:
-		return fmt.Sprintf("$%02X,X", c.bus.Read(address))
+		return fmt.Sprintf("$%02X,X", c.peekByte(address))
 	case ZeroPageY:
-		return fmt.Sprintf("$%02X,Y", c.bus.Read(address))
+		return fmt.Sprintf("$%02X,Y", c.peekByte(address))
 	case Absolute:
-		var temp uint16 = uint16(c.bus.Read(address)) | (uint16(c.bus.Read(address+1)) << 8)
+		var temp uint16 = uint16(c.peekByte(address)) | (uint16(c.peekByte(address+1)) << 8)
 		return fmt.Sprintf("$%04X", temp)
 	case AbsoluteX:
 		// We want the next two bytes
-		var temp uint16 = uint16(c.bus.Read(address)) | (uint16(c.bus.Read(address+1)) << 8)
+		var temp uint16 = uint16(c.peekByte(address)) | (uint16(c.peekByte(address+1)) << 8)
 		return fmt.Sprintf("$%04X,X", temp)
 	case AbsoluteY:
-		var temp uint16 = uint16(c.bus.Read(address)) | (uint16(c.bus.Read(address+1)) << 8)
+		var temp uint16 = uint16(c.peekByte(address)) | (uint16(c.peekByte(address+1)) << 8)
 		return fmt.Sprintf("$%04X,Y", temp)
 	case Indirect:
-		var temp uint16 = uint16(c.bus.Read(address)) | (uint16(c.bus.Read(address+1)) << 8)
+		var temp uint16 = uint16(c.peekByte(address)) | (uint16(c.peekByte(address+1)) << 8)
 		return fmt.Sprintf("($%04X)", temp)
 	case IndexedIndirect:
-		return fmt.Sprintf("($%02X,X)", c.bus.Read(address))
+		return fmt.Sprintf("($%02X,X)", c.peekByte(address))
 	case IndirectIndexed:
-		return fmt.Sprintf("($%02X),Y", c.bus.Read(address))
+		return fmt.Sprintf("($%02X),Y", c.peekByte(address))
 	case Relative:
-		var temp uint16 = uint16(c.bus.Read(address)) | (uint16(c.bus.Read(address+1)) << 8)
-		return fmt.Sprintf("$%04X", temp)
+		// The branch target is relative to the end of the instruction
+		var target uint16 = address + 1 + uint16(int8(c.peekByte(address)))
+		return fmt.Sprintf("$%04X", target)
+	case ZeroPageIndirect:
+		return fmt.Sprintf("($%02X)", c.peekByte(address))
+	case AbsoluteIndexedIndirect:
+		var temp uint16 = uint16(c.peekByte(address)) | (uint16(c.peekByte(address+1)) << 8)
+		return fmt.Sprintf("($%04X,X)", temp)
+	case ZeroPageRelative:
+		// The branch target is relative to the end of the instruction
+		var target uint16 = address + 2 + uint16(int8(c.peekByte(address+1)))
+		return fmt.Sprintf("$%02X,$%04X", c.peekByte(address), target)
 	default:
 		return ""
 	}
 }
 
+// DisassembleAt disassembles the instruction at addr without side effects.
+// It returns "???" if the bus can't be peeked.
 func (c *CPU) DisassembleAt(addr uint16) string {
-	var opcode uint8 = c.bus.Read(addr)
-	var instruction InstructionInfo = Instructions[opcode]
+	if c.peeker == nil {
+		return unpeekable
+	}
+
+	var opcode uint8 = c.peekByte(addr)
+	var instruction InstructionInfo = c.instructions[opcode]
 	var addrMode AddressingMode = instruction.Mode
 	var addrString string = c.getOperandString(addrMode, addr+1)
 	var insn = InstructionNames[instruction.Instruction]
@@ -235,8 +514,9 @@ func (c *CPU) DisassembleAt(addr uint16) string {
 }
 
 func (c *CPU) fetchByte() uint8 {
-	// If the addressing mode is not implied or accumulator, read the data
-	if c.i.addr_mode != Implied && c.i.addr_mode != Accumulator {
+	// If the addressing mode is not implied or accumulator, read the data.
+	// In cycle-exact mode, it was read on a cycle of its own.
+	if c.i.addr_mode != Implied && c.i.addr_mode != Accumulator && !c.cycleExact {
 		c.i.fetched = c.bus.Read(c.i.addr_absolute)
 	}
 	return c.i.fetched
@@ -263,7 +543,16 @@ func (c *CPU) popWord() uint16 {
 	return high<<8 | low
 }
 
+// currentInstructionString disassembles the current instruction, which is
+// only worth doing when someone wants to see it
+func (c *CPU) currentInstructionString() string {
+	if c.i.interrupting {
+		return interruptNames[c.i.vector]
+	}
+	return c.DisassembleAt(c.status.instructionPC)
+}
+
 func (c *CPU) String() string {
 	return fmt.Sprintf("Current Instruction: %s\nA: %02X X: %02X Y: %02X P: %02X SP: %02X PC: %04X\n",
-		c.status.currentInstructionString, c.r.a, c.r.x, c.r.y, c.r.p, c.r.sp, c.r.pc)
+		c.currentInstructionString(), c.r.a, c.r.x, c.r.y, c.r.p, c.r.sp, c.r.pc)
 }
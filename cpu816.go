@@ -0,0 +1,527 @@
+package goemu6502
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type (
+	// Bus24 is the 65C816's 24-bit counterpart of Bus: 256 banks of 64K
+	Bus24 interface {
+		Read(addr uint32) uint8
+		Write(addr uint32, value uint8)
+	}
+
+	// Registers816 holds the 65C816's registers. A is the full 16-bit
+	// accumulator (B in the high byte); with the M flag set only its low byte
+	// takes part in arithmetic, and with the X flag set the high bytes of X and
+	// Y are zero.
+	Registers816 struct {
+		a, x, y, sp, d uint16
+		pc             uint16
+		pbr, dbr       uint8
+		p              uint8
+		e              bool
+	}
+
+	InternalRegisters816 struct {
+		addr_absolute uint32 // All used memory addresses end up here
+		addr_relative uint16 // Branch offset
+		wrap_bank     bool   // The second byte of a word at addr_absolute wraps within its bank
+		temp          uint16 // Temporary value
+		addr_mode     AddressingMode
+		opcode        uint8 // Current opcode
+	}
+
+	InternalStatus816 struct {
+		Cycles             uint8
+		currentInstruction InstructionInfo816
+		halted             bool   // Set by STP, cleared by Reset
+		waiting            bool   // Set by WAI, cleared by an interrupt
+		totalCycles        uint64 // Cycles run since the CPU was created
+		instructions       uint64 // Instructions started since the CPU was created
+		interrupts         uint64 // IRQs and NMIs taken since the CPU was created
+	}
+
+	// CPU816 is a WDC 65C816. It shares the instruction decoding approach of
+	// CPU, but has its own registers, 24-bit bus and instruction table.
+	CPU816 struct {
+		mutex  sync.Mutex
+		r      Registers816
+		i      InternalRegisters816
+		status InternalStatus816
+		bus    Bus24
+		peeker Peeker24  // How debuggers and disassembly see memory, nil if they can't
+		pins   inputPins // The IRQ and NMI inputs
+
+		onHalt func(pc uint32, opcode uint8)
+	}
+)
+
+const (
+	// IndexWidth (X) selects 8-bit index registers in native mode. It is the
+	// bit used for Break in emulation mode.
+	IndexWidth = Break
+
+	// MemoryWidth (M) selects an 8-bit accumulator and memory accesses in
+	// native mode. It is the unused bit in emulation mode.
+	MemoryWidth = Unused
+)
+
+// 65C816 interrupt vectors. Native mode has its own set, including one for
+// BRK, which shares the IRQ vector in emulation mode.
+const (
+	vectorNativeCOP     = 0xFFE4
+	vectorNativeBRK     = 0xFFE6
+	vectorNativeNMI     = 0xFFEA
+	vectorNativeIRQ     = 0xFFEE
+	vectorEmulationCOP  = 0xFFF4
+	vectorEmulationNMI  = 0xFFFA
+	vectorReset         = 0xFFFC
+	vectorEmulationIRQ  = 0xFFFE
+	vectorEmulationBRK  = vectorEmulationIRQ
+	emulationStackPage  = 0x0100
+	emulationStatusBits = IndexWidth | MemoryWidth
+)
+
+// NewCPU816 creates a 65C816 attached to bus. Call Reset before running it.
+func NewCPU816(bus Bus24) *CPU816 {
+	c := &CPU816{
+		r:   Registers816{e: true, p: emulationStatusBits, sp: 0x01FF},
+		bus: bus,
+	}
+	c.peeker, _ = bus.(Peeker24)
+	return c
+}
+
+// Reset puts the CPU in emulation mode and jumps through the reset vector
+func (c *CPU816) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.status.halted = false
+	c.status.waiting = false
+	c.status.Cycles = 0
+
+	// A pending NMI is forgotten, but the lines stay where the devices put them
+	c.pins.takeNMI()
+
+	// Back to emulation mode with 8-bit registers, page 1 stack and direct
+	// page and banks at 0
+	c.r.e = true
+	c.r.p = uint8(InterruptDisable) | emulationStatusBits
+	c.r.x &= 0x00FF
+	c.r.y &= 0x00FF
+	c.r.sp = emulationStackPage | 0xFD
+	c.r.d = 0x0000
+	c.r.dbr = 0x00
+	c.r.pbr = 0x00
+
+	c.r.pc = c.readWord(vectorReset)
+}
+
+// Halted reports whether the CPU has stopped after STP
+func (c *CPU816) Halted() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.status.halted
+}
+
+// Waiting reports whether the CPU is waiting for an interrupt after WAI
+func (c *CPU816) Waiting() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.status.waiting
+}
+
+// OnHalt registers a function to call when the CPU executes STP. It receives
+// the 24-bit address and value of the opcode. The handler runs while the CPU
+// is locked, so it must not call back into the CPU.
+func (c *CPU816) OnHalt(handler func(pc uint32, opcode uint8)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.onHalt = handler
+}
+
+// Complete reports whether the current instruction has finished
+func (c *CPU816) Complete() bool {
+	return c.status.Cycles == 0
+}
+
+// Tick advances the CPU by a single clock cycle
+func (c *CPU816) Tick() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.tick()
+}
+
+// Step executes exactly one instruction and returns the number of cycles it
+// took. If an instruction is already in progress, Step finishes it instead.
+func (c *CPU816) Step() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.step()
+}
+
+// Run executes whole instructions until at least the given number of cycles
+// has been spent, and returns by how many cycles the budget was overshot, like
+// CPU.Run.
+func (c *CPU816) Run(cycles int) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	spent := 0
+	for spent < cycles {
+		spent += c.step()
+	}
+
+	return spent - cycles
+}
+
+// RunUntil executes whole instructions until predicate returns true or ctx is
+// cancelled, and returns the number of cycles spent, like CPU.RunUntil.
+func (c *CPU816) RunUntil(ctx context.Context, predicate func(*CPU816) bool) (int, error) {
+	spent := 0
+	done := ctx.Done()
+
+	for !predicate(c) {
+		select {
+		case <-done:
+			return spent, ctx.Err()
+		default:
+		}
+
+		spent += c.Step()
+	}
+
+	return spent, nil
+}
+
+func (c *CPU816) step() int {
+	cycles := 0
+	for {
+		c.tick()
+		cycles++
+
+		if c.status.Cycles == 0 {
+			return cycles
+		}
+	}
+}
+
+func (c *CPU816) tick() {
+	c.status.totalCycles++
+
+	// Any interrupt wakes a CPU waiting in WAI, even an IRQ it then ignores
+	if c.status.waiting && c.status.Cycles == 0 {
+		if nmi, irq := c.pins.sample(); nmi || irq {
+			c.status.waiting = false
+		}
+	}
+
+	// A stopped or waiting CPU burns cycles without touching the bus
+	if c.status.halted || c.status.waiting {
+		if c.status.Cycles > 0 {
+			c.status.Cycles--
+		}
+		return
+	}
+
+	if c.status.Cycles == 0 {
+		// The inputs are sampled between instructions. A pending NMI comes
+		// first; the IRQ line is taken for as long as it is asserted and
+		// interrupts are enabled.
+		nmi, irq := c.pins.sample()
+		switch {
+		case nmi:
+			c.pins.takeNMI()
+			c.status.interrupts++
+			c.interrupt(vectorNativeNMI, vectorEmulationNMI, false)
+		case irq && !c.getFlag(InterruptDisable):
+			c.status.interrupts++
+			c.interrupt(vectorNativeIRQ, vectorEmulationIRQ, false)
+		default:
+			c.execute()
+		}
+	}
+
+	// Decrement the number of cycles
+	c.status.Cycles--
+}
+
+// execute fetches and runs the next instruction, leaving its cycle count in
+// Cycles
+func (c *CPU816) execute() {
+	// Fetch the next instruction
+	c.status.currentInstruction = Instructions816[c.fetch()]
+	c.i.opcode = c.status.currentInstruction.Opcode
+	c.status.instructions++
+
+	// Get the number of cycles for the instruction
+	c.status.Cycles = c.status.currentInstruction.Cycles
+
+	// Get the address of the data that the instruction will operate on,
+	// noting whether indexing costs an extra cycle
+	c.i.addr_mode = c.status.currentInstruction.Mode
+	var addrCycles = c.executeAddressingMode(c.i.addr_mode)
+
+	// Now execute the instruction, which tells us whether it pays for the
+	// indexing
+	var opCycles = c.status.currentInstruction.Execute(c)
+
+	// Add the extra cycle only if both need it
+	c.status.Cycles += addrCycles & opCycles
+}
+
+// interrupt pushes the return address and status and jumps through the
+// vector for the current mode. In native mode the program bank is pushed
+// too, which costs a cycle.
+func (c *CPU816) interrupt(native, emulation uint16, brk bool) {
+	c.status.Cycles = 7
+
+	var vector uint16 = emulation
+	if !c.r.e {
+		vector = native
+		c.pushByte(c.r.pbr)
+		c.status.Cycles++
+	}
+	c.pushWord(c.r.pc)
+
+	// In emulation mode, the pushed break flag tells BRK from IRQ
+	var p uint8 = c.r.p
+	if c.r.e && !brk {
+		p &^= uint8(Break)
+	}
+	c.pushByte(p)
+
+	c.setFlag(InterruptDisable, true)
+	c.setFlag(Decimal, false)
+
+	c.r.pbr = 0x00
+	c.r.pc = c.readWord(uint32(vector))
+}
+
+func (c *CPU816) setFlag(flag StatusFlag, value bool) {
+	if value {
+		c.r.p |= uint8(flag)
+	} else {
+		c.r.p &= ^uint8(flag)
+	}
+}
+
+func (c *CPU816) getFlag(flag StatusFlag) bool {
+	return (c.r.p & uint8(flag)) != 0
+}
+
+// setP sets the processor status register, keeping M and X set in emulation
+// mode and clearing the high bytes of the index registers when X is set
+func (c *CPU816) setP(value uint8) {
+	if c.r.e {
+		value |= emulationStatusBits
+	}
+	c.r.p = value
+
+	if c.r.p&IndexWidth != 0 {
+		c.r.x &= 0x00FF
+		c.r.y &= 0x00FF
+	}
+}
+
+// m16 reports whether the accumulator and memory accesses are 16 bits wide
+func (c *CPU816) m16() bool {
+	return c.r.p&MemoryWidth == 0
+}
+
+// x16 reports whether the index registers are 16 bits wide
+func (c *CPU816) x16() bool {
+	return c.r.p&IndexWidth == 0
+}
+
+// setNZ sets the zero and negative flags from an 8 or 16-bit value
+func (c *CPU816) setNZ(value uint16, wide bool) {
+	if !wide {
+		value &= 0x00FF
+		c.setFlag(Negative, value&0x80 != 0)
+	} else {
+		c.setFlag(Negative, value&0x8000 != 0)
+	}
+	c.setFlag(Zero, value == 0)
+}
+
+func (c *CPU816) read(addr uint32) uint8 {
+	return c.bus.Read(addr & 0xFFFFFF)
+}
+
+func (c *CPU816) write(addr uint32, value uint8) {
+	c.bus.Write(addr&0xFFFFFF, value)
+}
+
+// readWord reads a little endian word from bank 0, e.g. a vector or pointer
+func (c *CPU816) readWord(addr uint32) uint16 {
+	return uint16(c.read(addr&0xFFFF)) | uint16(c.read((addr+1)&0xFFFF))<<8
+}
+
+// fetch reads the next byte of the instruction stream
+func (c *CPU816) fetch() uint8 {
+	var value uint8 = c.read(uint32(c.r.pbr)<<16 | uint32(c.r.pc))
+	c.r.pc++
+	return value
+}
+
+// fetchWord reads the next two bytes of the instruction stream
+func (c *CPU816) fetchWord() uint16 {
+	var low uint16 = uint16(c.fetch())
+	return uint16(c.fetch())<<8 | low
+}
+
+// next returns the address of the byte after addr, which wraps within the
+// bank for direct page and stack accesses
+func (c *CPU816) next(addr uint32) uint32 {
+	if c.i.wrap_bank {
+		return addr&0xFF0000 | (addr+1)&0x00FFFF
+	}
+	return (addr + 1) & 0xFFFFFF
+}
+
+// load reads the operand at the effective address. A 16-bit operand takes an
+// extra cycle.
+func (c *CPU816) load(wide bool) uint16 {
+	var value uint16 = uint16(c.read(c.i.addr_absolute))
+	if wide {
+		value |= uint16(c.read(c.next(c.i.addr_absolute))) << 8
+		c.status.Cycles++
+	}
+	return value
+}
+
+// store writes the operand at the effective address. A 16-bit operand takes
+// an extra cycle.
+func (c *CPU816) store(value uint16, wide bool) {
+	c.write(c.i.addr_absolute, uint8(value))
+	if wide {
+		c.write(c.next(c.i.addr_absolute), uint8(value>>8))
+		c.status.Cycles++
+	}
+}
+
+func (c *CPU816) pushByte(data uint8) {
+	c.write(uint32(c.r.sp), data)
+	c.r.sp--
+
+	// The emulation mode stack stays in page 1
+	if c.r.e {
+		c.r.sp = emulationStackPage | c.r.sp&0x00FF
+	}
+}
+
+func (c *CPU816) pushWord(data uint16) {
+	c.pushByte(uint8(data >> 8))
+	c.pushByte(uint8(data))
+}
+
+func (c *CPU816) popByte() uint8 {
+	c.r.sp++
+
+	// The emulation mode stack stays in page 1
+	if c.r.e {
+		c.r.sp = emulationStackPage | c.r.sp&0x00FF
+	}
+
+	return c.read(uint32(c.r.sp))
+}
+
+func (c *CPU816) popWord() uint16 {
+	var low uint16 = uint16(c.popByte())
+	var high uint16 = uint16(c.popByte())
+	return high<<8 | low
+}
+
+// DisassembleAt disassembles the instruction at a 24-bit address without side
+// effects, sizing immediate operands by the current M and X flags. It returns
+// "???" if the bus can't be peeked.
+func (c *CPU816) DisassembleAt(addr uint32) string {
+	if c.peeker == nil {
+		return unpeekable
+	}
+	peek := func(addr uint32) uint8 {
+		return c.peeker.Peek(addr & 0xFFFFFF)
+	}
+
+	var instruction InstructionInfo816 = Instructions816[peek(addr)]
+	var insn = InstructionNames[instruction.Instruction]
+
+	operand := func(n int) uint32 {
+		var value uint32
+		for i := 0; i < n; i++ {
+			value |= uint32(peek(addr+1+uint32(i))) << (8 * i)
+		}
+		return value
+	}
+
+	var operandString string
+	switch instruction.Mode {
+	case Immediate:
+		if c.immediateWide(instruction.Instruction) {
+			operandString = fmt.Sprintf("#$%04X", operand(2))
+		} else {
+			operandString = fmt.Sprintf("#$%02X", operand(1))
+		}
+	case ZeroPage:
+		operandString = fmt.Sprintf("$%02X", operand(1))
+	case ZeroPageX:
+		operandString = fmt.Sprintf("$%02X,X", operand(1))
+	case ZeroPageY:
+		operandString = fmt.Sprintf("$%02X,Y", operand(1))
+	case ZeroPageIndirect:
+		operandString = fmt.Sprintf("($%02X)", operand(1))
+	case IndexedIndirect:
+		operandString = fmt.Sprintf("($%02X,X)", operand(1))
+	case IndirectIndexed:
+		operandString = fmt.Sprintf("($%02X),Y", operand(1))
+	case DirectIndirectLong:
+		operandString = fmt.Sprintf("[$%02X]", operand(1))
+	case DirectIndirectLongY:
+		operandString = fmt.Sprintf("[$%02X],Y", operand(1))
+	case StackRelative:
+		operandString = fmt.Sprintf("$%02X,S", operand(1))
+	case StackRelativeIndirectY:
+		operandString = fmt.Sprintf("($%02X,S),Y", operand(1))
+	case Absolute:
+		operandString = fmt.Sprintf("$%04X", operand(2))
+	case AbsoluteX:
+		operandString = fmt.Sprintf("$%04X,X", operand(2))
+	case AbsoluteY:
+		operandString = fmt.Sprintf("$%04X,Y", operand(2))
+	case Indirect:
+		operandString = fmt.Sprintf("($%04X)", operand(2))
+	case AbsoluteIndexedIndirect:
+		operandString = fmt.Sprintf("($%04X,X)", operand(2))
+	case AbsoluteIndirectLong:
+		operandString = fmt.Sprintf("[$%04X]", operand(2))
+	case AbsoluteLong:
+		operandString = fmt.Sprintf("$%06X", operand(3))
+	case AbsoluteLongX:
+		operandString = fmt.Sprintf("$%06X,X", operand(3))
+	case Relative:
+		operandString = fmt.Sprintf("$%04X", uint16(addr)+2+uint16(int8(operand(1))))
+	case RelativeLong:
+		operandString = fmt.Sprintf("$%04X", uint16(addr)+3+uint16(operand(2)))
+	case BlockMove:
+		// The destination bank comes first in memory, but last in source
+		operandString = fmt.Sprintf("$%02X,$%02X", operand(2)>>8, operand(1))
+	}
+
+	return fmt.Sprintf("%s %s", insn, operandString)
+}
+
+func (c *CPU816) String() string {
+	var pc uint32 = uint32(c.r.pbr)<<16 | uint32(c.r.pc)
+	return fmt.Sprintf("Next Instruction: %s\nA: %04X X: %04X Y: %04X P: %02X SP: %04X D: %04X DBR: %02X PC: %06X E: %t\n",
+		c.DisassembleAt(pc), c.r.a, c.r.x, c.r.y, c.r.p, c.r.sp, c.r.d, c.r.dbr, pc, c.r.e)
+}
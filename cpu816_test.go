@@ -0,0 +1,362 @@
+package goemu6502
+
+import "testing"
+
+// sparseBus is a Bus24 that only stores the bytes written to it
+type sparseBus map[uint32]uint8
+
+func (b sparseBus) Read(addr uint32) uint8 {
+	return b[addr]
+}
+
+func (b sparseBus) Write(addr uint32, value uint8) {
+	b[addr] = value
+}
+
+func (b sparseBus) Peek(addr uint32) uint8 {
+	return b[addr]
+}
+
+// cpu816Test runs a program at $00:8000 until the program counter reaches its
+// end, or for a number of steps if it jumps away
+type cpu816Test struct {
+	name       string
+	program    []uint8
+	registers  Registers816
+	memory     map[uint32]uint8
+	steps      int
+	want       Registers816
+	wantMemory map[uint32]uint8
+	wantCycles int
+}
+
+func (tt cpu816Test) run(t *testing.T) {
+	t.Helper()
+
+	bus := sparseBus{}
+	for i, value := range tt.program {
+		bus[0x8000+uint32(i)] = value
+	}
+	for addr, value := range tt.memory {
+		bus[addr] = value
+	}
+
+	c := NewCPU816(bus)
+	r := tt.registers
+	r.pc = 0x8000
+	c.SetRegisters(r)
+
+	var end uint16 = 0x8000 + uint16(len(tt.program))
+	cycles := 0
+	for steps := 0; steps < 100; steps++ {
+		if tt.steps != 0 && steps == tt.steps || tt.steps == 0 && c.r.pbr == 0 && c.r.pc == end {
+			break
+		}
+		cycles += c.Step()
+	}
+
+	if got := c.Registers(); got != tt.want {
+		t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+	}
+	for addr, value := range tt.wantMemory {
+		if bus[addr] != value {
+			t.Errorf("%s: $%06X = %02X, want %02X", tt.name, addr, bus[addr], value)
+		}
+	}
+	if tt.wantCycles != 0 && cycles != tt.wantCycles {
+		t.Errorf("%s: took %d cycles, want %d", tt.name, cycles, tt.wantCycles)
+	}
+}
+
+func TestCPU816Instructions(t *testing.T) {
+	for _, tt := range []cpu816Test{
+		{name: "CLC XCE", program: []uint8{0x18, 0xFB},
+			registers:  Registers816{p: 0x35, sp: 0x01FF, e: true},
+			want:       Registers816{p: 0x35, sp: 0x01FF, pc: 0x8002},
+			wantCycles: 4},
+		{name: "SEC XCE", program: []uint8{0x38, 0xFB},
+			registers:  Registers816{x: 0x1234, p: 0x00, sp: 0x1FF0},
+			want:       Registers816{x: 0x0034, p: 0x30, sp: 0x01F0, pc: 0x8002, e: true},
+			wantCycles: 4},
+		{name: "REP #$30 LDA #$1234", program: []uint8{0xC2, 0x30, 0xA9, 0x34, 0x12},
+			registers:  Registers816{p: 0x30, sp: 0x01FF},
+			want:       Registers816{a: 0x1234, p: 0x00, sp: 0x01FF, pc: 0x8005},
+			wantCycles: 6},
+		{name: "SEP #$10 clears XH and YH", program: []uint8{0xE2, 0x10},
+			registers:  Registers816{x: 0x1234, y: 0xABCD, sp: 0x01FF},
+			want:       Registers816{x: 0x0034, y: 0x00CD, p: 0x10, sp: 0x01FF, pc: 0x8002},
+			wantCycles: 3},
+		{name: "LDA long", program: []uint8{0xAF, 0x56, 0x34, 0x12},
+			registers:  Registers816{a: 0xAB00, p: 0x30, sp: 0x01FF},
+			memory:     map[uint32]uint8{0x123456: 0x99},
+			want:       Registers816{a: 0xAB99, p: 0xB0, sp: 0x01FF, pc: 0x8004},
+			wantCycles: 5},
+		{name: "LDA long,X 16-bit", program: []uint8{0xBF, 0x00, 0x00, 0x7E},
+			registers:  Registers816{x: 0x0010, sp: 0x01FF},
+			memory:     map[uint32]uint8{0x7E0010: 0x34, 0x7E0011: 0x12},
+			want:       Registers816{a: 0x1234, x: 0x0010, sp: 0x01FF, pc: 0x8004},
+			wantCycles: 6},
+		{name: "LDA abs uses DBR", program: []uint8{0xAD, 0x00, 0x20},
+			registers:  Registers816{p: 0x30, sp: 0x01FF, dbr: 0x7E},
+			memory:     map[uint32]uint8{0x7E2000: 0x42, 0x002000: 0x99},
+			want:       Registers816{a: 0x0042, p: 0x30, sp: 0x01FF, dbr: 0x7E, pc: 0x8003},
+			wantCycles: 4},
+		{name: "LDA dp with D not page aligned", program: []uint8{0xA5, 0x10},
+			registers:  Registers816{p: 0x30, sp: 0x01FF, d: 0x0101},
+			memory:     map[uint32]uint8{0x0111: 0x77},
+			want:       Registers816{a: 0x0077, p: 0x30, sp: 0x01FF, d: 0x0101, pc: 0x8002},
+			wantCycles: 4},
+		{name: "LDA [dp],Y", program: []uint8{0xB7, 0x10},
+			registers:  Registers816{y: 0x0001, p: 0x30, sp: 0x01FF},
+			memory:     map[uint32]uint8{0x10: 0xFF, 0x11: 0xFF, 0x12: 0x7E, 0x7F0000: 0x5A},
+			want:       Registers816{a: 0x005A, y: 0x0001, p: 0x30, sp: 0x01FF, pc: 0x8002},
+			wantCycles: 6},
+		{name: "STA sr,S", program: []uint8{0x83, 0x03},
+			registers:  Registers816{a: 0x0042, p: 0x30, sp: 0x1FF0},
+			want:       Registers816{a: 0x0042, p: 0x30, sp: 0x1FF0, pc: 0x8002},
+			wantMemory: map[uint32]uint8{0x1FF3: 0x42},
+			wantCycles: 4},
+		{name: "LDA (sr,S),Y", program: []uint8{0xB3, 0x01},
+			registers:  Registers816{y: 0x0002, p: 0x30, sp: 0x01F0, dbr: 0x7E},
+			memory:     map[uint32]uint8{0x01F1: 0x00, 0x01F2: 0x20, 0x7E2002: 0x55},
+			want:       Registers816{a: 0x0055, y: 0x0002, p: 0x30, sp: 0x01F0, dbr: 0x7E, pc: 0x8002},
+			wantCycles: 7},
+		{name: "INC dp 16-bit", program: []uint8{0xE6, 0x10},
+			registers:  Registers816{sp: 0x01FF},
+			memory:     map[uint32]uint8{0x10: 0xFF, 0x11: 0x00},
+			want:       Registers816{sp: 0x01FF, pc: 0x8002},
+			wantMemory: map[uint32]uint8{0x10: 0x00, 0x11: 0x01},
+			wantCycles: 7},
+		{name: "PHA 16-bit", program: []uint8{0x48},
+			registers:  Registers816{a: 0xBEEF, sp: 0x1FFF},
+			want:       Registers816{a: 0xBEEF, sp: 0x1FFD, pc: 0x8001},
+			wantMemory: map[uint32]uint8{0x1FFF: 0xBE, 0x1FFE: 0xEF},
+			wantCycles: 4},
+		{name: "XBA", program: []uint8{0xEB},
+			registers:  Registers816{a: 0x1234, p: 0x30, sp: 0x01FF},
+			want:       Registers816{a: 0x3412, p: 0x30, sp: 0x01FF, pc: 0x8001},
+			wantCycles: 3},
+		{name: "TCD TDC", program: []uint8{0x5B, 0x7B},
+			registers:  Registers816{a: 0x8000, p: 0x30, sp: 0x01FF},
+			want:       Registers816{a: 0x8000, d: 0x8000, p: 0xB0, sp: 0x01FF, pc: 0x8002},
+			wantCycles: 4},
+		{name: "ADC 1999+0001 decimal", program: []uint8{0x69, 0x01, 0x00},
+			registers:  Registers816{a: 0x1999, p: uint8(Decimal), sp: 0x01FF},
+			want:       Registers816{a: 0x2000, p: uint8(Decimal), sp: 0x01FF, pc: 0x8003},
+			wantCycles: 3},
+		{name: "ADC 9999+0001 decimal", program: []uint8{0x69, 0x01, 0x00},
+			registers:  Registers816{a: 0x9999, p: uint8(Decimal), sp: 0x01FF},
+			want:       Registers816{a: 0x0000, p: uint8(Decimal | Carry | Zero), sp: 0x01FF, pc: 0x8003},
+			wantCycles: 3},
+		{name: "SBC 1000-0001 decimal", program: []uint8{0xE9, 0x01, 0x00},
+			registers:  Registers816{a: 0x1000, p: uint8(Decimal | Carry), sp: 0x01FF},
+			want:       Registers816{a: 0x0999, p: uint8(Decimal | Carry), sp: 0x01FF, pc: 0x8003},
+			wantCycles: 3},
+		{name: "MVN", program: []uint8{0x54, 0x7F, 0x7E},
+			registers: Registers816{a: 0x0002, x: 0x0010, y: 0x0020, p: 0x30, sp: 0x01FF},
+			memory:    map[uint32]uint8{0x7E0010: 0x01, 0x7E0011: 0x02, 0x7E0012: 0x03},
+			want: Registers816{a: 0xFFFF, x: 0x0013, y: 0x0023, p: 0x30, sp: 0x01FF,
+				dbr: 0x7F, pc: 0x8003},
+			wantMemory: map[uint32]uint8{0x7F0020: 0x01, 0x7F0021: 0x02, 0x7F0022: 0x03},
+			wantCycles: 21},
+		{name: "MVP", program: []uint8{0x44, 0x7F, 0x7E},
+			registers: Registers816{a: 0x0001, x: 0x0011, y: 0x0021, p: 0x30, sp: 0x01FF},
+			memory:    map[uint32]uint8{0x7E0010: 0x01, 0x7E0011: 0x02},
+			want: Registers816{a: 0xFFFF, x: 0x000F, y: 0x001F, p: 0x30, sp: 0x01FF,
+				dbr: 0x7F, pc: 0x8003},
+			wantMemory: map[uint32]uint8{0x7F0020: 0x01, 0x7F0021: 0x02},
+			wantCycles: 14},
+		{name: "JSL RTL", program: []uint8{0x22, 0x00, 0x90, 0x01},
+			registers:  Registers816{p: 0x30, sp: 0x01FF},
+			memory:     map[uint32]uint8{0x019000: 0x6B},
+			want:       Registers816{p: 0x30, sp: 0x01FF, pc: 0x8004},
+			wantMemory: map[uint32]uint8{0x01FF: 0x00, 0x01FE: 0x80, 0x01FD: 0x03},
+			wantCycles: 14},
+		{name: "BRL", program: []uint8{0x82, 0xFD, 0x7F}, steps: 1,
+			registers:  Registers816{p: 0x30, sp: 0x01FF},
+			want:       Registers816{p: 0x30, sp: 0x01FF, pc: 0x0000},
+			wantCycles: 4},
+		{name: "BRA page cross in emulation", program: []uint8{0x80, 0x7F}, steps: 1,
+			registers:  Registers816{p: 0x30, sp: 0x01FF, e: true},
+			want:       Registers816{p: 0x30, sp: 0x01FF, pc: 0x8081, e: true},
+			wantCycles: 3},
+		{name: "COP native", program: []uint8{0x02, 0x00}, steps: 1,
+			registers:  Registers816{p: 0x38, sp: 0x01FF},
+			memory:     map[uint32]uint8{0xFFE4: 0x00, 0xFFE5: 0xA0},
+			want:       Registers816{p: 0x34, sp: 0x01FB, pc: 0xA000},
+			wantMemory: map[uint32]uint8{0x01FF: 0x00, 0x01FE: 0x80, 0x01FD: 0x02, 0x01FC: 0x38},
+			wantCycles: 8},
+		{name: "BRK emulation", program: []uint8{0x00, 0x00}, steps: 1,
+			registers:  Registers816{p: 0x30, sp: 0x01FF, e: true},
+			memory:     map[uint32]uint8{0xFFFE: 0x00, 0xFFFF: 0xB0},
+			want:       Registers816{p: 0x34, sp: 0x01FC, pc: 0xB000, e: true},
+			wantMemory: map[uint32]uint8{0x01FF: 0x80, 0x01FE: 0x02, 0x01FD: 0x30},
+			wantCycles: 7},
+		{name: "WDM", program: []uint8{0x42, 0x00},
+			registers:  Registers816{p: 0x30, sp: 0x01FF},
+			want:       Registers816{p: 0x30, sp: 0x01FF, pc: 0x8002},
+			wantCycles: 2},
+	} {
+		tt.run(t)
+	}
+}
+
+func TestCPU816Reset(t *testing.T) {
+	bus := sparseBus{0xFFFC: 0x00, 0xFFFD: 0x80}
+	c := NewCPU816(bus)
+	c.SetRegisters(Registers816{a: 0x1234, x: 0x5678, y: 0x9ABC, d: 0x2000, dbr: 0x7E, pbr: 0x01})
+	c.Reset()
+
+	want := Registers816{a: 0x1234, x: 0x0078, y: 0x00BC, p: 0x34, sp: 0x01FD, pc: 0x8000, e: true}
+	if got := c.Registers(); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCPU816Interrupts(t *testing.T) {
+	bus := sparseBus{
+		0x8000: 0xCB,               // WAI
+		0xFFEE: 0x00, 0xFFEF: 0x90, // Native IRQ
+		0xFFFE: 0x00, 0xFFFF: 0xA0, // Emulation IRQ
+	}
+
+	// A native interrupt pushes the program bank and takes 8 cycles
+	c := NewCPU816(bus)
+	c.SetRegisters(Registers816{p: 0x30, sp: 0x01FF, pc: 0x8000})
+	c.Step()
+	if !c.Waiting() {
+		t.Fatal("not waiting after WAI")
+	}
+
+	c.SetIRQ(c.NewIRQSource(), true)
+	if cycles := c.Step(); cycles != 8 {
+		t.Errorf("native IRQ took %d cycles, want 8", cycles)
+	}
+	want := Registers816{p: 0x34, sp: 0x01FB, pc: 0x9000}
+	if got := c.Registers(); got != want {
+		t.Errorf("native IRQ: got %+v, want %+v", got, want)
+	}
+	if bus[0x01FC] != 0x30 {
+		t.Errorf("native IRQ pushed P=%02X, want 30", bus[0x01FC])
+	}
+
+	// An emulation interrupt pushes P with the break flag clear
+	c = NewCPU816(bus)
+	c.SetRegisters(Registers816{p: 0x30, sp: 0x01FF, pc: 0x8000, e: true})
+	c.SetIRQ(c.NewIRQSource(), true)
+	if cycles := c.Step(); cycles != 7 {
+		t.Errorf("emulation IRQ took %d cycles, want 7", cycles)
+	}
+	want = Registers816{p: 0x34, sp: 0x01FC, pc: 0xA000, e: true}
+	if got := c.Registers(); got != want {
+		t.Errorf("emulation IRQ: got %+v, want %+v", got, want)
+	}
+	if bus[0x01FD] != 0x20 {
+		t.Errorf("emulation IRQ pushed P=%02X, want 20", bus[0x01FD])
+	}
+}
+
+func TestCPU816IRQLevel(t *testing.T) {
+	bus := sparseBus{
+		0x8000: 0xEA, // NOP
+		0x8001: 0x58, // CLI
+		0x8002: 0xEA, // NOP
+		0x8003: 0x78, // SEI
+		0x8004: 0xEA, // NOP
+		0x8005: 0xEA, // NOP
+		0x9000: 0x40, // RTI
+		0xFFEE: 0x00, 0xFFEF: 0x90,
+	}
+	c := NewCPU816(bus)
+	source := c.NewIRQSource()
+
+	// An IRQ asserted while interrupts are disabled is taken once they are
+	// enabled
+	c.SetRegisters(Registers816{p: 0x34, sp: 0x01FF, pc: 0x8000})
+	c.SetIRQ(source, true)
+	for _, want := range []uint16{0x8001, 0x8002, 0x9000} {
+		c.Step()
+		if c.Registers().PC() != want {
+			t.Fatalf("with IRQ asserted: PC = $%04X, want $%04X", c.Registers().PC(), want)
+		}
+	}
+
+	// Released before the next instruction, it is forgotten
+	c.SetIRQ(source, false)
+	c.Step()
+	if c.Registers().PC() != 0x8002 {
+		t.Fatalf("after RTI: PC = $%04X, want $8002", c.Registers().PC())
+	}
+	c.Step()
+
+	// Asserted after SEI, it is ignored
+	c.Step()
+	c.SetIRQ(source, true)
+	c.Step()
+	if c.Registers().PC() != 0x8005 || c.InterruptsServiced() != 1 {
+		t.Errorf("after SEI: PC = $%04X with %d interrupts, want $8005 with 1", c.Registers().PC(), c.InterruptsServiced())
+	}
+}
+
+func TestCPU816NMIEdge(t *testing.T) {
+	bus := sparseBus{
+		0x8000: 0xEA, 0x8001: 0xEA, // NOP
+		0xB000: 0xEA, 0xB001: 0xEA, // NOP
+		0xFFEA: 0x00, 0xFFEB: 0xB0,
+	}
+	c := NewCPU816(bus)
+	c.SetRegisters(Registers816{p: 0x34, sp: 0x01FF, pc: 0x8000})
+
+	// An NMI is taken with interrupts disabled, once per edge
+	c.SetNMI(true)
+	if cycles := c.Step(); cycles != 8 || c.Registers().PC() != 0xB000 {
+		t.Fatalf("NMI took %d cycles to $%04X, want 8 to $B000", cycles, c.Registers().PC())
+	}
+	c.Step()
+	if c.Registers().PC() != 0xB001 {
+		t.Errorf("NMI held asserted: PC = $%04X, want $B001", c.Registers().PC())
+	}
+
+	c.SetNMI(false)
+	c.SetNMI(true)
+	c.Step()
+	if c.Registers().PC() != 0xB000 || c.InterruptsServiced() != 2 {
+		t.Errorf("second edge: PC = $%04X with %d interrupts, want $B000 with 2", c.Registers().PC(), c.InterruptsServiced())
+	}
+}
+
+func TestCPU816Disassemble(t *testing.T) {
+	bus := sparseBus{}
+	c := NewCPU816(bus)
+	c.SetRegisters(Registers816{p: 0x10, e: false})
+
+	for _, tt := range []struct {
+		program []uint8
+		want    string
+	}{
+		{[]uint8{0xA9, 0x34, 0x12}, "lda #$1234"},
+		{[]uint8{0xA2, 0x34, 0x12}, "ldx #$34"},
+		{[]uint8{0xBF, 0x56, 0x34, 0x12}, "lda $123456,X"},
+		{[]uint8{0xB3, 0x03}, "lda ($03,S),Y"},
+		{[]uint8{0xB7, 0x10}, "lda [$10],Y"},
+		{[]uint8{0x54, 0x7F, 0x7E}, "mvn $7E,$7F"},
+	} {
+		for i, value := range tt.program {
+			bus[0x8000+uint32(i)] = value
+		}
+		if got := c.DisassembleAt(0x8000); got != tt.want {
+			t.Errorf("DisassembleAt(% X) = %q, want %q", tt.program, got, tt.want)
+		}
+	}
+}
+
+func TestInstructions816(t *testing.T) {
+	for opcode, info := range Instructions816 {
+		if info.Opcode != uint8(opcode) {
+			t.Errorf("$%02X is listed as $%02X", opcode, info.Opcode)
+		}
+		if info.Execute == nil {
+			t.Errorf("$%02X %s has no implementation", opcode, InstructionNames[info.Instruction])
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package goemu6502
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// newTestCPU returns a CPU with program loaded at $0400 and PC pointing at it
+func newTestCPU(program ...uint8) (*CPU, *flatBus) {
+	bus := &flatBus{}
+	copy(bus.memory[0x0400:], program)
+
+	c := NewCPU(bus)
+	c.SetPC(0x0400)
+	return c, bus
+}
+
+func TestStep(t *testing.T) {
+	// STA $10; JMP $0400
+	c, bus := newTestCPU(0x85, 0x10, 0x4C, 0x00, 0x04)
+	c.SetA(0x42)
+
+	if cycles := c.Step(); cycles != 3 {
+		t.Errorf("STA zp took %d cycles, want 3", cycles)
+	}
+	if bus.memory[0x10] != 0x42 {
+		t.Errorf("$10 = $%02X, want $42", bus.memory[0x10])
+	}
+	if cycles := c.Step(); cycles != 3 {
+		t.Errorf("JMP abs took %d cycles, want 3", cycles)
+	}
+	if c.PC() != 0x0400 {
+		t.Errorf("PC = $%04X, want $0400", c.PC())
+	}
+}
+
+func TestStepFinishesInstructionInProgress(t *testing.T) {
+	// JMP $0400
+	c, _ := newTestCPU(0x4C, 0x00, 0x04)
+
+	c.Tick()
+	if cycles := c.Step(); cycles != 2 {
+		t.Errorf("Step took %d cycles, want the remaining 2", cycles)
+	}
+	if !c.Complete() {
+		t.Error("instruction still in progress after Step")
+	}
+}
+
+func TestRun(t *testing.T) {
+	// JMP $0400, 3 cycles per instruction
+	c, _ := newTestCPU(0x4C, 0x00, 0x04)
+
+	if overshoot := c.Run(10); overshoot != 2 {
+		t.Errorf("Run(10) overshot by %d, want 2", overshoot)
+	}
+	if overshoot := c.Run(10 - 2); overshoot != 1 {
+		t.Errorf("Run(8) overshot by %d, want 1", overshoot)
+	}
+	if overshoot := c.Run(0); overshoot != 0 {
+		t.Errorf("Run(0) overshot by %d, want 0", overshoot)
+	}
+
+	// An overshoot bigger than the budget carries over without running
+	pc := c.PC()
+	if overshoot := c.Run(1 - 3); overshoot != 2 {
+		t.Errorf("Run(-2) overshot by %d, want 2", overshoot)
+	}
+	if c.PC() != pc || c.TotalInstructions() != 7 {
+		t.Errorf("Run(-2) ran to $%04X after %d instructions", c.PC(), c.TotalInstructions())
+	}
+}
+
+func TestRunUntil(t *testing.T) {
+	// STA $10; JMP $0400
+	c, _ := newTestCPU(0x85, 0x10, 0x4C, 0x00, 0x04)
+
+	steps := 0
+	cycles, err := c.RunUntil(context.Background(), func(c *CPU) bool {
+		steps++
+		return steps > 4
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cycles != 12 {
+		t.Errorf("RunUntil spent %d cycles, want 12", cycles)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.RunUntil(ctx, func(*CPU) bool { return false }); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+}
+
+func TestJamHaltsUntilReset(t *testing.T) {
+	// LDA #$01; JAM
+	c, bus := newTestCPU(0xA9, 0x01, 0x02)
+	bus.memory[0xFFFC] = 0x00
+	bus.memory[0xFFFD] = 0x04
+
+	var haltedAt uint16
+	var haltedOn uint8
+	c.OnHalt(func(pc uint16, opcode uint8) {
+		haltedAt, haltedOn = pc, opcode
+	})
+
+	c.Step()
+	c.Step()
+	if !c.Halted() {
+		t.Fatal("CPU not halted after JAM")
+	}
+	if haltedAt != 0x0402 || haltedOn != 0x02 {
+		t.Errorf("halt handler got $%04X/$%02X, want $0402/$02", haltedAt, haltedOn)
+	}
+
+	// Ticks keep coming but nothing happens
+	pc := c.PC()
+	if overshoot := c.Run(100); overshoot != 0 {
+		t.Errorf("halted CPU overshot by %d", overshoot)
+	}
+	if c.PC() != pc {
+		t.Errorf("PC moved from $%04X to $%04X while halted", pc, c.PC())
+	}
+
+	// Survives a save state round trip
+	data, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewCPU(bus)
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !restored.Halted() {
+		t.Error("halt lost in save state")
+	}
+
+	c.Reset()
+	if c.Halted() {
+		t.Error("CPU still halted after reset")
+	}
+	if c.PC() != 0x0400 {
+		t.Errorf("PC = $%04X after reset, want $0400", c.PC())
+	}
+}
@@ -0,0 +1,73 @@
+package dap
+
+import (
+	"sync"
+
+	"github.com/drewwalton19216801/goemu6502"
+	"github.com/drewwalton19216801/goemu6502/debugger"
+)
+
+type (
+	// callStack follows JSR and RTS to work out which subroutines the CPU is
+	// in, since the 6502 stack holds nothing but return addresses and data
+	callStack struct {
+		mutex  sync.Mutex
+		start  uint16  // Where the program started
+		frames []frame // Outermost first
+	}
+
+	// frame is a subroutine call
+	frame struct {
+		call  uint16 // Address of the JSR
+		entry uint16 // Address of the subroutine
+		sp    uint8  // Stack pointer before the JSR
+	}
+
+	// location is where the CPU is in a subroutine
+	location struct {
+		entry uint16 // Address of the subroutine, or where the program started
+		pc    uint16 // Address of the next instruction, or of the JSR to the subroutine below
+	}
+)
+
+func newCallStack(start uint16) *callStack {
+	return &callStack{start: start}
+}
+
+// after updates the stack after an instruction. It runs while the CPU is
+// locked.
+func (s *callStack) after(e goemu6502.InstructionEvent) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// An RTS, or anything else that unwinds the stack such as a TXS, leaves
+	// the subroutines whose return addresses it dropped
+	sp := e.After.SP()
+	for len(s.frames) > 0 && sp >= s.frames[len(s.frames)-1].sp {
+		s.frames = s.frames[:len(s.frames)-1]
+	}
+
+	if e.Opcode == debugger.OpcodeJSR {
+		s.frames = append(s.frames, frame{call: e.PC, entry: e.After.PC(), sp: e.Before.SP()})
+	}
+}
+
+// trace returns the subroutines the CPU is in, innermost first, with pc as
+// the next instruction
+func (s *callStack) trace(pc uint16) []location {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	trace := make([]location, 0, len(s.frames)+1)
+	for i := len(s.frames); i >= 0; i-- {
+		entry := s.start
+		if i > 0 {
+			entry = s.frames[i-1].entry
+		}
+		trace = append(trace, location{entry: entry, pc: pc})
+		if i > 0 {
+			pc = s.frames[i-1].call
+		}
+	}
+	return trace
+}
@@ -0,0 +1,76 @@
+package dap
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// listing maps the lines of an assembler listing to the addresses of the code
+// on them, so that the listing can stand in for the source. Any listing whose
+// code lines start with a four digit hex address followed by the bytes
+// assembled there will do, such as those of AS65 ("0400 : a9 41"), 64tass
+// (".0400  a9 41") and ACME ("   12  0400 a9 41").
+type listing struct {
+	path      string         // Absolute path of the listing
+	addresses map[int]uint16 // Line to the address of its code
+	lines     map[uint16]int // Address to the line of the code there
+	last      int            // Last line with code on it
+}
+
+// listingCodeLine matches a line with code on it, capturing its address
+var listingCodeLine = regexp.MustCompile(`^\s*(?:\d+\s+)?[.$]?([0-9a-fA-F]{4})\s*:?\s+(?:[0-9a-fA-F]{2})+(?:\s|$)`)
+
+// loadListing reads a listing
+func loadListing(name string) (*listing, error) {
+	path, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	l := &listing{path: path, addresses: map[int]uint16{}, lines: map[uint16]int{}}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		m := listingCodeLine.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		addr, _ := strconv.ParseUint(m[1], 16, 16)
+		l.addresses[line] = uint16(addr)
+		if _, ok := l.lines[uint16(addr)]; !ok {
+			l.lines[uint16(addr)] = line
+		}
+		l.last = line
+	}
+	return l, scanner.Err()
+}
+
+// is reports whether path names the listing
+func (l *listing) is(path string) bool {
+	abs, err := filepath.Abs(path)
+	return err == nil && abs == l.path
+}
+
+// address returns the address of the code on a line. A line without code,
+// such as a comment or a label, moves on to the next line that has some.
+func (l *listing) address(line int) (uint16, int, bool) {
+	for ; line > 0 && line <= l.last; line++ {
+		if addr, ok := l.addresses[line]; ok {
+			return addr, line, true
+		}
+	}
+	return 0, 0, false
+}
+
+// line returns the line of the code at addr
+func (l *listing) line(addr uint16) (int, bool) {
+	line, ok := l.lines[addr]
+	return line, ok
+}
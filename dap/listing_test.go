@@ -0,0 +1,66 @@
+package dap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListing(t *testing.T) {
+	l, err := loadListing("../testdata/functional_excerpt.lst")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		line, wantLine int
+		want           uint16
+	}{
+		{line: 4, wantLine: 5, want: 0x0400},
+		{line: 6, wantLine: 6, want: 0x0402},
+		{line: 15, wantLine: 15, want: 0x040A},
+		{line: 20, wantLine: 20, want: 0x3469},
+	} {
+		if addr, line, ok := l.address(tt.line); !ok || addr != tt.want || line != tt.wantLine {
+			t.Errorf("line %d = $%04X on line %d, %t, want $%04X on line %d", tt.line, addr, line, ok, tt.want, tt.wantLine)
+		}
+	}
+	if _, _, ok := l.address(21); ok {
+		t.Error("found code after the last line")
+	}
+	if line, ok := l.line(0x0408); !ok || line != 13 {
+		t.Errorf("$0408 is on line %d, %t, want 13", line, ok)
+	}
+	if !l.is("../testdata/functional_excerpt.lst") || l.is("functional_excerpt.lst") {
+		t.Error("is doesn't recognise the listing's path")
+	}
+}
+
+func TestListingFormats(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "formats.lst")
+	lines := []string{
+		".0400  a9 41      lda #$41",      // 64tass
+		"   12  0402 8d 00 d0  sta $d000", // ACME
+		"0405  ea  nop",
+		"0406 = 0010  zp = $10", // A symbol, not code
+		"1234  ; just a comment",
+	}
+	if err := os.WriteFile(name, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := loadListing(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]uint16{1: 0x0400, 2: 0x0402, 3: 0x0405}
+	if len(l.addresses) != len(want) {
+		t.Errorf("found code on lines %v, want %v", l.addresses, want)
+	}
+	for line, addr := range want {
+		if l.addresses[line] != addr {
+			t.Errorf("line %d = $%04X, want $%04X", line, l.addresses[line], addr)
+		}
+	}
+}
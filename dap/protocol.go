@@ -0,0 +1,203 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// --- Protocol ---
+// Messages are JSON objects, each preceded by a Content-Length header and a
+// blank line. Only the parts of the protocol that the server uses are
+// declared here; see https://microsoft.github.io/debug-adapter-protocol/ for
+// the rest.
+
+type (
+	// request asks the server to do something
+	request struct {
+		Seq       int             `json:"seq"`
+		Type      string          `json:"type"`
+		Command   string          `json:"command"`
+		Arguments json.RawMessage `json:"arguments,omitempty"`
+	}
+
+	// response answers a request
+	response struct {
+		Seq        int    `json:"seq"`
+		Type       string `json:"type"`
+		RequestSeq int    `json:"request_seq"`
+		Success    bool   `json:"success"`
+		Command    string `json:"command"`
+		Message    string `json:"message,omitempty"`
+		Body       any    `json:"body,omitempty"`
+	}
+
+	// event tells the client that something happened
+	event struct {
+		Seq   int    `json:"seq"`
+		Type  string `json:"type"`
+		Event string `json:"event"`
+		Body  any    `json:"body,omitempty"`
+	}
+
+	// conn reads requests and writes responses and events. Writes can come
+	// from any goroutine.
+	conn struct {
+		reader *bufio.Reader
+		writer io.Writer
+		mutex  sync.Mutex
+		seq    int
+	}
+)
+
+type (
+	capabilities struct {
+		SupportsConfigurationDoneRequest bool `json:"supportsConfigurationDoneRequest"`
+		SupportsConditionalBreakpoints   bool `json:"supportsConditionalBreakpoints"`
+		SupportsInstructionBreakpoints   bool `json:"supportsInstructionBreakpoints"`
+		SupportsReadMemoryRequest        bool `json:"supportsReadMemoryRequest"`
+		SupportsEvaluateForHovers        bool `json:"supportsEvaluateForHovers"`
+	}
+
+	source struct {
+		Name string `json:"name,omitempty"`
+		Path string `json:"path,omitempty"`
+	}
+
+	sourceBreakpoint struct {
+		Line      int    `json:"line"`
+		Condition string `json:"condition,omitempty"`
+	}
+
+	instructionBreakpoint struct {
+		InstructionReference string `json:"instructionReference"`
+		Offset               int    `json:"offset,omitempty"`
+		Condition            string `json:"condition,omitempty"`
+	}
+
+	// breakpoint is a breakpoint as the client sees it
+	breakpoint struct {
+		ID                   int     `json:"id,omitempty"`
+		Verified             bool    `json:"verified"`
+		Message              string  `json:"message,omitempty"`
+		Source               *source `json:"source,omitempty"`
+		Line                 int     `json:"line,omitempty"`
+		InstructionReference string  `json:"instructionReference,omitempty"`
+	}
+
+	thread struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	stackFrame struct {
+		ID                          int     `json:"id"`
+		Name                        string  `json:"name"`
+		Source                      *source `json:"source,omitempty"`
+		Line                        int     `json:"line"`
+		Column                      int     `json:"column"`
+		InstructionPointerReference string  `json:"instructionPointerReference"`
+	}
+
+	scope struct {
+		Name               string `json:"name"`
+		VariablesReference int    `json:"variablesReference"`
+		Expensive          bool   `json:"expensive"`
+	}
+
+	variable struct {
+		Name               string `json:"name"`
+		Value              string `json:"value"`
+		VariablesReference int    `json:"variablesReference"`
+		MemoryReference    string `json:"memoryReference,omitempty"`
+	}
+)
+
+func newConn(rw io.ReadWriter) *conn {
+	return &conn{reader: bufio.NewReader(rw), writer: rw}
+}
+
+// read reads the next request
+func (c *conn) read() (*request, error) {
+	data, err := c.readMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	var r request
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("dap: %w", err)
+	}
+	return &r, nil
+}
+
+// readMessage reads the next message, whatever it is
+func (c *conn) readMessage() ([]byte, error) {
+	length := -1
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+
+		name, value, _ := strings.Cut(line, ":")
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil || length < 0 {
+				return nil, fmt.Errorf("dap: bad Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("dap: message without a Content-Length")
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// respond answers r with body, or with err if it failed
+func (c *conn) respond(r *request, body any, err error) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.seq++
+	resp := response{Seq: c.seq, Type: "response", RequestSeq: r.Seq, Success: err == nil, Command: r.Command, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+		resp.Body = nil
+	}
+	return c.write(resp)
+}
+
+// event sends an event
+func (c *conn) event(name string, body any) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.seq++
+	return c.write(event{Seq: c.seq, Type: "event", Event: name, Body: body})
+}
+
+// write frames a message. The mutex must be held.
+func (c *conn) write(message any) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(c.writer, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = c.writer.Write(data)
+	return err
+}
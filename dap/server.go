@@ -0,0 +1,137 @@
+// Package dap is a Debug Adapter Protocol server, so that editors such as VS
+// Code can debug 6502 programs running on goemu6502.
+//
+// A launch request loads a binary into 64K of RAM and starts it at an
+// address:
+//
+//	{
+//		"program": "hello.bin",
+//		"address": "$0800",
+//		"start": "$0800",
+//		"listing": "hello.lst",
+//		"variant": "65C02",
+//		"stopOnEntry": true
+//	}
+//
+// Only program and address are required. Breakpoints can be set on
+// instructions by address, or on the lines of the assembler listing, which
+// stands in for the source. Stepping over and out of subroutines follows JSR
+// and RTS, and so does the call stack. The registers are shown as variables,
+// memory can be read, and expressions are evaluated like the conditions of
+// the debugger package, which can also be put on breakpoints.
+package dap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Serve runs a debug session over rw until the client disconnects or rw is
+// closed
+func Serve(rw io.ReadWriter) error {
+	s := newSession(newConn(rw))
+	defer s.close()
+
+	for !s.disconnected {
+		r, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if r.Type != "request" {
+			continue
+		}
+		if err := s.handle(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stdio joins standard input and output
+type stdio struct{}
+
+func (stdio) Read(p []byte) (int, error) {
+	return os.Stdin.Read(p)
+}
+
+func (stdio) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+// ServeStdio runs a debug session over standard input and output, which is
+// how editors usually start debug adapters
+func ServeStdio() error {
+	return Serve(stdio{})
+}
+
+// ListenAndServe listens on a TCP address and runs a debug session on every
+// connection, each with its own CPU
+func ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	for {
+		c, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer c.Close()
+			Serve(c)
+		}()
+	}
+}
+
+// address is an address in a launch request, either a number or a string
+// such as "$0800" or "0x0800"
+type address uint16
+
+func (a *address) UnmarshalJSON(data []byte) error {
+	var n uint16
+	if err := json.Unmarshal(data, &n); err == nil {
+		*a = address(n)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("bad address %s", data)
+	}
+	addr, err := parseAddress(s)
+	*a = address(addr)
+	return err
+}
+
+// parseAddress parses $hex, 0xhex or decimal
+func parseAddress(s string) (uint16, error) {
+	text, base := strings.TrimSpace(s), 10
+	switch {
+	case strings.HasPrefix(text, "$"):
+		text, base = text[1:], 16
+	case strings.HasPrefix(text, "0x"), strings.HasPrefix(text, "0X"):
+		text, base = text[2:], 16
+	}
+
+	value, err := strconv.ParseUint(text, base, 16)
+	if err != nil {
+		return 0, fmt.Errorf("bad address %q", s)
+	}
+	return uint16(value), nil
+}
+
+// reference formats an address as a memory or instruction reference
+func reference(addr uint16) string {
+	return fmt.Sprintf("0x%04X", addr)
+}
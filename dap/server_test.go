@@ -0,0 +1,363 @@
+package dap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// program is a program at $0400 that calls a subroutine twice, with its
+// AS65 listing
+var (
+	program = []uint8{
+		0xA2, 0x00, 0x20, 0x10, 0x04, 0x20, 0x10, 0x04, 0x4C, 0x08, 0x04, 0, 0, 0, 0, 0,
+		0xE8, 0x60,
+	}
+	programListing = strings.Join([]string{
+		"                        ; calls sub twice",
+		"0400 : a200                     ldx #0",
+		"0402 : 201004                  jsr sub",
+		"0405 : 201004                  jsr sub",
+		"0408 : 4c0804          done    jmp done",
+		"                        ; counts calls in X",
+		"0410 : e8              sub     inx",
+		"0411 : 60                      rts",
+	}, "\n")
+)
+
+type (
+	// message is any message from the server
+	message struct {
+		Type       string          `json:"type"`
+		Command    string          `json:"command"`
+		Event      string          `json:"event"`
+		RequestSeq int             `json:"request_seq"`
+		Success    bool            `json:"success"`
+		Message    string          `json:"message"`
+		Body       json.RawMessage `json:"body"`
+	}
+
+	// client talks to a server over a pipe
+	client struct {
+		t        *testing.T
+		conn     *conn
+		seq      int
+		messages chan message
+		events   []message // Events that arrived while waiting for a response
+		served   chan error
+	}
+)
+
+func newClient(t *testing.T) *client {
+	server, pipe := net.Pipe()
+	c := &client{t: t, conn: newConn(pipe), messages: make(chan message, 100), served: make(chan error, 1)}
+
+	go func() {
+		c.served <- Serve(server)
+		server.Close()
+	}()
+	go func() {
+		defer close(c.messages)
+		for {
+			data, err := c.conn.readMessage()
+			if err != nil {
+				return
+			}
+			var m message
+			if err := json.Unmarshal(data, &m); err != nil {
+				t.Errorf("bad message %s: %v", data, err)
+				return
+			}
+			c.messages <- m
+		}
+	}()
+
+	t.Cleanup(func() { pipe.Close() })
+	return c
+}
+
+// next waits for the next message
+func (c *client) next() message {
+	c.t.Helper()
+	select {
+	case m, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("the server hung up")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+	}
+	return message{}
+}
+
+// request sends a request and waits for its response, decoding the body
+// into body if it isn't nil
+func (c *client) request(command string, arguments any, body any) message {
+	c.t.Helper()
+	c.seq++
+	c.conn.mutex.Lock()
+	err := c.conn.write(request{Seq: c.seq, Type: "request", Command: command, Arguments: mustMarshal(arguments)})
+	c.conn.mutex.Unlock()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	for {
+		m := c.next()
+		if m.Type == "event" {
+			c.events = append(c.events, m)
+			continue
+		}
+		if m.RequestSeq != c.seq || m.Command != command {
+			c.t.Fatalf("got a response to %s #%d, want %s #%d", m.Command, m.RequestSeq, command, c.seq)
+		}
+		if body != nil && m.Success {
+			if err := json.Unmarshal(m.Body, body); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return m
+	}
+}
+
+// must sends a request that has to succeed
+func (c *client) must(command string, arguments any, body any) {
+	c.t.Helper()
+	if m := c.request(command, arguments, body); !m.Success {
+		c.t.Fatalf("%s failed: %s", command, m.Message)
+	}
+}
+
+// event waits for an event, decoding its body into body if it isn't nil
+func (c *client) event(name string, body any) {
+	c.t.Helper()
+	for {
+		var m message
+		if len(c.events) > 0 {
+			m, c.events = c.events[0], c.events[1:]
+		} else {
+			m = c.next()
+		}
+		if m.Type != "event" || m.Event != name {
+			c.t.Fatalf("got %s %s%s, want %s event", m.Type, m.Command, m.Event, name)
+		}
+		if body != nil {
+			if err := json.Unmarshal(m.Body, body); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return
+	}
+}
+
+// stopped waits for a stopped event and returns its reason
+func (c *client) stopped() string {
+	c.t.Helper()
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	c.event("stopped", &body)
+	return body.Reason
+}
+
+// frames returns the names and lines of the stack frames
+func (c *client) frames() []string {
+	c.t.Helper()
+	var body struct {
+		StackFrames []stackFrame `json:"stackFrames"`
+	}
+	c.must("stackTrace", map[string]any{"threadId": threadID}, &body)
+
+	var frames []string
+	for _, f := range body.StackFrames {
+		frames = append(frames, f.Name+"@"+f.InstructionPointerReference)
+	}
+	return frames
+}
+
+// evaluate returns the value of an expression
+func (c *client) evaluate(expression string) string {
+	c.t.Helper()
+	var body struct {
+		Result string `json:"result"`
+	}
+	c.must("evaluate", map[string]any{"expression": expression}, &body)
+	return body.Result
+}
+
+func mustMarshal(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// launch starts a session debugging program
+func launch(t *testing.T, stopOnEntry bool) (*client, string) {
+	dir := t.TempDir()
+	binary, listingPath := filepath.Join(dir, "program.bin"), filepath.Join(dir, "program.lst")
+	if err := os.WriteFile(binary, program, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(listingPath, []byte(programListing), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newClient(t)
+	var capabilities capabilities
+	c.must("initialize", map[string]any{"adapterID": "goemu6502"}, &capabilities)
+	if !capabilities.SupportsConfigurationDoneRequest || !capabilities.SupportsReadMemoryRequest {
+		t.Errorf("capabilities = %+v", capabilities)
+	}
+
+	c.must("launch", map[string]any{
+		"program":     binary,
+		"address":     "$0400",
+		"listing":     listingPath,
+		"stopOnEntry": stopOnEntry,
+	}, nil)
+	c.event("initialized", nil)
+	return c, listingPath
+}
+
+func TestSession(t *testing.T) {
+	c, listingPath := launch(t, false)
+
+	// A breakpoint on the comment above the subroutine moves to its first line
+	var breakpoints struct {
+		Breakpoints []breakpoint `json:"breakpoints"`
+	}
+	c.must("setBreakpoints", map[string]any{
+		"source":      source{Path: listingPath},
+		"breakpoints": []sourceBreakpoint{{Line: 6}, {Line: 100}},
+	}, &breakpoints)
+	if b := breakpoints.Breakpoints; len(b) != 2 || !b[0].Verified || b[0].Line != 7 || b[1].Verified {
+		t.Fatalf("breakpoints = %+v", b)
+	}
+
+	c.must("configurationDone", nil, nil)
+	if reason := c.stopped(); reason != "breakpoint" {
+		t.Fatalf("stopped for %s, want breakpoint", reason)
+	}
+	if got, want := strings.Join(c.frames(), " "), "$0410@0x0410 $0400@0x0402"; got != want {
+		t.Errorf("frames = %s, want %s", got, want)
+	}
+
+	var variables struct {
+		Variables []variable `json:"variables"`
+	}
+	c.must("variables", map[string]any{"variablesReference": registersReference}, &variables)
+	if v := variables.Variables; len(v) != 6 || v[1].Name != "X" || v[1].Value != "$00" || v[3].VariablesReference != flagsReference {
+		t.Errorf("registers = %+v", v)
+	}
+	var flags struct {
+		Variables []variable `json:"variables"`
+	}
+	c.must("variables", map[string]any{"variablesReference": flagsReference}, &flags)
+	if v := flags.Variables; len(v) != 7 || v[0].Name != "N" || v[5].Name != "Z" || v[5].Value != "1" {
+		t.Errorf("flags = %+v", v)
+	}
+
+	// Out of the subroutine, then over the second call
+	c.must("stepOut", map[string]any{"threadId": threadID}, nil)
+	if reason := c.stopped(); reason != "step" {
+		t.Fatalf("stopped for %s, want step", reason)
+	}
+	if got, want := strings.Join(c.frames(), " "), "$0400@0x0405"; got != want {
+		t.Errorf("frames = %s, want %s", got, want)
+	}
+	c.must("setBreakpoints", map[string]any{"source": source{Path: listingPath}}, nil)
+	c.must("next", map[string]any{"threadId": threadID}, nil)
+	c.stopped()
+	if pc, x := c.evaluate("PC"), c.evaluate("X"); pc != "$0408 (1032)" || x != "$02 (2)" {
+		t.Errorf("PC = %s and X = %s, want $0408 and $02", pc, x)
+	}
+
+	var memory struct {
+		Address string `json:"address"`
+		Data    string `json:"data"`
+	}
+	c.must("readMemory", map[string]any{"memoryReference": "0x0400", "offset": 2, "count": 3}, &memory)
+	if data, _ := base64.StdEncoding.DecodeString(memory.Data); memory.Address != "0x0402" || !bytes.Equal(data, program[2:5]) {
+		t.Errorf("memory at %s = % X, want 0x0402 with % X", memory.Address, data, program[2:5])
+	}
+
+	// The program ends in a loop, which only pause stops
+	c.must("continue", map[string]any{"threadId": threadID}, nil)
+	if m := c.request("next", map[string]any{"threadId": threadID}, nil); m.Success {
+		t.Error("stepped while running")
+	}
+	c.must("pause", map[string]any{"threadId": threadID}, nil)
+	if reason := c.stopped(); reason != "pause" {
+		t.Errorf("stopped for %s, want pause", reason)
+	}
+
+	c.must("disconnect", nil, nil)
+	if err := <-c.served; err != nil {
+		t.Errorf("Serve returned %v", err)
+	}
+}
+
+func TestInstructionBreakpoints(t *testing.T) {
+	c, _ := launch(t, true)
+	var breakpoints struct {
+		Breakpoints []breakpoint `json:"breakpoints"`
+	}
+	c.must("setInstructionBreakpoints", map[string]any{
+		"breakpoints": []instructionBreakpoint{{InstructionReference: "0x0410", Condition: "X == 1"}, {InstructionReference: "nowhere"}},
+	}, &breakpoints)
+	if b := breakpoints.Breakpoints; len(b) != 2 || !b[0].Verified || b[0].Line != 7 || b[1].Verified {
+		t.Fatalf("breakpoints = %+v", b)
+	}
+
+	c.must("configurationDone", nil, nil)
+	if reason := c.stopped(); reason != "entry" {
+		t.Fatalf("stopped for %s, want entry", reason)
+	}
+
+	// Into the first call, which the condition skips
+	for i := 0; i < 2; i++ {
+		c.must("stepIn", map[string]any{"threadId": threadID}, nil)
+		c.stopped()
+	}
+	if got, want := strings.Join(c.frames(), " "), "$0410@0x0410 $0400@0x0402"; got != want {
+		t.Errorf("frames = %s, want %s", got, want)
+	}
+	c.must("continue", map[string]any{"threadId": threadID}, nil)
+	c.stopped()
+	if got, want := strings.Join(c.frames(), " "), "$0410@0x0410 $0400@0x0405"; got != want {
+		t.Errorf("frames = %s, want %s", got, want)
+	}
+	if x := c.evaluate("X + 1"); x != "$02 (2)" {
+		t.Errorf("X + 1 = %s, want $02", x)
+	}
+}
+
+func TestLaunchErrors(t *testing.T) {
+	c := newClient(t)
+	c.must("initialize", nil, nil)
+
+	for _, arguments := range []map[string]any{
+		{"program": "missing.bin", "address": 1024},
+		{"program": "missing.bin"},
+		{"program": "missing.bin", "address": "$10000"},
+	} {
+		if m := c.request("launch", arguments, nil); m.Success {
+			t.Errorf("launched %v", arguments)
+		}
+	}
+	if m := c.request("stackTrace", nil, nil); m.Success || m.Message != errNotLaunched.Error() {
+		t.Errorf("stack trace before launch: %+v", m)
+	}
+	if m := c.request("attach", nil, nil); m.Success {
+		t.Error("attached")
+	}
+}
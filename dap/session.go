@@ -0,0 +1,642 @@
+package dap
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/drewwalton19216801/goemu6502"
+	"github.com/drewwalton19216801/goemu6502/debugger"
+)
+
+type (
+	// session is a debug session with one client, debugging one CPU. The CPU
+	// runs in its own goroutine while the session carries on answering
+	// requests.
+	session struct {
+		conn *conn
+		then func() // Runs after the response to the current request
+
+		mutex        sync.Mutex // Guards everything below
+		disconnected bool
+		cpu          *goemu6502.CPU
+		debugger     *debugger.Debugger
+		stack        *callStack
+		listing      *listing
+		stopOnEntry  bool
+
+		sourceBreakpoints      map[string][]*debugger.Breakpoint // By source path
+		instructionBreakpoints []*debugger.Breakpoint
+
+		cancel  context.CancelFunc // Stops the CPU while it runs
+		running chan struct{}      // Closed when it stops
+	}
+
+	// handler answers a request, returning the body of the response
+	handler func(s *session, arguments json.RawMessage) (any, error)
+
+	launchArguments struct {
+		Program     string   `json:"program"`     // Binary to load
+		Address     *address `json:"address"`     // Where to load it
+		Start       *address `json:"start"`       // Where to start, the load address by default
+		Listing     string   `json:"listing"`     // Assembler listing of the program
+		Variant     string   `json:"variant"`     // 6502 by default
+		StopOnEntry bool     `json:"stopOnEntry"` // Stop before the first instruction
+	}
+)
+
+const (
+	// threadID is the only thread
+	threadID = 1
+
+	// registersReference and flagsReference are the variablesReferences of
+	// the registers and the flags in P
+	registersReference = 1
+	flagsReference     = 2
+)
+
+var (
+	errNotLaunched = errors.New("no program has been launched")
+	errRunning     = errors.New("the program is running")
+)
+
+// handlers maps the commands to their handlers
+var handlers = map[string]handler{
+	"initialize":                (*session).initialize,
+	"launch":                    (*session).launch,
+	"setBreakpoints":            (*session).setBreakpoints,
+	"setInstructionBreakpoints": (*session).setInstructionBreakpoints,
+	"setExceptionBreakpoints":   (*session).setExceptionBreakpoints,
+	"configurationDone":         (*session).configurationDone,
+	"threads":                   (*session).threads,
+	"stackTrace":                (*session).stackTrace,
+	"scopes":                    (*session).scopes,
+	"variables":                 (*session).variables,
+	"continue":                  (*session).continueCommand,
+	"next":                      (*session).next,
+	"stepIn":                    (*session).stepIn,
+	"stepOut":                   (*session).stepOut,
+	"pause":                     (*session).pause,
+	"readMemory":                (*session).readMemory,
+	"evaluate":                  (*session).evaluate,
+	"disconnect":                (*session).disconnect,
+}
+
+// flagNames lists the flags shown under P, from bit 7 down
+var flagNames = []struct {
+	name string
+	flag goemu6502.StatusFlag
+}{
+	{"N", goemu6502.Negative},
+	{"V", goemu6502.Overflow},
+	{"B", goemu6502.Break},
+	{"D", goemu6502.Decimal},
+	{"I", goemu6502.InterruptDisable},
+	{"Z", goemu6502.Zero},
+	{"C", goemu6502.Carry},
+}
+
+func newSession(c *conn) *session {
+	return &session{conn: c, sourceBreakpoints: map[string][]*debugger.Breakpoint{}}
+}
+
+// handle answers a request
+func (s *session) handle(r *request) error {
+	var (
+		body any
+		err  error
+	)
+	if h, ok := handlers[r.Command]; ok {
+		body, err = h(s, r.Arguments)
+	} else {
+		err = fmt.Errorf("%s is not supported", r.Command)
+	}
+
+	if err := s.conn.respond(r, body, err); err != nil {
+		return err
+	}
+	if then := s.then; then != nil {
+		s.then = nil
+		then()
+	}
+	return nil
+}
+
+// close stops the CPU and detaches the debugger
+func (s *session) close() {
+	s.stop()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.debugger != nil {
+		s.debugger.Close()
+	}
+}
+
+// stop stops the CPU if it's running, and waits for it
+func (s *session) stop() {
+	s.mutex.Lock()
+	cancel, running := s.cancel, s.running
+	s.mutex.Unlock()
+
+	if running != nil {
+		cancel()
+		<-running
+	}
+}
+
+// launched returns the CPU and its debugger, once a program has been
+// launched
+func (s *session) launched() (*goemu6502.CPU, *debugger.Debugger, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.cpu == nil {
+		return nil, nil, errNotLaunched
+	}
+	return s.cpu, s.debugger, nil
+}
+
+func (s *session) initialize(json.RawMessage) (any, error) {
+	return capabilities{
+		SupportsConfigurationDoneRequest: true,
+		SupportsConditionalBreakpoints:   true,
+		SupportsInstructionBreakpoints:   true,
+		SupportsReadMemoryRequest:        true,
+		SupportsEvaluateForHovers:        true,
+	}, nil
+}
+
+// launch loads the program and sets up the CPU, which starts running once
+// the client is done configuring it
+func (s *session) launch(arguments json.RawMessage) (any, error) {
+	var args launchArguments
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if args.Program == "" || args.Address == nil {
+		return nil, errors.New("launch needs a program and the address to load it at")
+	}
+
+	variant := goemu6502.NMOS6502
+	if args.Variant != "" {
+		var ok bool
+		if variant, ok = goemu6502.ParseVariant(args.Variant); !ok {
+			return nil, fmt.Errorf("unknown variant %q", args.Variant)
+		}
+	}
+
+	bus := &goemu6502.RAM{}
+	if _, err := bus.LoadFile(args.Program, uint16(*args.Address)); err != nil {
+		return nil, err
+	}
+	start := uint16(*args.Address)
+	if args.Start != nil {
+		start = uint16(*args.Start)
+	}
+
+	var l *listing
+	if args.Listing != "" {
+		var err error
+		if l, err = loadListing(args.Listing); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.cpu != nil {
+		return nil, errors.New("a program has already been launched")
+	}
+
+	s.listing, s.stopOnEntry = l, args.StopOnEntry
+	s.cpu = goemu6502.NewCPU(bus, goemu6502.WithVariant(variant))
+	s.cpu.SetPC(start)
+	s.cpu.SetSP(0xFD)
+	s.stack = newCallStack(start)
+	s.cpu.AddHooks(goemu6502.Hooks{AfterInstruction: s.stack.after})
+	s.debugger = debugger.New(s.cpu)
+
+	// Now breakpoints can be set
+	s.then = func() { s.conn.event("initialized", nil) }
+	return nil, nil
+}
+
+// setBreakpoints replaces the breakpoints on the lines of a source, which
+// must be the listing
+func (s *session) setBreakpoints(arguments json.RawMessage) (any, error) {
+	var args struct {
+		Source      source             `json:"source"`
+		Breakpoints []sourceBreakpoint `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	_, d, err := s.launched()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, b := range s.sourceBreakpoints[args.Source.Path] {
+		d.Remove(b.ID)
+	}
+	delete(s.sourceBreakpoints, args.Source.Path)
+
+	results := make([]breakpoint, 0, len(args.Breakpoints))
+	for _, sb := range args.Breakpoints {
+		result := breakpoint{Source: &args.Source, Line: sb.Line}
+		addr, line, ok := uint16(0), 0, false
+		if s.listing != nil && s.listing.is(args.Source.Path) {
+			addr, line, ok = s.listing.address(sb.Line)
+		}
+		if !ok {
+			result.Message = "no code for this line in the listing"
+			results = append(results, result)
+			continue
+		}
+
+		b, err := d.Break(addr, sb.Condition)
+		if err != nil {
+			result.Message = err.Error()
+			results = append(results, result)
+			continue
+		}
+		s.sourceBreakpoints[args.Source.Path] = append(s.sourceBreakpoints[args.Source.Path], b)
+
+		result.ID, result.Verified, result.Line = b.ID, true, line
+		result.InstructionReference = reference(addr)
+		results = append(results, result)
+	}
+
+	return struct {
+		Breakpoints []breakpoint `json:"breakpoints"`
+	}{results}, nil
+}
+
+// setInstructionBreakpoints replaces the breakpoints on addresses
+func (s *session) setInstructionBreakpoints(arguments json.RawMessage) (any, error) {
+	var args struct {
+		Breakpoints []instructionBreakpoint `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	_, d, err := s.launched()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, b := range s.instructionBreakpoints {
+		d.Remove(b.ID)
+	}
+	s.instructionBreakpoints = nil
+
+	results := make([]breakpoint, 0, len(args.Breakpoints))
+	for _, ib := range args.Breakpoints {
+		var result breakpoint
+		addr, err := parseAddress(ib.InstructionReference)
+		var b *debugger.Breakpoint
+		if err == nil {
+			addr += uint16(ib.Offset)
+			b, err = d.Break(addr, ib.Condition)
+		}
+		if err != nil {
+			result.Message = err.Error()
+			results = append(results, result)
+			continue
+		}
+		s.instructionBreakpoints = append(s.instructionBreakpoints, b)
+
+		result.ID, result.Verified = b.ID, true
+		result.InstructionReference = reference(addr)
+		if s.listing != nil {
+			if line, ok := s.listing.line(addr); ok {
+				result.Source, result.Line = &source{Path: s.listing.path}, line
+			}
+		}
+		results = append(results, result)
+	}
+
+	return struct {
+		Breakpoints []breakpoint `json:"breakpoints"`
+	}{results}, nil
+}
+
+// setExceptionBreakpoints accepts that there are no exception breakpoints,
+// since clients send it whatever the capabilities say
+func (s *session) setExceptionBreakpoints(json.RawMessage) (any, error) {
+	return nil, nil
+}
+
+// configurationDone starts the CPU, or stops it on entry
+func (s *session) configurationDone(json.RawMessage) (any, error) {
+	s.mutex.Lock()
+	stopOnEntry := s.stopOnEntry
+	s.mutex.Unlock()
+
+	if stopOnEntry {
+		cpu, _, err := s.launched()
+		if err != nil {
+			return nil, err
+		}
+		s.then = func() { s.stopped(debugger.Stop{PC: cpu.PC()}, "entry") }
+		return nil, nil
+	}
+	return nil, s.resume((*debugger.Debugger).Continue)
+}
+
+func (s *session) threads(json.RawMessage) (any, error) {
+	return struct {
+		Threads []thread `json:"threads"`
+	}{[]thread{{ID: threadID, Name: "6502"}}}, nil
+}
+
+// stackTrace shows the subroutines that the CPU is in, as far as JSR and RTS
+// tell. Each is named after its address.
+func (s *session) stackTrace(json.RawMessage) (any, error) {
+	cpu, _, err := s.launched()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	trace := s.stack.trace(cpu.PC())
+	frames := make([]stackFrame, len(trace))
+	for i, l := range trace {
+		frames[i] = stackFrame{
+			ID:                          i,
+			Name:                        fmt.Sprintf("$%04X", l.entry),
+			InstructionPointerReference: reference(l.pc),
+		}
+		if s.listing != nil {
+			if line, ok := s.listing.line(l.pc); ok {
+				frames[i].Source, frames[i].Line, frames[i].Column = &source{Path: s.listing.path}, line, 1
+			}
+		}
+	}
+
+	return struct {
+		StackFrames []stackFrame `json:"stackFrames"`
+		TotalFrames int          `json:"totalFrames"`
+	}{frames, len(frames)}, nil
+}
+
+// scopes has the registers, whichever frame is asked about
+func (s *session) scopes(json.RawMessage) (any, error) {
+	return struct {
+		Scopes []scope `json:"scopes"`
+	}{[]scope{{Name: "Registers", VariablesReference: registersReference}}}, nil
+}
+
+// variables shows the registers, or the flags in P
+func (s *session) variables(arguments json.RawMessage) (any, error) {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	cpu, _, err := s.launched()
+	if err != nil {
+		return nil, err
+	}
+
+	r := cpu.Registers()
+	var variables []variable
+	switch args.VariablesReference {
+	case registersReference:
+		variables = []variable{
+			{Name: "A", Value: fmt.Sprintf("$%02X", r.A())},
+			{Name: "X", Value: fmt.Sprintf("$%02X", r.X())},
+			{Name: "Y", Value: fmt.Sprintf("$%02X", r.Y())},
+			{Name: "P", Value: fmt.Sprintf("$%02X %s", r.P(), r.Flags()), VariablesReference: flagsReference},
+			{Name: "SP", Value: fmt.Sprintf("$%02X", r.SP()), MemoryReference: reference(0x0100 | uint16(r.SP()))},
+			{Name: "PC", Value: fmt.Sprintf("$%04X", r.PC()), MemoryReference: reference(r.PC())},
+		}
+	case flagsReference:
+		for _, f := range flagNames {
+			value := "0"
+			if r.Flags().Has(f.flag) {
+				value = "1"
+			}
+			variables = append(variables, variable{Name: f.name, Value: value})
+		}
+	default:
+		return nil, fmt.Errorf("no variables %d", args.VariablesReference)
+	}
+
+	return struct {
+		Variables []variable `json:"variables"`
+	}{variables}, nil
+}
+
+func (s *session) continueCommand(json.RawMessage) (any, error) {
+	if err := s.resume((*debugger.Debugger).Continue); err != nil {
+		return nil, err
+	}
+	return struct {
+		AllThreadsContinued bool `json:"allThreadsContinued"`
+	}{true}, nil
+}
+
+// next steps over a JSR
+func (s *session) next(json.RawMessage) (any, error) {
+	return nil, s.resume((*debugger.Debugger).StepOver)
+}
+
+// stepIn steps a single instruction, into a JSR
+func (s *session) stepIn(json.RawMessage) (any, error) {
+	return nil, s.resume(func(d *debugger.Debugger, _ context.Context) debugger.Stop {
+		return d.Step()
+	})
+}
+
+// stepOut runs until the current subroutine returns
+func (s *session) stepOut(json.RawMessage) (any, error) {
+	return nil, s.resume((*debugger.Debugger).StepOut)
+}
+
+// pause stops the CPU, which reports it with a stopped event
+func (s *session) pause(json.RawMessage) (any, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.running != nil {
+		s.then = s.cancel
+	}
+	return nil, nil
+}
+
+// resume runs the CPU in its own goroutine, once the response has been
+// sent, and reports where it stopped with a stopped event
+func (s *session) resume(run func(*debugger.Debugger, context.Context) debugger.Stop) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.cpu == nil {
+		return errNotLaunched
+	}
+	if s.running != nil {
+		return errRunning
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	running := make(chan struct{})
+	s.cancel, s.running = cancel, running
+	d := s.debugger
+
+	s.then = func() {
+		go func() {
+			defer close(running)
+			stop := run(d, ctx)
+
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+
+			s.cancel, s.running = nil, nil
+			if !s.disconnected {
+				s.stopped(stop, "")
+			}
+			cancel()
+		}()
+	}
+	return nil
+}
+
+// stopped sends a stopped event for stop, with reason overriding the one it
+// has
+func (s *session) stopped(stop debugger.Stop, reason string) {
+	body := struct {
+		Reason            string `json:"reason"`
+		Description       string `json:"description,omitempty"`
+		ThreadID          int    `json:"threadId"`
+		AllThreadsStopped bool   `json:"allThreadsStopped"`
+		Text              string `json:"text,omitempty"`
+		HitBreakpointIDs  []int  `json:"hitBreakpointIds,omitempty"`
+	}{Reason: reason, ThreadID: threadID, AllThreadsStopped: true}
+
+	if reason == "" {
+		switch stop.Reason {
+		case debugger.ReasonBreakpoint:
+			body.Reason = "breakpoint"
+			if stop.Breakpoint != nil {
+				body.HitBreakpointIDs = []int{stop.Breakpoint.ID}
+			}
+		case debugger.ReasonStep:
+			body.Reason = "step"
+		case debugger.ReasonCancelled:
+			body.Reason = "pause"
+		case debugger.ReasonHalted:
+			body.Reason, body.Description, body.Text = "exception", "Halted", "the CPU has halted"
+		default:
+			body.Reason, body.Description = "exception", "Error"
+			if stop.Err != nil {
+				body.Text = stop.Err.Error()
+			}
+		}
+	}
+
+	s.conn.event("stopped", body)
+}
+
+// readMemory reads memory without side effects. Anything past $FFFF is
+// unreadable.
+func (s *session) readMemory(arguments json.RawMessage) (any, error) {
+	var args struct {
+		MemoryReference string `json:"memoryReference"`
+		Offset          int    `json:"offset"`
+		Count           int    `json:"count"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	cpu, _, err := s.launched()
+	if err != nil {
+		return nil, err
+	}
+	base, err := parseAddress(args.MemoryReference)
+	if err != nil {
+		return nil, err
+	}
+
+	start := int(base) + args.Offset
+	if start < 0 || start > 0xFFFF {
+		return nil, fmt.Errorf("$%X is outside memory", start)
+	}
+	data := make([]byte, 0, args.Count)
+	for addr := start; addr <= 0xFFFF && len(data) < args.Count; addr++ {
+		value, err := cpu.Peek(uint16(addr))
+		if err != nil {
+			break
+		}
+		data = append(data, value)
+	}
+
+	return struct {
+		Address         string `json:"address"`
+		Data            string `json:"data"`
+		UnreadableBytes int    `json:"unreadableBytes,omitempty"`
+	}{reference(uint16(start)), base64.StdEncoding.EncodeToString(data), args.Count - len(data)}, nil
+}
+
+// evaluate works out the value of an expression in the syntax of breakpoint
+// conditions, such as "[$10] + X"
+func (s *session) evaluate(arguments json.RawMessage) (any, error) {
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	cpu, _, err := s.launched()
+	if err != nil {
+		return nil, err
+	}
+
+	condition, err := debugger.ParseCondition(args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	value, err := condition.Value(cpu)
+	if err != nil {
+		return nil, err
+	}
+
+	result, memoryReference := fmt.Sprint(value), ""
+	switch {
+	case value >= 0 && value <= 0xFF:
+		result = fmt.Sprintf("$%02X (%d)", value, value)
+		memoryReference = reference(uint16(value))
+	case value > 0xFF && value <= 0xFFFF:
+		result = fmt.Sprintf("$%04X (%d)", value, value)
+		memoryReference = reference(uint16(value))
+	}
+	return struct {
+		Result             string `json:"result"`
+		VariablesReference int    `json:"variablesReference"`
+		MemoryReference    string `json:"memoryReference,omitempty"`
+	}{Result: result, MemoryReference: memoryReference}, nil
+}
+
+// disconnect ends the session
+func (s *session) disconnect(json.RawMessage) (any, error) {
+	s.mutex.Lock()
+	s.disconnected = true
+	s.mutex.Unlock()
+
+	s.stop()
+	return nil, nil
+}
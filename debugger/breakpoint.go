@@ -0,0 +1,93 @@
+package debugger
+
+import (
+	"fmt"
+
+	"github.com/drewwalton19216801/goemu6502"
+)
+
+type (
+	// Kind is what a breakpoint watches for
+	Kind uint8
+
+	// Breakpoint stops the CPU when something happens, if its condition
+	// holds. Watchpoints are breakpoints too.
+	Breakpoint struct {
+		ID        int
+		Kind      Kind
+		Start     uint16     // First address watched by Execute and the watchpoints...
+		End       uint16     // ...and the last
+		Opcode    uint8      // Opcode watched by Opcode
+		Condition *Condition // nil to stop every time
+		Enabled   bool
+		Hits      uint64 // Times the breakpoint stopped the CPU
+	}
+)
+
+const (
+	Execute   Kind = iota // Stop before the instruction at an address runs
+	Read                  // Stop after an instruction reads from a range
+	Write                 // Stop after an instruction writes to a range
+	Access                // Stop after an instruction reads or writes a range
+	Opcode                // Stop before an opcode runs
+	Interrupt             // Stop on the first instruction of an interrupt handler
+)
+
+var kindNames = map[Kind]string{
+	Execute:   "execute",
+	Read:      "read",
+	Write:     "write",
+	Access:    "access",
+	Opcode:    "opcode",
+	Interrupt: "interrupt",
+}
+
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("Kind(%d)", uint8(k))
+}
+
+// watchpoint reports whether the breakpoint watches bus accesses
+func (b *Breakpoint) watchpoint() bool {
+	return b.Kind == Read || b.Kind == Write || b.Kind == Access
+}
+
+// watches reports whether a watchpoint covers a bus access
+func (b *Breakpoint) watches(a goemu6502.Access) bool {
+	if a.Addr < b.Start || a.Addr > b.End {
+		return false
+	}
+
+	switch b.Kind {
+	case Read:
+		return !a.Write
+	case Write:
+		return a.Write
+	default:
+		return true
+	}
+}
+
+func (b *Breakpoint) String() string {
+	var s string
+	switch b.Kind {
+	case Execute:
+		s = fmt.Sprintf("#%d execute $%04X", b.ID, b.Start)
+	case Opcode:
+		s = fmt.Sprintf("#%d opcode $%02X", b.ID, b.Opcode)
+	case Interrupt:
+		s = fmt.Sprintf("#%d interrupt", b.ID)
+	default:
+		s = fmt.Sprintf("#%d %s $%04X-$%04X", b.ID, b.Kind, b.Start, b.End)
+	}
+
+	if b.Condition != nil {
+		s += " if " + b.Condition.String()
+	}
+	if !b.Enabled {
+		s += " (disabled)"
+	}
+	return s
+}
@@ -0,0 +1,383 @@
+// Package debugger stops a goemu6502 CPU on breakpoints, watchpoints and
+// interrupts, without patching anything into the emulated memory.
+package debugger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/drewwalton19216801/goemu6502"
+)
+
+type (
+	// Reason is why the CPU stopped
+	Reason uint8
+
+	// Stop reports where and why the CPU stopped. It always stops between
+	// instructions.
+	Stop struct {
+		Reason     Reason
+		PC         uint16           // Address of the next instruction
+		Breakpoint *Breakpoint      // The breakpoint that stopped the CPU, if any
+		Access     goemu6502.Access // The access that hit a watchpoint
+		Err        error            // What went wrong, for ReasonError and ReasonCancelled
+	}
+
+	// Debugger runs a CPU until one of its breakpoints stops it. Breakpoints
+	// can be changed at any time, even while the CPU runs, but nothing else
+	// may run the CPU while Continue or Step does.
+	Debugger struct {
+		mutex       sync.Mutex
+		cpu         *goemu6502.CPU
+		hook        goemu6502.HookID
+		breakpoints []*Breakpoint
+		nextID      int
+		temporary   []*Breakpoint // Used by StepOver and StepOut, and never listed
+
+		watchpoints atomic.Int32 // Enabled watchpoints, so that accesses can skip the mutex
+		hits        []watchHit   // Watchpoints hit by the instruction that just ran
+		interrupts  uint64       // Interrupts serviced when last checked
+	}
+
+	// watchHit is a bus access that hit a watchpoint
+	watchHit struct {
+		breakpoint *Breakpoint
+		access     goemu6502.Access
+	}
+)
+
+const (
+	ReasonBreakpoint Reason = iota // A breakpoint or watchpoint stopped the CPU
+	ReasonStep                     // Step ran its instruction
+	ReasonHalted                   // The CPU has halted on a JAM or STP
+	ReasonCancelled                // The context was cancelled
+	ReasonError                    // A condition or the illegal opcode policy failed
+)
+
+var reasonNames = map[Reason]string{
+	ReasonBreakpoint: "breakpoint",
+	ReasonStep:       "step",
+	ReasonHalted:     "halted",
+	ReasonCancelled:  "cancelled",
+	ReasonError:      "error",
+}
+
+func (r Reason) String() string {
+	if name, ok := reasonNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Reason(%d)", uint8(r))
+}
+
+func (s Stop) String() string {
+	switch {
+	case s.Err != nil:
+		return fmt.Sprintf("%s at $%04X: %v", s.Reason, s.PC, s.Err)
+	case s.Breakpoint != nil && s.Breakpoint.watchpoint():
+		kind := "read"
+		if s.Access.Write {
+			kind = "write"
+		}
+		return fmt.Sprintf("%s %s at $%04X: %s $%02X at $%04X", s.Reason, s.Breakpoint, s.PC, kind, s.Access.Value, s.Access.Addr)
+	case s.Breakpoint != nil:
+		return fmt.Sprintf("%s %s at $%04X", s.Reason, s.Breakpoint, s.PC)
+	default:
+		return fmt.Sprintf("%s at $%04X", s.Reason, s.PC)
+	}
+}
+
+// New attaches a debugger to cpu. Memory is peeked for opcode breakpoints
+// and conditions, so the bus should implement goemu6502.Peeker.
+func New(cpu *goemu6502.CPU) *Debugger {
+	d := &Debugger{cpu: cpu}
+	d.hook = cpu.AddHooks(goemu6502.Hooks{Access: d.access})
+	return d
+}
+
+// Close detaches the debugger from its CPU
+func (d *Debugger) Close() {
+	d.cpu.RemoveHooks(d.hook)
+}
+
+// Break adds a breakpoint that stops the CPU before it runs the instruction
+// at addr. condition may be empty.
+func (d *Debugger) Break(addr uint16, condition string) (*Breakpoint, error) {
+	return d.add(&Breakpoint{Kind: Execute, Start: addr, End: addr}, condition)
+}
+
+// Watch adds a watchpoint that stops the CPU after an instruction reads,
+// writes or accesses anything from start to end. kind is Read, Write or
+// Access, and condition may be empty.
+func (d *Debugger) Watch(kind Kind, start, end uint16, condition string) (*Breakpoint, error) {
+	if kind != Read && kind != Write && kind != Access {
+		return nil, fmt.Errorf("debugger: %s is not a kind of watchpoint", kind)
+	}
+	if end < start {
+		return nil, fmt.Errorf("debugger: watchpoint ends at $%04X before it starts at $%04X", end, start)
+	}
+	return d.add(&Breakpoint{Kind: kind, Start: start, End: end}, condition)
+}
+
+// BreakOnOpcode adds a breakpoint that stops the CPU before it runs an
+// opcode, wherever it is. condition may be empty.
+func (d *Debugger) BreakOnOpcode(opcode uint8, condition string) (*Breakpoint, error) {
+	return d.add(&Breakpoint{Kind: Opcode, Opcode: opcode}, condition)
+}
+
+// BreakOnInterrupt adds a breakpoint that stops the CPU on the first
+// instruction of an IRQ or NMI handler. condition may be empty.
+func (d *Debugger) BreakOnInterrupt(condition string) (*Breakpoint, error) {
+	return d.add(&Breakpoint{Kind: Interrupt}, condition)
+}
+
+// add numbers a new breakpoint and parses its condition
+func (d *Debugger) add(b *Breakpoint, condition string) (*Breakpoint, error) {
+	if condition != "" {
+		var err error
+		if b.Condition, err = ParseCondition(condition); err != nil {
+			return nil, err
+		}
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.nextID++
+	b.ID = d.nextID
+	b.Enabled = true
+	d.breakpoints = append(d.breakpoints, b)
+	d.countWatchpoints()
+
+	return b, nil
+}
+
+// Remove deletes a breakpoint, and reports whether there was one with that ID
+func (d *Debugger) Remove(id int) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for i, b := range d.breakpoints {
+		if b.ID == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			d.countWatchpoints()
+			return true
+		}
+	}
+	return false
+}
+
+// Enable enables or disables a breakpoint, and reports whether there was one
+// with that ID
+func (d *Debugger) Enable(id int, enabled bool) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, b := range d.breakpoints {
+		if b.ID == id {
+			b.Enabled = enabled
+			d.countWatchpoints()
+			return true
+		}
+	}
+	return false
+}
+
+// Breakpoints returns copies of the breakpoints in the order they were
+// added. The copies don't change as the CPU runs.
+func (d *Debugger) Breakpoints() []Breakpoint {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	breakpoints := make([]Breakpoint, len(d.breakpoints))
+	for i, b := range d.breakpoints {
+		breakpoints[i] = *b
+	}
+	return breakpoints
+}
+
+// countWatchpoints updates the number of enabled watchpoints. The mutex must
+// be held.
+func (d *Debugger) countWatchpoints() {
+	var n int32
+	for _, b := range d.breakpoints {
+		if b.Enabled && b.watchpoint() {
+			n++
+		}
+	}
+	d.watchpoints.Store(n)
+}
+
+// Continue runs the CPU until a breakpoint stops it, it halts, or ctx is
+// cancelled. A breakpoint on the instruction it starts at doesn't stop it, so
+// that it can carry on from the last stop.
+func (d *Debugger) Continue(ctx context.Context) Stop {
+	d.reset()
+
+	var stop Stop
+	first := true
+	_, err := d.cpu.RunUntil(ctx, func(cpu *goemu6502.CPU) bool {
+		var stopped bool
+		stop, stopped = d.check(first)
+		first = false
+		return stopped
+	})
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		return Stop{Reason: ReasonCancelled, PC: d.cpu.PC(), Err: err}
+	case err != nil:
+		return Stop{Reason: ReasonError, PC: d.cpu.PC(), Err: err}
+	}
+	return stop
+}
+
+// Step runs a single instruction, or the start of an interrupt. It reports
+// any watchpoint that the instruction hit, but doesn't look at the
+// breakpoints on the next one.
+func (d *Debugger) Step() Stop {
+	d.reset()
+
+	if _, err := d.cpu.TryStep(); err != nil {
+		return Stop{Reason: ReasonError, PC: d.cpu.PC(), Err: err}
+	}
+
+	if stop, stopped := d.check(true); stopped {
+		return stop
+	}
+	return Stop{Reason: ReasonStep, PC: d.cpu.PC()}
+}
+
+// reset forgets what happened before the CPU starts running
+func (d *Debugger) reset() {
+	interrupts := d.cpu.InterruptsServiced()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.hits = d.hits[:0]
+	d.interrupts = interrupts
+}
+
+// access records the bus accesses that hit watchpoints. It runs while the
+// CPU is locked.
+func (d *Debugger) access(a goemu6502.Access) {
+	if d.watchpoints.Load() == 0 {
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, b := range d.breakpoints {
+		if b.Enabled && b.watchpoint() && b.watches(a) {
+			d.hits = append(d.hits, watchHit{breakpoint: b, access: a})
+		}
+	}
+}
+
+// check looks for a reason to stop before the next instruction. Watchpoints
+// and interrupts come first, since they happened in the last instruction.
+// Breakpoints on the next instruction are skipped if first is set.
+func (d *Debugger) check(first bool) (Stop, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	pc := d.cpu.PC()
+	stop := func(b *Breakpoint) (Stop, bool) {
+		ok, err := d.hit(b)
+		if err != nil {
+			return Stop{Reason: ReasonError, PC: pc, Breakpoint: b, Err: err}, true
+		}
+		return Stop{Reason: ReasonBreakpoint, PC: pc, Breakpoint: b}, ok
+	}
+
+	// Watchpoints hit by the last instruction
+	hits := d.hits
+	d.hits = d.hits[:0]
+	for _, hit := range hits {
+		if s, ok := stop(hit.breakpoint); ok {
+			s.Access = hit.access
+			return s, true
+		}
+	}
+
+	// The last step entered an interrupt handler
+	if interrupts := d.cpu.InterruptsServiced(); interrupts != d.interrupts {
+		d.interrupts = interrupts
+		for _, b := range d.breakpoints {
+			if b.Kind != Interrupt {
+				continue
+			}
+			if s, ok := stop(b); ok {
+				return s, true
+			}
+		}
+	}
+
+	if d.cpu.Halted() {
+		return Stop{Reason: ReasonHalted, PC: pc}, true
+	}
+	if first {
+		return Stop{}, false
+	}
+
+	// Breakpoints on the next instruction
+	for _, b := range d.breakpoints {
+		if s, ok := d.stopAt(b, pc, stop); ok {
+			return s, true
+		}
+	}
+	for _, b := range d.temporary {
+		if s, ok := d.stopAt(b, pc, stop); ok {
+			return s, true
+		}
+	}
+
+	return Stop{}, false
+}
+
+// stopAt stops on an Execute or Opcode breakpoint on the instruction at pc
+func (d *Debugger) stopAt(b *Breakpoint, pc uint16, stop func(*Breakpoint) (Stop, bool)) (Stop, bool) {
+	at, err := d.at(b, pc)
+	if err != nil {
+		return Stop{Reason: ReasonError, PC: pc, Breakpoint: b, Err: err}, true
+	}
+	if !at {
+		return Stop{}, false
+	}
+	return stop(b)
+}
+
+// at reports whether an Execute or Opcode breakpoint is on the instruction at
+// pc
+func (d *Debugger) at(b *Breakpoint, pc uint16) (bool, error) {
+	switch b.Kind {
+	case Execute:
+		return pc == b.Start, nil
+	case Opcode:
+		opcode, err := d.cpu.Peek(pc)
+		return err == nil && opcode == b.Opcode, err
+	default:
+		return false, nil
+	}
+}
+
+// hit reports whether an enabled breakpoint's condition holds, and counts
+// the hit if it does. The mutex must be held.
+func (d *Debugger) hit(b *Breakpoint) (bool, error) {
+	if !b.Enabled {
+		return false, nil
+	}
+
+	if b.Condition != nil {
+		ok, err := b.Condition.Eval(d.cpu)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	b.Hits++
+	return true, nil
+}
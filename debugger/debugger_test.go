@@ -0,0 +1,155 @@
+package debugger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drewwalton19216801/goemu6502"
+)
+
+// loop is a program at $0400 that counts X up in $10 forever:
+//
+//	0400  LDX #$00
+//	0402  INX
+//	0403  STX $10
+//	0405  JMP $0402
+var loop = []uint8{0xA2, 0x00, 0xE8, 0x86, 0x10, 0x4C, 0x02, 0x04}
+
+// newDebugger attaches a debugger to a CPU running program at $0400
+func newDebugger(program []uint8) (*Debugger, *goemu6502.CPU, *goemu6502.RAM) {
+	bus := &goemu6502.RAM{}
+	copy(bus.Memory[0x0400:], program)
+	cpu := goemu6502.NewCPU(bus)
+	cpu.SetRegisters(goemu6502.NewRegisters(0, 0, 0, goemu6502.Unused, 0xFD, 0x0400))
+	return New(cpu), cpu, bus
+}
+
+func TestBreak(t *testing.T) {
+	d, cpu, _ := newDebugger(loop)
+	b, err := d.Break(0x0403, "X == 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := d.Continue(context.Background())
+	if stop.Reason != ReasonBreakpoint || stop.Breakpoint != b || stop.PC != 0x0403 {
+		t.Fatalf("stopped with %v", stop)
+	}
+	if cpu.X() != 3 || b.Hits != 1 {
+		t.Errorf("X = %d with %d hits, want 3 with 1", cpu.X(), b.Hits)
+	}
+
+	// Continuing carries on past the breakpoint, which no longer holds
+	d.Break(0x0405, "")
+	if stop := d.Continue(context.Background()); stop.PC != 0x0405 || cpu.X() != 3 {
+		t.Errorf("stopped with %v and X = %d, want $0405 with X = 3", stop, cpu.X())
+	}
+}
+
+func TestWatch(t *testing.T) {
+	for _, tt := range []struct {
+		kind Kind
+		want bool
+	}{
+		{kind: Write, want: true},
+		{kind: Access, want: true},
+		{kind: Read, want: false},
+	} {
+		t.Run(tt.kind.String(), func(t *testing.T) {
+			d, _, _ := newDebugger(loop)
+			b, _ := d.Watch(tt.kind, 0x0010, 0x001F, "[$10] == 2")
+			d.Break(0x0405, "X == 5")
+
+			// The CPU stops after the instruction that made the access
+			stop := d.Continue(context.Background())
+			if got := stop.Breakpoint == b; got != tt.want {
+				t.Fatalf("stopped with %v", stop)
+			}
+			if tt.want && (stop.PC != 0x0405 || stop.Access.Addr != 0x0010 || stop.Access.Value != 2 || !stop.Access.Write) {
+				t.Errorf("stopped with %v", stop)
+			}
+		})
+	}
+}
+
+func TestBreakOnOpcode(t *testing.T) {
+	d, _, _ := newDebugger(loop)
+	d.BreakOnOpcode(0x4C, "")
+
+	if stop := d.Continue(context.Background()); stop.PC != 0x0405 {
+		t.Errorf("stopped with %v, want JMP at $0405", stop)
+	}
+}
+
+func TestBreakOnInterrupt(t *testing.T) {
+	d, cpu, bus := newDebugger(loop)
+	bus.Memory[0xFFFE], bus.Memory[0xFFFF] = 0x00, 0x80
+	d.BreakOnInterrupt("")
+
+	cpu.SetIRQ(cpu.NewIRQSource(), true)
+	stop := d.Continue(context.Background())
+	if stop.Reason != ReasonBreakpoint || stop.PC != 0x8000 {
+		t.Errorf("stopped with %v, want the IRQ handler at $8000", stop)
+	}
+}
+
+func TestStep(t *testing.T) {
+	d, _, _ := newDebugger(loop)
+	d.Break(0x0402, "")
+
+	if stop := d.Step(); stop.Reason != ReasonStep || stop.PC != 0x0402 {
+		t.Errorf("stopped with %v, want a step to $0402", stop)
+	}
+}
+
+func TestStopsWhenHalted(t *testing.T) {
+	// JAM
+	d, _, _ := newDebugger([]uint8{0x02})
+
+	if stop := d.Continue(context.Background()); stop.Reason != ReasonHalted {
+		t.Errorf("stopped with %v, want halted", stop)
+	}
+}
+
+func TestContinueCancelled(t *testing.T) {
+	d, _, _ := newDebugger(loop)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if stop := d.Continue(ctx); stop.Reason != ReasonCancelled {
+		t.Errorf("stopped with %v, want cancelled", stop)
+	}
+}
+
+func TestRemoveAndEnable(t *testing.T) {
+	d, _, _ := newDebugger(loop)
+	first, _ := d.Break(0x0402, "")
+	second, _ := d.Break(0x0403, "")
+
+	d.Remove(first.ID)
+	if !d.Enable(second.ID, false) || d.Remove(first.ID) {
+		t.Fatal("breakpoints not where they should be")
+	}
+	d.Break(0x0405, "")
+
+	if stop := d.Continue(context.Background()); stop.PC != 0x0405 {
+		t.Errorf("stopped with %v, want $0405", stop)
+	}
+}
+
+func TestBreakpointsAreCopies(t *testing.T) {
+	d, _, _ := newDebugger(loop)
+	d.Break(0x0403, "")
+
+	before := d.Breakpoints()
+	d.Continue(context.Background())
+	d.Enable(before[0].ID, false)
+
+	if b := before[0]; b.Hits != 0 || !b.Enabled {
+		t.Errorf("copy changed to %d hits, enabled %t", b.Hits, b.Enabled)
+	}
+	if b := d.Breakpoints()[0]; b.Hits != 1 || b.Enabled {
+		t.Errorf("got %d hits, enabled %t, want 1 hit, disabled", b.Hits, b.Enabled)
+	}
+}
@@ -0,0 +1,395 @@
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/drewwalton19216801/goemu6502"
+)
+
+// --- Conditions ---
+// Breakpoints can carry a condition, a small C-like expression that is
+// evaluated when the breakpoint is hit, e.g.
+//
+//	A == $40 && [$D012] > $80
+//
+// Numbers are decimal, $hex or %binary. A, X, Y, P, SP and PC are the
+// registers, and N, V, B, D, I, Z and C are the flags, 1 when set. [addr]
+// peeks the byte at addr. The operators are those of C, with the same
+// precedence: unary - ! ~, then * / %, + -, << >>, < <= > >=, == !=, &, ^, |,
+// && and ||. Anything nonzero is true.
+
+type (
+	// Condition is a parsed breakpoint condition
+	Condition struct {
+		source string
+		eval   evalFunc
+	}
+
+	// evalFunc evaluates part of a condition on a CPU
+	evalFunc func(cpu *goemu6502.CPU) (int64, error)
+
+	// parser is a recursive descent parser over the tokens of a condition
+	parser struct {
+		tokens []string
+		pos    int
+	}
+)
+
+var (
+	// ErrSyntax is returned for a condition that can't be parsed
+	ErrSyntax = errors.New("debugger: syntax error")
+
+	// ErrDivideByZero is returned when a condition divides by zero
+	ErrDivideByZero = errors.New("debugger: division by zero")
+)
+
+// binaryLevels lists the binary operators from the loosest binding to the
+// tightest
+var binaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// registers reads the registers by name
+var registers = map[string]func(r goemu6502.Registers) int64{
+	"A":  func(r goemu6502.Registers) int64 { return int64(r.A()) },
+	"X":  func(r goemu6502.Registers) int64 { return int64(r.X()) },
+	"Y":  func(r goemu6502.Registers) int64 { return int64(r.Y()) },
+	"P":  func(r goemu6502.Registers) int64 { return int64(r.P()) },
+	"SP": func(r goemu6502.Registers) int64 { return int64(r.SP()) },
+	"PC": func(r goemu6502.Registers) int64 { return int64(r.PC()) },
+}
+
+// flags maps the flag names to their bits
+var flags = map[string]goemu6502.StatusFlag{
+	"N": goemu6502.Negative,
+	"V": goemu6502.Overflow,
+	"B": goemu6502.Break,
+	"D": goemu6502.Decimal,
+	"I": goemu6502.InterruptDisable,
+	"Z": goemu6502.Zero,
+	"C": goemu6502.Carry,
+}
+
+// ParseCondition parses a breakpoint condition
+func ParseCondition(source string) (*Condition, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	eval, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrSyntax, p.tokens[p.pos])
+	}
+
+	return &Condition{source: source, eval: eval}, nil
+}
+
+// Eval evaluates the condition on cpu, which must not be running
+func (c *Condition) Eval(cpu *goemu6502.CPU) (bool, error) {
+	value, err := c.eval(cpu)
+	return value != 0, err
+}
+
+// Value evaluates the condition on cpu as a number rather than a truth, so
+// that it can be used to inspect the machine
+func (c *Condition) Value(cpu *goemu6502.CPU) (int64, error) {
+	return c.eval(cpu)
+}
+
+func (c *Condition) String() string {
+	return c.source
+}
+
+// tokenize splits a condition into numbers, names and operators
+func tokenize(source string) ([]string, error) {
+	var tokens []string
+
+	for i := 0; i < len(source); {
+		ch := source[i]
+		switch {
+		case ch == ' ' || ch == '\t':
+			i++
+
+		case isWordChar(ch) || ch == '$' || ch == '%' && (len(tokens) == 0 || !isOperand(tokens[len(tokens)-1])):
+			// A number or a name. % is binary where an operand is expected,
+			// and modulo everywhere else.
+			j := i + 1
+			for j < len(source) && isWordChar(source[j]) {
+				j++
+			}
+			tokens = append(tokens, source[i:j])
+			i = j
+
+		default:
+			// Two character operators first
+			if i+1 < len(source) {
+				switch op := source[i : i+2]; op {
+				case "||", "&&", "==", "!=", "<=", ">=", "<<", ">>":
+					tokens = append(tokens, op)
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("|&^<>+-*/%!~()[]", rune(ch)) {
+				return nil, fmt.Errorf("%w: unexpected %q", ErrSyntax, ch)
+			}
+			tokens = append(tokens, string(ch))
+			i++
+		}
+	}
+
+	return tokens, nil
+}
+
+func isWordChar(ch byte) bool {
+	return ch >= '0' && ch <= '9' || ch >= 'A' && ch <= 'Z' || ch >= 'a' && ch <= 'z' || ch == '_'
+}
+
+// isOperand reports whether a token ends an operand, so that an operator
+// follows it
+func isOperand(token string) bool {
+	return token == ")" || token == "]" || isWordChar(token[len(token)-1])
+}
+
+// next returns the next token without consuming it, or "" at the end
+func (p *parser) next() string {
+	if p.pos == len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+// expect consumes the next token, which must be token
+func (p *parser) expect(token string) error {
+	if p.next() != token {
+		return fmt.Errorf("%w: expected %q", ErrSyntax, token)
+	}
+	p.pos++
+	return nil
+}
+
+// binary parses the binary operators from binaryLevels[level] on
+func (p *parser) binary(level int) (evalFunc, error) {
+	if level == len(binaryLevels) {
+		return p.unary()
+	}
+
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.next()
+		if !contains(binaryLevels[level], op) {
+			return left, nil
+		}
+		p.pos++
+
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryOp(op, left, right)
+	}
+}
+
+// unary parses a unary operator, or an operand
+func (p *parser) unary() (evalFunc, error) {
+	op := p.next()
+	if op != "-" && op != "!" && op != "~" {
+		return p.operand()
+	}
+	p.pos++
+
+	operand, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(cpu *goemu6502.CPU) (int64, error) {
+		value, err := operand(cpu)
+		switch op {
+		case "-":
+			value = -value
+		case "!":
+			value = boolValue(value == 0)
+		case "~":
+			value = ^value
+		}
+		return value, err
+	}, nil
+}
+
+// operand parses a number, register, flag, memory access or parenthesised
+// expression
+func (p *parser) operand() (evalFunc, error) {
+	token := p.next()
+	p.pos++
+
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("%w: unexpected end of condition", ErrSyntax)
+
+	case token == "(":
+		inner, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+
+	case token == "[":
+		addr, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		return func(cpu *goemu6502.CPU) (int64, error) {
+			value, err := addr(cpu)
+			if err != nil {
+				return 0, err
+			}
+			b, err := cpu.Peek(uint16(value))
+			return int64(b), err
+		}, p.expect("]")
+	}
+
+	if register, ok := registers[strings.ToUpper(token)]; ok {
+		return func(cpu *goemu6502.CPU) (int64, error) {
+			return register(cpu.Registers()), nil
+		}, nil
+	}
+
+	if flag, ok := flags[strings.ToUpper(token)]; ok {
+		return func(cpu *goemu6502.CPU) (int64, error) {
+			return boolValue(cpu.Flag(flag)), nil
+		}, nil
+	}
+
+	value, err := parseNumber(token)
+	if err != nil {
+		return nil, err
+	}
+	return func(*goemu6502.CPU) (int64, error) {
+		return value, nil
+	}, nil
+}
+
+// parseNumber parses a decimal, $hex or %binary number
+func parseNumber(token string) (int64, error) {
+	base, digits := 10, token
+	switch token[0] {
+	case '$':
+		base, digits = 16, token[1:]
+	case '%':
+		base, digits = 2, token[1:]
+	}
+
+	value, err := strconv.ParseInt(digits, base, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: bad number %q", ErrSyntax, token)
+	}
+	return value, nil
+}
+
+// binaryOp combines two operands with a binary operator. && and || only
+// evaluate their right operand when they need to.
+func binaryOp(op string, left, right evalFunc) evalFunc {
+	return func(cpu *goemu6502.CPU) (int64, error) {
+		l, err := left(cpu)
+		if err != nil {
+			return 0, err
+		}
+
+		switch op {
+		case "&&":
+			if l == 0 {
+				return 0, nil
+			}
+		case "||":
+			if l != 0 {
+				return 1, nil
+			}
+		}
+
+		r, err := right(cpu)
+		if err != nil {
+			return 0, err
+		}
+
+		switch op {
+		case "&&", "||":
+			return boolValue(r != 0), nil
+		case "|":
+			return l | r, nil
+		case "^":
+			return l ^ r, nil
+		case "&":
+			return l & r, nil
+		case "==":
+			return boolValue(l == r), nil
+		case "!=":
+			return boolValue(l != r), nil
+		case "<":
+			return boolValue(l < r), nil
+		case "<=":
+			return boolValue(l <= r), nil
+		case ">":
+			return boolValue(l > r), nil
+		case ">=":
+			return boolValue(l >= r), nil
+		case "<<":
+			return l << uint64(r&63), nil
+		case ">>":
+			return l >> uint64(r&63), nil
+		case "+":
+			return l + r, nil
+		case "-":
+			return l - r, nil
+		case "*":
+			return l * r, nil
+		case "/", "%":
+			if r == 0 {
+				return 0, ErrDivideByZero
+			}
+			if op == "/" {
+				return l / r, nil
+			}
+			return l % r, nil
+		}
+
+		return 0, fmt.Errorf("%w: unknown operator %q", ErrSyntax, op)
+	}
+}
+
+func boolValue(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package debugger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/drewwalton19216801/goemu6502"
+)
+
+func TestCondition(t *testing.T) {
+	bus := &goemu6502.RAM{}
+	bus.Memory[0xD012] = 0x90
+	cpu := goemu6502.NewCPU(bus)
+	cpu.SetRegisters(goemu6502.NewRegisters(0x40, 0x02, 0x00, goemu6502.Carry|goemu6502.Unused, 0xFD, 0x1234))
+
+	for _, tt := range []struct {
+		source string
+		want   bool
+	}{
+		{source: "A == $40 && [$D012] > $80", want: true},
+		{source: "a == 64", want: true},
+		{source: "X == %10", want: true},
+		{source: "[$D010 + X] == $90", want: true},
+		{source: "C && !Z", want: true},
+		{source: "PC == $1234 || [0]", want: true},
+		{source: "1 + 2 * 3 == 7", want: true},
+		{source: "(1 + 2) * 3 == 7", want: false},
+		{source: "-1 < 0", want: true},
+		{source: "(~A & $FF) == $BF", want: true},
+		{source: "A % 3 == 1", want: true},
+		{source: "SP >> 4 == $F", want: true},
+		{source: "Y", want: false},
+	} {
+		condition, err := ParseCondition(tt.source)
+		if err != nil {
+			t.Errorf("%q: %v", tt.source, err)
+			continue
+		}
+		if got, err := condition.Eval(cpu); got != tt.want || err != nil {
+			t.Errorf("%q = %t, %v, want %t", tt.source, got, err, tt.want)
+		}
+	}
+}
+
+func TestConditionErrors(t *testing.T) {
+	for _, source := range []string{"", "A ==", "(A", "[A", "A # 1", "$G", "A B"} {
+		if _, err := ParseCondition(source); !errors.Is(err, ErrSyntax) {
+			t.Errorf("%q: got %v, want ErrSyntax", source, err)
+		}
+	}
+
+	condition, _ := ParseCondition("A / Y")
+	if _, err := condition.Eval(goemu6502.NewCPU(&goemu6502.RAM{})); !errors.Is(err, ErrDivideByZero) {
+		t.Errorf("got %v, want ErrDivideByZero", err)
+	}
+}
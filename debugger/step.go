@@ -0,0 +1,92 @@
+package debugger
+
+import (
+	"context"
+	"fmt"
+)
+
+// The opcodes that StepOver and StepOut look for. Every variant has them.
+const (
+	OpcodeJSR = 0x20 // Calls a subroutine
+	OpcodeRTS = 0x60 // Returns from a subroutine
+	OpcodeRTI = 0x40 // Returns from an interrupt
+)
+
+// StepOver steps like Step, except that it runs a JSR until the subroutine
+// returns. Breakpoints and watchpoints in the subroutine still stop the CPU.
+func (d *Debugger) StepOver(ctx context.Context) Stop {
+	pc, sp := d.cpu.PC(), d.cpu.SP()
+	opcode, err := d.cpu.Peek(pc)
+	if err != nil {
+		return Stop{Reason: ReasonError, PC: pc, Err: err}
+	}
+	if opcode != OpcodeJSR {
+		return d.Step()
+	}
+
+	// Recursive calls come back to the same place deeper in the stack
+	return d.runTo(ctx, []*Breakpoint{
+		{Kind: Execute, Start: pc + 3, End: pc + 3, Condition: d.stackCondition("SP == $%02X", sp)},
+	})
+}
+
+// StepOut runs until the RTS or RTI that returns from the current subroutine
+// or interrupt handler, then steps it, so that the CPU stops at the caller.
+// That is the first one to pull the stack pointer above where it was, so
+// anything pushed in the meantime doesn't matter, while nested calls are run
+// through.
+func (d *Debugger) StepOut(ctx context.Context) Stop {
+	pc, sp := d.cpu.PC(), d.cpu.SP()
+	opcode, err := d.cpu.Peek(pc)
+	if err != nil {
+		return Stop{Reason: ReasonError, PC: pc, Err: err}
+	}
+
+	// Continue wouldn't stop on the return it starts at
+	if opcode != OpcodeRTS && opcode != OpcodeRTI {
+		stop := d.runTo(ctx, []*Breakpoint{
+			{Kind: Opcode, Opcode: OpcodeRTS, Condition: d.stackCondition("SP + 2 > $%02X", sp)},
+			{Kind: Opcode, Opcode: OpcodeRTI, Condition: d.stackCondition("SP + 3 > $%02X", sp)},
+		})
+		if stop.Reason != ReasonStep {
+			return stop
+		}
+	}
+	return d.Step()
+}
+
+// stackCondition parses a condition on the stack pointer
+func (d *Debugger) stackCondition(format string, sp uint8) *Condition {
+	condition, err := ParseCondition(fmt.Sprintf(format, sp))
+	if err != nil {
+		panic(err)
+	}
+	return condition
+}
+
+// runTo continues until one of the temporary breakpoints is hit. Getting
+// there is reported as ReasonStep, and anything else that stops the CPU first
+// as usual.
+func (d *Debugger) runTo(ctx context.Context, temporary []*Breakpoint) Stop {
+	for _, b := range temporary {
+		b.Enabled = true
+	}
+
+	d.mutex.Lock()
+	d.temporary = temporary
+	d.mutex.Unlock()
+
+	defer func() {
+		d.mutex.Lock()
+		d.temporary = nil
+		d.mutex.Unlock()
+	}()
+
+	stop := d.Continue(ctx)
+	for _, b := range temporary {
+		if stop.Breakpoint == b {
+			return Stop{Reason: ReasonStep, PC: stop.PC}
+		}
+	}
+	return stop
+}
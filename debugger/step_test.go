@@ -0,0 +1,95 @@
+package debugger
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// recursive is a program at $0400 that calls a subroutine which calls itself
+// until X reaches 3:
+//
+//	0400  JSR $0410
+//	0403  JSR $0410
+//	0406  NOP
+//	0410  INX
+//	0411  CPX #$03
+//	0413  BCS $0418
+//	0415  JSR $0410
+//	0418  RTS
+var recursive = []uint8{
+	0x20, 0x10, 0x04, 0x20, 0x10, 0x04, 0xEA, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0xE8, 0xE0, 0x03, 0xB0, 0x03, 0x20, 0x10, 0x04, 0x60,
+}
+
+func TestStepOver(t *testing.T) {
+	d, cpu, _ := newDebugger(recursive)
+	if stop := d.StepOver(context.Background()); stop.Reason != ReasonStep || stop.PC != 0x0403 {
+		t.Fatalf("stopped with %v, want step at $0403", stop)
+	}
+	if cpu.X() != 3 || cpu.SP() != 0xFD {
+		t.Errorf("X = %d and SP = $%02X, want 3 and $FD", cpu.X(), cpu.SP())
+	}
+
+	// Anything else is a plain step
+	d.Step()
+	if stop := d.StepOver(context.Background()); stop.Reason != ReasonStep || stop.PC != 0x0411 {
+		t.Errorf("stopped with %v, want step at $0411", stop)
+	}
+
+	// A breakpoint in the subroutine still stops it
+	d, _, _ = newDebugger(recursive)
+	b, _ := d.Break(0x0418, "")
+	if stop := d.StepOver(context.Background()); stop.Breakpoint != b {
+		t.Errorf("stopped with %v, want %v", stop, b)
+	}
+	if len(d.Breakpoints()) != 1 {
+		t.Errorf("%d breakpoints, want 1", len(d.Breakpoints()))
+	}
+}
+
+func TestStepOut(t *testing.T) {
+	d, cpu, _ := newDebugger(recursive)
+
+	// Into the second level of recursion
+	for i := 0; i < 5; i++ {
+		d.Step()
+	}
+	if cpu.PC() != 0x0410 || cpu.SP() != 0xF9 {
+		t.Fatalf("at $%04X with SP = $%02X, want $0410 with $F9", cpu.PC(), cpu.SP())
+	}
+
+	if stop := d.StepOut(context.Background()); stop.Reason != ReasonStep || stop.PC != 0x0418 || cpu.SP() != 0xFB {
+		t.Fatalf("stopped with %v and SP = $%02X, want step at $0418 with $FB", stop, cpu.SP())
+	}
+
+	// Starting on the RTS just returns
+	if stop := d.StepOut(context.Background()); stop.Reason != ReasonStep || stop.PC != 0x0403 || cpu.SP() != 0xFD {
+		t.Errorf("stopped with %v and SP = $%02X, want step at $0403 with $FD", stop, cpu.SP())
+	}
+}
+
+func TestStepOutAfterPush(t *testing.T) {
+	// 0400  JSR $0410
+	// 0403  NOP
+	// 0410  PHA
+	// 0411  JSR $0420
+	// 0414  PLA
+	// 0415  RTS
+	// 0420  RTS
+	program := make([]uint8, 0x21)
+	copy(program, []uint8{0x20, 0x10, 0x04, 0xEA})
+	copy(program[0x10:], []uint8{0x48, 0x20, 0x20, 0x04, 0x68, 0x60})
+	program[0x20] = 0x60
+	d, cpu, _ := newDebugger(program)
+
+	// Stepping out after the push still stops at the caller, not at the
+	// nested RTS
+	d.Step()
+	d.Step()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if stop := d.StepOut(ctx); stop.Reason != ReasonStep || stop.PC != 0x0403 || cpu.SP() != 0xFD {
+		t.Errorf("stopped with %v and SP = $%02X, want step at $0403 with $FD", stop, cpu.SP())
+	}
+}
@@ -0,0 +1,584 @@
+package goemu6502
+
+// --- Cycle-exact mode ---
+// Normally an instruction does all of its work on its first cycle and then
+// counts down the rest. In cycle-exact mode every Tick performs the one bus
+// access the real chip performs on that cycle, including the dummy reads and
+// writes, so that devices watching the bus see each access at the right time.
+//
+// The instruction functions are reused for the arithmetic: the sequences below
+// do the addressing one access per cycle and latch the operand, and the
+// instruction function runs on the cycle of its final access.
+
+// access is how an instruction uses its operand, which decides the accesses
+// it makes at the effective address
+type access uint8
+
+const (
+	accessRead   access = iota // Read the operand
+	accessWrite                // Write the result without reading first
+	accessModify               // Read the operand, write it back, then write the result
+)
+
+// WithCycleExact spreads the bus accesses of every instruction over its
+// cycles, one per Tick, as the real chip does. It is slower than the
+// default.
+func WithCycleExact() Option {
+	return func(c *CPU) {
+		c.cycleExact = true
+	}
+}
+
+// accessOf returns how an instruction uses its operand
+func accessOf(instruction Instruction) access {
+	switch instruction {
+	case sta, stx, sty, stz, sax, sha, shx, shy, tas:
+		return accessWrite
+	case asl, lsr, rol, ror, inc, dec, slo, rla, sre, rra, dcp, isc, trb, tsb,
+		rmb0, rmb1, rmb2, rmb3, rmb4, rmb5, rmb6, rmb7,
+		smb0, smb1, smb2, smb3, smb4, smb5, smb6, smb7:
+		return accessModify
+	default:
+		return accessRead
+	}
+}
+
+// tickExact runs one cycle of the current instruction in cycle-exact mode
+func (c *CPU) tickExact() {
+	switch {
+	case c.status.Cycles == 0:
+		c.startExact()
+	case c.i.operated:
+		// The instruction is done, but branches and the 65C02's decimal mode
+		// take a few cycles more
+		c.i.cycle++
+		c.trailingCycle()
+		c.status.Cycles--
+		return
+	default:
+		c.i.cycle++
+		c.exactCycle()
+	}
+
+	// The count down is only an estimate until the sequence says it is done
+	if !c.i.operated && c.status.Cycles > 1 {
+		c.status.Cycles--
+	}
+}
+
+// startExact performs the first cycle of an instruction, or of an interrupt
+// if the last instruction polled one
+func (c *CPU) startExact() {
+	c.startInstruction()
+
+	if c.startInterrupt() {
+		// The opcode is fetched, but never executed
+		c.bus.Read(c.r.pc)
+		return
+	}
+
+	if !c.fetchInstruction() {
+		c.status.Cycles = 0
+		return
+	}
+	if c.hooks.active {
+		c.beforeInstruction()
+	}
+
+	// The 65C02's one-cycle NOPs are done already
+	if c.status.Cycles <= 1 {
+		c.operate()
+	}
+}
+
+// operate runs the instruction function on the cycle of its final access.
+// Anything it adds to Cycles becomes trailing cycles.
+func (c *CPU) operate() {
+	c.i.operated = true
+	c.status.Cycles = 0
+
+	c.status.currentInstruction.Execute(c)
+}
+
+// finish ends a sequence that did the work of its instruction itself
+func (c *CPU) finish() {
+	c.i.operated = true
+	c.status.Cycles = 0
+}
+
+// fetchOperand reads the next byte of the instruction
+func (c *CPU) fetchOperand() uint8 {
+	var value uint8 = c.bus.Read(c.r.pc)
+	c.r.pc++
+	return value
+}
+
+// indexDummyRead is the cycle spent adding an index. The NMOS 6502 reads
+// from the address before the carry into the high byte is fixed; the 65C02
+// reads the last byte of the instruction instead.
+func (c *CPU) indexDummyRead(unfixed uint16) {
+	if c.variant.cmos() {
+		c.bus.Read(c.r.pc - 1)
+	} else {
+		c.bus.Read(unfixed)
+	}
+}
+
+// indexCycle reports whether indexing from c.i.temp to c.i.addr_absolute
+// costs a cycle, given that the data access would otherwise start on cycle k.
+// Reads only pay when indexing crosses a page; writes and read-modify-writes
+// always pay, unless the table says otherwise like for the 65C02's shifts.
+func (c *CPU) indexCycle(k uint8) bool {
+	var crossed = c.i.temp&0xFF00 != c.i.addr_absolute&0xFF00
+
+	var extra uint8 = 0
+	if accessOf(c.status.currentInstruction.Instruction) == accessModify {
+		extra = 2
+	}
+	var pays = c.status.currentInstruction.Cycles == k+extra
+
+	return crossed || !pays
+}
+
+// dataCycle performs cycle n of the accesses at the effective address, which
+// start on cycle k
+func (c *CPU) dataCycle(n, k uint8) {
+	switch accessOf(c.status.currentInstruction.Instruction) {
+	case accessWrite:
+		c.operate()
+	case accessModify:
+		switch n - k {
+		case 0:
+			c.i.fetched = c.bus.Read(c.i.addr_absolute)
+		case 1:
+			// The NMOS 6502 writes the operand back while it works; the
+			// 65C02 reads it again instead
+			if c.variant.cmos() {
+				c.bus.Read(c.i.addr_absolute)
+			} else {
+				c.bus.Write(c.i.addr_absolute, c.i.fetched)
+			}
+		default:
+			c.operate()
+		}
+	default:
+		c.i.fetched = c.bus.Read(c.i.addr_absolute)
+
+		// A few 65C02 NOPs keep reading for longer than usual
+		if n >= c.status.currentInstruction.Cycles {
+			c.operate()
+		}
+	}
+}
+
+// trailingCycle performs a cycle added by the instruction function
+func (c *CPU) trailingCycle() {
+	if c.i.addr_mode != Relative && c.i.addr_mode != ZeroPageRelative {
+		c.bus.Read(c.i.addr_absolute)
+		return
+	}
+
+	// A taken branch reads the next opcode, then reads from the target
+	// before the carry into the high byte is fixed. c.i.temp holds the
+	// address of the next opcode.
+	if c.status.Cycles == 1 && c.i.temp&0xFF00 != c.r.pc&0xFF00 {
+		c.bus.Read(c.i.temp&0xFF00 | c.r.pc&0x00FF)
+	} else {
+		c.bus.Read(c.i.temp)
+	}
+}
+
+// writeCycle reports whether the next cycle writes to the bus. It follows the
+// sequences in exactCycle.
+func (c *CPU) writeCycle() bool {
+	// Opcode fetches and trailing cycles only read
+	if c.status.Cycles == 0 || c.i.operated {
+		return false
+	}
+
+	var n = c.i.cycle + 1
+
+	if c.i.interrupting {
+		return c.i.vector != vectorReset && n >= 3 && n <= 5
+	}
+
+	switch c.status.currentInstruction.Instruction {
+	case brk:
+		return n >= 3 && n <= 5
+	case jsr:
+		return n == 4 || n == 5
+	case pha, php, phx, phy:
+		return n == 3
+	case rts, rti, pla, plp, plx, ply:
+		return false
+	}
+
+	var operand access = accessOf(c.status.currentInstruction.Instruction)
+	if operand == accessRead {
+		return false
+	}
+
+	// Find the cycle on which the accesses at the effective address start
+	var k uint8
+	switch c.i.addr_mode {
+	case ZeroPage:
+		k = 3
+	case ZeroPageX, ZeroPageY, Absolute:
+		k = 4
+	case ZeroPageIndirect:
+		k = 5
+	case IndexedIndirect:
+		k = 6
+	case AbsoluteX, AbsoluteY:
+		k = 4
+		if c.indexCycle(k) {
+			k++
+		}
+	case IndirectIndexed:
+		k = 5
+		if c.indexCycle(k) {
+			k++
+		}
+	default:
+		return false
+	}
+
+	if n < k {
+		return false
+	}
+	if operand == accessWrite {
+		return n == k
+	}
+
+	// The 65C02 reads the operand twice before it writes the result
+	return n-k >= 2 || n-k == 1 && !c.variant.cmos()
+}
+
+// exactCycle performs cycle n (2 and up) of the current instruction
+func (c *CPU) exactCycle() {
+	var n = c.i.cycle
+
+	if c.i.interrupting {
+		c.interruptCycle(n)
+		return
+	}
+
+	// Instructions that use the stack have sequences of their own
+	switch c.status.currentInstruction.Instruction {
+	case brk:
+		c.brkCycle(n)
+		return
+	case jsr:
+		c.jsrCycle(n)
+		return
+	case rts:
+		c.rtsCycle(n)
+		return
+	case rti:
+		c.rtiCycle(n)
+		return
+	case pha, php, phx, phy:
+		if n == 2 {
+			c.bus.Read(c.r.pc)
+		} else {
+			c.operate()
+		}
+		return
+	case pla, plp, plx, ply:
+		switch n {
+		case 2:
+			c.bus.Read(c.r.pc)
+		case 3:
+			c.bus.Read(0x100 + uint16(c.r.sp))
+		default:
+			c.operate()
+		}
+		return
+	}
+
+	switch c.i.addr_mode {
+	case Implied, Accumulator:
+		c.bus.Read(c.r.pc)
+		if n >= c.status.currentInstruction.Cycles {
+			c.i.fetched = c.r.a
+			c.operate()
+		}
+	case Immediate:
+		c.i.addr_absolute = c.r.pc
+		c.r.pc++
+		c.dataCycle(n, 2)
+	case ZeroPage:
+		if n == 2 {
+			c.i.addr_absolute = uint16(c.fetchOperand())
+		} else {
+			c.dataCycle(n, 3)
+		}
+	case ZeroPageX:
+		c.zeroPageIndexedCycle(n, c.r.x)
+	case ZeroPageY:
+		c.zeroPageIndexedCycle(n, c.r.y)
+	case Absolute:
+		switch n {
+		case 2:
+			c.i.addr_absolute = uint16(c.fetchOperand())
+		case 3:
+			c.i.addr_absolute |= uint16(c.fetchOperand()) << 8
+			if c.status.currentInstruction.Instruction == jmp {
+				c.operate()
+			}
+		default:
+			c.dataCycle(n, 4)
+		}
+	case AbsoluteX:
+		c.absoluteIndexedCycle(n, c.r.x)
+	case AbsoluteY:
+		c.absoluteIndexedCycle(n, c.r.y)
+	case IndexedIndirect:
+		switch n {
+		case 2:
+			c.i.addr_absolute = uint16(c.fetchOperand())
+		case 3:
+			c.indexDummyRead(c.i.addr_absolute)
+			c.i.addr_absolute = (c.i.addr_absolute + uint16(c.r.x)) & 0x00FF
+		case 4:
+			c.i.temp = uint16(c.bus.Read(c.i.addr_absolute))
+		case 5:
+			c.i.temp |= uint16(c.bus.Read((c.i.addr_absolute+1)&0x00FF)) << 8
+			c.i.addr_absolute = c.i.temp
+		default:
+			c.dataCycle(n, 6)
+		}
+	case IndirectIndexed:
+		switch n {
+		case 2:
+			c.i.addr_absolute = uint16(c.fetchOperand())
+		case 3:
+			c.i.temp = uint16(c.bus.Read(c.i.addr_absolute))
+		case 4:
+			c.i.temp |= uint16(c.bus.Read((c.i.addr_absolute+1)&0x00FF)) << 8
+			c.i.addr_absolute = c.i.temp + uint16(c.r.y)
+		default:
+			c.indexedDataCycle(n, 5)
+		}
+	case ZeroPageIndirect:
+		switch n {
+		case 2:
+			c.i.addr_absolute = uint16(c.fetchOperand())
+		case 3:
+			c.i.temp = uint16(c.bus.Read(c.i.addr_absolute))
+		case 4:
+			c.i.temp |= uint16(c.bus.Read((c.i.addr_absolute+1)&0x00FF)) << 8
+			c.i.addr_absolute = c.i.temp
+		default:
+			c.dataCycle(n, 5)
+		}
+	case Indirect:
+		c.indirectCycle(n)
+	case AbsoluteIndexedIndirect:
+		switch n {
+		case 2:
+			c.i.temp = uint16(c.fetchOperand())
+		case 3:
+			c.i.temp |= uint16(c.fetchOperand()) << 8
+		case 4:
+			c.indexDummyRead(c.i.temp)
+			c.i.temp += uint16(c.r.x)
+		case 5:
+			c.i.addr_absolute = uint16(c.bus.Read(c.i.temp))
+		default:
+			c.i.addr_absolute |= uint16(c.bus.Read(c.i.temp+1)) << 8
+			c.operate()
+		}
+	case Relative:
+		c.i.addr_relative = uint16(int8(c.fetchOperand()))
+		c.i.temp = c.r.pc
+		c.operate()
+	case ZeroPageRelative:
+		switch n {
+		case 2:
+			c.i.addr_absolute = uint16(c.fetchOperand())
+		case 3:
+			c.i.fetched = c.bus.Read(c.i.addr_absolute)
+		case 4:
+			c.bus.Read(c.i.addr_absolute)
+		default:
+			c.i.addr_relative = uint16(int8(c.fetchOperand()))
+			c.i.temp = c.r.pc
+			c.operate()
+		}
+	default:
+		c.operate()
+	}
+}
+
+// zeroPageIndexedCycle performs cycle n of a zero page,X or zero page,Y
+// instruction
+func (c *CPU) zeroPageIndexedCycle(n uint8, index uint8) {
+	switch n {
+	case 2:
+		c.i.addr_absolute = uint16(c.fetchOperand())
+	case 3:
+		c.indexDummyRead(c.i.addr_absolute)
+		c.i.addr_absolute = (c.i.addr_absolute + uint16(index)) & 0x00FF
+	default:
+		c.dataCycle(n, 4)
+	}
+}
+
+// absoluteIndexedCycle performs cycle n of an absolute,X or absolute,Y
+// instruction
+func (c *CPU) absoluteIndexedCycle(n uint8, index uint8) {
+	switch n {
+	case 2:
+		c.i.temp = uint16(c.fetchOperand())
+	case 3:
+		c.i.temp |= uint16(c.fetchOperand()) << 8
+		c.i.addr_absolute = c.i.temp + uint16(index)
+	default:
+		c.indexedDataCycle(n, 4)
+	}
+}
+
+// indexedDataCycle performs cycle n of an indexed access whose address is
+// ready on cycle k, fixing up the high byte first if that costs a cycle
+func (c *CPU) indexedDataCycle(n, k uint8) {
+	if !c.indexCycle(k) {
+		c.dataCycle(n, k)
+		return
+	}
+
+	if n == k {
+		c.indexDummyRead(c.i.temp&0xFF00 | c.i.addr_absolute&0x00FF)
+		return
+	}
+	c.dataCycle(n, k+1)
+}
+
+// indirectCycle performs cycle n of JMP (ind), including the NMOS bug that
+// fetches the high byte of a pointer at $xxFF from $xx00
+func (c *CPU) indirectCycle(n uint8) {
+	// The 65C02 spends a cycle more to get it right
+	if c.variant.cmos() {
+		switch n {
+		case 4:
+			c.bus.Read(c.r.pc - 1)
+			return
+		case 5, 6:
+			n--
+		}
+	}
+
+	switch n {
+	case 2:
+		c.i.temp = uint16(c.fetchOperand())
+	case 3:
+		c.i.temp |= uint16(c.fetchOperand()) << 8
+	case 4:
+		c.i.addr_absolute = uint16(c.bus.Read(c.i.temp))
+	default:
+		var high uint16 = c.i.temp + 1
+		if !c.variant.cmos() {
+			high = c.i.temp&0xFF00 | high&0x00FF
+		}
+		c.i.addr_absolute |= uint16(c.bus.Read(high)) << 8
+		c.operate()
+	}
+}
+
+// brkCycle performs cycle n of BRK
+func (c *CPU) brkCycle(n uint8) {
+	switch n {
+	case 2:
+		// Skip the padding byte following the opcode
+		c.fetchOperand()
+		c.i.vector = vectorIRQ
+	case 3:
+		c.pushByte(uint8(c.r.pc >> 8))
+	case 4:
+		c.pushByte(uint8(c.r.pc))
+	case 5:
+		c.pushStatus(true)
+	case 6:
+		c.i.temp = uint16(c.bus.Read(c.i.vector))
+	default:
+		c.r.pc = c.i.temp | uint16(c.bus.Read(c.i.vector+1))<<8
+		c.finish()
+	}
+}
+
+// interruptCycle performs cycle n of an IRQ, NMI or reset. Only reset starts
+// on cycle 1; the others start with the opcode fetch of the instruction they
+// replace.
+func (c *CPU) interruptCycle(n uint8) {
+	switch n {
+	case 1, 2:
+		c.bus.Read(c.r.pc)
+	case 3:
+		c.pushInterrupt(uint8(c.r.pc >> 8))
+	case 4:
+		c.pushInterrupt(uint8(c.r.pc))
+	case 5:
+		c.pushStatus(false)
+	case 6:
+		c.i.temp = uint16(c.bus.Read(c.i.vector))
+	default:
+		c.r.pc = c.i.temp | uint16(c.bus.Read(c.i.vector+1))<<8
+		c.finish()
+	}
+}
+
+// jsrCycle performs cycle n of JSR, which pushes the return address before
+// it has even fetched the high byte of the target
+func (c *CPU) jsrCycle(n uint8) {
+	switch n {
+	case 2:
+		c.i.temp = uint16(c.fetchOperand())
+	case 3:
+		c.bus.Read(0x100 + uint16(c.r.sp))
+	case 4:
+		c.pushByte(uint8(c.r.pc >> 8))
+	case 5:
+		c.pushByte(uint8(c.r.pc))
+	default:
+		c.i.addr_absolute = c.i.temp | uint16(c.bus.Read(c.r.pc))<<8
+		c.r.pc = c.i.addr_absolute
+		c.finish()
+	}
+}
+
+// rtsCycle performs cycle n of RTS
+func (c *CPU) rtsCycle(n uint8) {
+	switch n {
+	case 2:
+		c.bus.Read(c.r.pc)
+	case 3:
+		c.bus.Read(0x100 + uint16(c.r.sp))
+	case 4:
+		c.i.temp = uint16(c.popByte())
+	case 5:
+		c.r.pc = c.i.temp | uint16(c.popByte())<<8
+	default:
+		c.bus.Read(c.r.pc)
+		c.r.pc++
+		c.finish()
+	}
+}
+
+// rtiCycle performs cycle n of RTI
+func (c *CPU) rtiCycle(n uint8) {
+	switch n {
+	case 2:
+		c.bus.Read(c.r.pc)
+	case 3:
+		c.bus.Read(0x100 + uint16(c.r.sp))
+	case 4:
+		c.r.p = c.popByte()
+		c.setFlag(Break, false)
+		c.setFlag(Unused, true)
+	case 5:
+		c.i.temp = uint16(c.popByte())
+	default:
+		c.r.pc = c.i.temp | uint16(c.popByte())<<8
+		c.finish()
+	}
+}
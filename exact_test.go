@@ -0,0 +1,122 @@
+package goemu6502
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestCycleExactBusAccesses(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		variant Variant
+		program []uint8
+		x       uint8
+		p       uint8
+		irq     bool
+		policy  IllegalOpcodePolicy
+		want    []string
+	}{
+		{name: "LDA abs,X page cross", program: []uint8{0xBD, 0xF0, 0x20}, x: 0x20,
+			want: []string{"0400 BD read", "0401 F0 read", "0402 20 read", "2010 00 read", "2110 00 read"}},
+		{name: "LDA abs,X no page cross", program: []uint8{0xBD, 0x00, 0x20}, x: 0x20,
+			want: []string{"0400 BD read", "0401 00 read", "0402 20 read", "2020 00 read"}},
+		{name: "STA abs,X", program: []uint8{0x9D, 0x00, 0x20}, x: 0x01,
+			want: []string{"0400 9D read", "0401 00 read", "0402 20 read", "2001 00 read", "2001 00 write"}},
+		{name: "INC zp", program: []uint8{0xE6, 0x10},
+			want: []string{"0400 E6 read", "0401 10 read", "0010 00 read", "0010 00 write", "0010 01 write"}},
+		{name: "INC zp on the 65C02", variant: CMOS65C02, program: []uint8{0xE6, 0x10},
+			want: []string{"0400 E6 read", "0401 10 read", "0010 00 read", "0010 00 read", "0010 01 write"}},
+		{name: "LDA abs,X page cross on the 65C02", variant: CMOS65C02, program: []uint8{0xBD, 0xF0, 0x20}, x: 0x20,
+			want: []string{"0400 BD read", "0401 F0 read", "0402 20 read", "0402 20 read", "2110 00 read"}},
+		{name: "SLO zp as a NOP", program: []uint8{0x07, 0x10}, policy: IllegalNop,
+			want: []string{"0400 07 read", "0401 10 read", "0010 00 read", "0010 00 read", "0010 00 read"}},
+		{name: "LDA (zp,X)", program: []uint8{0xA1, 0x10}, x: 0x02,
+			want: []string{"0400 A1 read", "0401 10 read", "0010 00 read", "0012 00 read", "0013 00 read", "0000 00 read"}},
+		{name: "BNE taken across a page", program: []uint8{0xD0, 0x80},
+			want: []string{"0400 D0 read", "0401 80 read", "0402 00 read", "0482 00 read"}},
+		{name: "BEQ not taken", program: []uint8{0xF0, 0x80},
+			want: []string{"0400 F0 read", "0401 80 read"}},
+		{name: "JSR", program: []uint8{0x20, 0x34, 0x12},
+			want: []string{"0400 20 read", "0401 34 read", "01FD 00 read", "01FD 04 write", "01FC 02 write", "0402 12 read"}},
+		{name: "PLA", program: []uint8{0x68},
+			want: []string{"0400 68 read", "0401 00 read", "01FD 00 read", "01FE 00 read"}},
+		{name: "IRQ after NOP", program: []uint8{0xEA}, irq: true,
+			want: []string{"0400 EA read", "0401 00 read", "0401 00 read", "0401 00 read",
+				"01FD 04 write", "01FC 01 write", "01FB 20 write", "FFFE 00 read", "FFFF 00 read"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := &recordingBus{}
+			copy(bus.memory[0x0400:], tt.program)
+
+			c := NewCPU(bus, WithVariant(tt.variant), WithIllegalOpcodePolicy(tt.policy), WithCycleExact())
+			c.SetRegisters(NewRegisters(0x00, tt.x, 0x00, tt.p|Unused, 0xFD, 0x0400))
+			if tt.irq {
+				c.SetIRQ(c.NewIRQSource(), true)
+			}
+
+			// Every cycle makes exactly one access, until the instruction, and
+			// any interrupt after it, is done
+			for {
+				before := len(bus.accesses)
+				c.Tick()
+				if len(bus.accesses) != before+1 {
+					t.Fatalf("cycle %d made %d bus accesses", len(bus.accesses), len(bus.accesses)-before)
+				}
+				if c.Complete() && len(bus.accesses) >= len(tt.want) {
+					break
+				}
+			}
+
+			var got []string
+			for _, a := range bus.accesses {
+				got = append(got, a.String())
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("cycle %d: got %s, want %s", i+1, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestCycleExactMatchesDefault runs every opcode of every instruction table
+// from random states in both modes, which must end up in the same state after
+// the same number of cycles
+func TestCycleExactMatchesDefault(t *testing.T) {
+	rng := rand.New(rand.NewSource(6502))
+
+	for _, policy := range []IllegalOpcodePolicy{IllegalExecute, IllegalNop, IllegalHalt, IllegalTrap} {
+		for _, variant := range []Variant{NMOS6502, CMOS65C02, Rockwell65C02, WDC65C02} {
+			for opcode := 0; opcode < 0x100; opcode++ {
+				for i := 0; i < 8; i++ {
+					var memory [0x10000]uint8
+					rng.Read(memory[:])
+
+					var pc uint16 = uint16(rng.Intn(0x10000))
+					memory[pc] = uint8(opcode)
+					r := NewRegisters(uint8(rng.Intn(0x100)), uint8(rng.Intn(0x100)), uint8(rng.Intn(0x100)),
+						uint8(rng.Intn(0x100))|Unused, uint8(rng.Intn(0x100)), pc)
+
+					run := func(options ...Option) (*CPU, *flatBus, int) {
+						bus := &flatBus{memory: memory}
+						c := NewCPU(bus, append(options, WithVariant(variant), WithIllegalOpcodePolicy(policy))...)
+						c.SetRegisters(r)
+						return c, bus, c.Step()
+					}
+					want, wantBus, wantCycles := run()
+					got, gotBus, gotCycles := run(WithCycleExact())
+
+					if got.Registers() != want.Registers() || gotCycles != wantCycles || gotBus.memory != wantBus.memory {
+						t.Fatalf("%s $%02X with policy %d from %+v: got %+v in %d cycles, want %+v in %d cycles (memory equal: %t)",
+							variant, opcode, policy, r, got.Registers(), gotCycles, want.Registers(), wantCycles,
+							gotBus.memory == wantBus.memory)
+					}
+				}
+			}
+		}
+	}
+}
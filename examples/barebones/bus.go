@@ -17,3 +17,7 @@ func (b *Bus) Read(addr uint16) uint8 {
 func (b *Bus) Write(addr uint16, value uint8) {
 	b.memory.Write(addr, value)
 }
+
+func (b *Bus) Peek(addr uint16) uint8 {
+	return b.memory.Read(addr)
+}
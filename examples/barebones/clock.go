@@ -14,8 +14,9 @@ type Clock struct {
 	cpuTick chan struct{}
 	sysTick chan struct{}
 
-	sysTicks int
-	cpuTicks int
+	sysTicks  int
+	cpuTicks  int
+	overshoot int // cycles the CPU has already run ahead of the clock
 
 	pause chan bool
 }
@@ -50,8 +51,14 @@ func (c *Clock) run() {
 
 		case <-c.cpuTick:
 			if !paused {
-				println(c.cpu.String())
-				c.cpu.Tick()
+				// Run whole instructions, sitting out the ticks the last
+				// one overshot by so the CPU keeps time with the clock
+				if c.overshoot > 0 {
+					c.overshoot--
+				} else {
+					c.overshoot = c.cpu.Run(1)
+					println(c.cpu.String())
+				}
 				c.cpuTicks++
 			}
 
@@ -33,5 +33,8 @@ func (m *Machine) Run() {
 }
 
 func (m *Machine) Reset() {
+	// Run the whole reset sequence, which leaves SP at $FD and PC at the
+	// reset vector, before the clock starts
 	m.cpu.Reset()
+	m.cpu.Step()
 }
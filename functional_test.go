@@ -0,0 +1,223 @@
+package goemu6502
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The Klaus Dormann 6502 functional test is not redistributed with this
+// package. Build it (or grab the prebuilt image from the upstream bin_files
+// directory) and drop it in testdata to have it run as part of `go test`.
+// See testdata/README.md for details.
+var (
+	functionalBin     = flag.String("functional.bin", "testdata/6502_functional_test.bin", "path to the Klaus Dormann functional test image")
+	functionalLst     = flag.String("functional.lst", "testdata/6502_functional_test.lst", "path to the matching AS65 listing, used to name traps")
+	functionalStart   = flag.Uint("functional.start", 0x0400, "address execution starts at")
+	functionalSuccess = flag.Uint("functional.success", 0x3469, "address of the success trap")
+	functionalCycles  = flag.Uint64("functional.cycles", 200_000_000, "cycle budget before the test is declared hung")
+)
+
+const (
+	// functionalTestCase is where the functional test stores the number of
+	// the test currently running (test_case in the default configuration).
+	functionalTestCase = 0x0200
+)
+
+// flatBus is 64K of RAM with no I/O, which is all the test images expect.
+type flatBus struct {
+	memory [0x10000]uint8
+}
+
+func (b *flatBus) Read(addr uint16) uint8 {
+	return b.memory[addr]
+}
+
+func (b *flatBus) Write(addr uint16, value uint8) {
+	b.memory[addr] = value
+}
+
+func (b *flatBus) Peek(addr uint16) uint8 {
+	return b.memory[addr]
+}
+
+// trapNames maps the address of every trap in the functional test to the
+// name of the test that contains it.
+type trapNames map[uint16]string
+
+var (
+	// Listing lines that produced code look like "0594 : d0fe  >  bne *"
+	listingCodeLine = regexp.MustCompile(`^([0-9a-fA-F]{4}) : ([0-9a-fA-F]+)\s`)
+
+	// Branch opcodes; a branch with an offset of $FE loops on itself
+	branchOpcodes = map[string]bool{
+		"10": true, "30": true, "50": true, "70": true,
+		"90": true, "b0": true, "d0": true, "f0": true,
+	}
+)
+
+// loadTrapNames parses an AS65 listing of the functional test and returns the
+// name of the test each trap belongs to. The name is the last full-line
+// comment that appeared before the trap in the source.
+func loadTrapNames(path string) (trapNames, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names := trapNames{}
+	heading := "start of test"
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Code lines: check whether the instruction jumps or branches to itself
+		if m := listingCodeLine.FindStringSubmatch(line); m != nil {
+			addr, _ := strconv.ParseUint(m[1], 16, 16)
+			code := strings.ToLower(m[2])
+
+			switch {
+			case len(code) == 4 && branchOpcodes[code[:2]] && code[2:] == "fe":
+				names[uint16(addr)] = heading
+			case len(code) == 6 && code[:2] == "4c" && code[2:] == fmt.Sprintf("%02x%02x", addr&0xFF, addr>>8):
+				names[uint16(addr)] = heading
+			}
+			continue
+		}
+
+		// Full-line comments outside of macro expansions name the next test
+		text := strings.TrimSpace(line)
+		if !strings.HasPrefix(text, ";") {
+			continue
+		}
+		text = strings.TrimSpace(strings.TrimLeft(text, ";"))
+		if strings.IndexFunc(text, func(r rune) bool {
+			return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		}) < 0 {
+			continue
+		}
+		heading = text
+	}
+
+	return names, scanner.Err()
+}
+
+// describe returns a human readable name for the trap at addr.
+func (t trapNames) describe(addr uint16, testCase uint8) string {
+	if name, ok := t[addr]; ok {
+		return fmt.Sprintf("test $%02X (%s)", testCase, name)
+	}
+	return fmt.Sprintf("test $%02X", testCase)
+}
+
+// runUntilTrap runs the CPU until an instruction jumps or branches to itself
+// and returns the address of that instruction. It gives up once budget cycles
+// have been spent, or when the CPU reports an illegal opcode.
+func runUntilTrap(c *CPU, budget uint64) (trap uint16, cycles uint64, err error) {
+	for cycles < budget {
+		pc := c.PC()
+
+		spent, err := c.TryStep()
+		cycles += uint64(spent)
+		if err != nil {
+			return 0, cycles, err
+		}
+
+		if c.PC() == pc {
+			return pc, cycles, nil
+		}
+	}
+
+	return 0, cycles, fmt.Errorf("no trap reached within %d cycles, PC=$%04X", budget, c.PC())
+}
+
+func TestKlausDormannFunctional(t *testing.T) {
+	image, err := os.ReadFile(*functionalBin)
+	if os.IsNotExist(err) {
+		t.Skipf("%s not found, see testdata/README.md", *functionalBin)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if testing.Short() {
+		t.Skip("functional test skipped in short mode")
+	}
+
+	names, err := loadTrapNames(*functionalLst)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("reading listing: %v", err)
+	}
+
+	bus := &flatBus{}
+	copy(bus.memory[:], image)
+
+	// The functional test only uses documented opcodes
+	c := NewCPU(bus, WithIllegalOpcodePolicy(IllegalError))
+	c.Reset()
+	c.SetPC(uint16(*functionalStart))
+
+	trap, cycles, err := runUntilTrap(c, *functionalCycles)
+	if err != nil {
+		t.Fatalf("%v, last test case $%02X", err, bus.memory[functionalTestCase])
+	}
+
+	if trap != uint16(*functionalSuccess) {
+		t.Fatalf("trapped at $%04X in %s after %d cycles\n%s",
+			trap, names.describe(trap, bus.memory[functionalTestCase]), cycles, c)
+	}
+
+	t.Logf("reached success trap $%04X after %d cycles", trap, cycles)
+}
+
+func TestLoadTrapNames(t *testing.T) {
+	names, err := loadTrapNames("testdata/functional_excerpt.lst")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := trapNames{
+		0x0405: "testing relative addressing with BEQ",
+		0x040A: "partial test BNE & CMP, CPX, CPY immediate",
+		0x3469: "S U C C E S S ************************************",
+	}
+	if len(names) != len(want) {
+		t.Fatalf("got %d traps, want %d: %v", len(names), len(want), names)
+	}
+	for addr, name := range want {
+		if names[addr] != name {
+			t.Errorf("trap $%04X: got %q, want %q", addr, names[addr], name)
+		}
+	}
+}
+
+func TestRunUntilTrap(t *testing.T) {
+	bus := &flatBus{}
+
+	// 0x0400: LDA #$42
+	// 0x0402: JMP $0402
+	copy(bus.memory[0x0400:], []uint8{0xA9, 0x42, 0x4C, 0x02, 0x04})
+
+	c := NewCPU(bus)
+	c.SetPC(0x0400)
+
+	trap, cycles, err := runUntilTrap(c, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trap != 0x0402 {
+		t.Errorf("trapped at $%04X, want $0402", trap)
+	}
+	if cycles != 5 {
+		t.Errorf("took %d cycles, want 5", cycles)
+	}
+	if c.A() != 0x42 {
+		t.Errorf("A = $%02X, want $42", c.A())
+	}
+}
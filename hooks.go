@@ -0,0 +1,171 @@
+package goemu6502
+
+// --- Hooks ---
+// Profilers, tracers and tests watch the CPU through hooks instead of
+// wrapping the bus themselves. Any number of them can be attached to a CPU at
+// once. A CPU without hooks doesn't pay for them: the bus is only wrapped
+// while an access hook is attached, and the instruction hooks cost a check of
+// a flag.
+
+type (
+	// Hooks are callbacks that watch the CPU run. Any of them may be nil. They
+	// run while the CPU is locked, so they must not call back into the CPU.
+	Hooks struct {
+		// BeforeInstruction is called once an instruction's opcode has been
+		// fetched, before it executes
+		BeforeInstruction func(pc uint16, opcode uint8)
+
+		// AfterInstruction is called on the last cycle of an instruction
+		AfterInstruction func(e InstructionEvent)
+
+		// Access is called for every bus access the CPU makes, including the
+		// 6510's accesses to its I/O port
+		Access func(a Access)
+	}
+
+	// InstructionEvent describes an instruction that has finished
+	InstructionEvent struct {
+		PC     uint16
+		Opcode uint8
+		Cycles int       // Cycles taken, including any stolen by RDY or DMA
+		Before Registers // Registers before the instruction ran...
+		After  Registers // ...and after
+	}
+
+	// Access is a single bus access
+	Access struct {
+		Addr  uint16
+		Value uint8
+		Write bool
+		Cycle uint64 // TotalCycles when the access was made
+	}
+
+	// HookID identifies hooks added with AddHooks, for RemoveHooks
+	HookID int
+
+	// hookRegistry holds the hooks attached to a CPU, and what they need to
+	// know about the instruction they are watching
+	hookRegistry struct {
+		next   HookID
+		ids    []HookID
+		hooks  []Hooks
+		active bool // Some hooks watch instructions
+		bus    Bus  // The bus before it was wrapped for access hooks
+
+		running bool      // An instruction is being watched...
+		pc      uint16    // ...which is at pc...
+		opcode  uint8     // ...with this opcode...
+		before  Registers // ...and started with these registers...
+		start   uint64    // ...on this cycle
+	}
+
+	// hookBus calls the access hooks for every access to the bus it wraps
+	hookBus struct {
+		cpu *CPU
+		bus Bus
+	}
+)
+
+// AddHooks attaches hooks to the CPU and returns an ID to remove them with
+func (c *CPU) AddHooks(hooks Hooks) HookID {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.hooks.next++
+	c.hooks.ids = append(c.hooks.ids, c.hooks.next)
+	c.hooks.hooks = append(c.hooks.hooks, hooks)
+	c.updateHooks()
+
+	return c.hooks.next
+}
+
+// RemoveHooks detaches the hooks added with id
+func (c *CPU) RemoveHooks(id HookID) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i, hookID := range c.hooks.ids {
+		if hookID == id {
+			c.hooks.ids = append(c.hooks.ids[:i], c.hooks.ids[i+1:]...)
+			c.hooks.hooks = append(c.hooks.hooks[:i], c.hooks.hooks[i+1:]...)
+			break
+		}
+	}
+	c.updateHooks()
+}
+
+// updateHooks wraps the bus if any hooks watch it, and unwraps it if none do
+func (c *CPU) updateHooks() {
+	var access bool
+	c.hooks.active = false
+	for _, hooks := range c.hooks.hooks {
+		access = access || hooks.Access != nil
+		c.hooks.active = c.hooks.active || hooks.BeforeInstruction != nil || hooks.AfterInstruction != nil
+	}
+
+	if c.hooks.bus == nil {
+		c.hooks.bus = c.bus
+	}
+	c.bus = c.hooks.bus
+	if access {
+		c.bus = &hookBus{cpu: c, bus: c.hooks.bus}
+	}
+}
+
+// beforeInstruction starts watching the instruction that was just fetched
+func (c *CPU) beforeInstruction() {
+	c.hooks.running = true
+	c.hooks.pc = c.status.instructionPC
+	c.hooks.opcode = c.i.opcode
+	c.hooks.before = c.r
+	c.hooks.before.pc = c.status.instructionPC
+	c.hooks.start = c.status.totalCycles
+
+	for _, hooks := range c.hooks.hooks {
+		if hooks.BeforeInstruction != nil {
+			hooks.BeforeInstruction(c.hooks.pc, c.hooks.opcode)
+		}
+	}
+}
+
+// afterInstruction reports the instruction being watched once it has
+// finished
+func (c *CPU) afterInstruction() {
+	if !c.hooks.running || c.status.Cycles != 0 {
+		return
+	}
+	c.hooks.running = false
+
+	e := InstructionEvent{
+		PC:     c.hooks.pc,
+		Opcode: c.hooks.opcode,
+		Cycles: int(c.status.totalCycles - c.hooks.start + 1),
+		Before: c.hooks.before,
+		After:  c.r,
+	}
+	for _, hooks := range c.hooks.hooks {
+		if hooks.AfterInstruction != nil {
+			hooks.AfterInstruction(e)
+		}
+	}
+}
+
+// access calls the access hooks
+func (b *hookBus) access(a Access) {
+	for _, hooks := range b.cpu.hooks.hooks {
+		if hooks.Access != nil {
+			hooks.Access(a)
+		}
+	}
+}
+
+func (b *hookBus) Read(addr uint16) uint8 {
+	value := b.bus.Read(addr)
+	b.access(Access{Addr: addr, Value: value, Cycle: b.cpu.status.totalCycles})
+	return value
+}
+
+func (b *hookBus) Write(addr uint16, value uint8) {
+	b.bus.Write(addr, value)
+	b.access(Access{Addr: addr, Value: value, Write: true, Cycle: b.cpu.status.totalCycles})
+}
@@ -0,0 +1,67 @@
+package goemu6502
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	bothModes(t, func(t *testing.T, options ...Option) {
+		// LDA #$42; STA $10
+		c, _ := newInterruptCPU([]uint8{0xA9, 0x42, 0x85, 0x10}, options...)
+
+		var got []string
+		c.AddHooks(Hooks{
+			BeforeInstruction: func(pc uint16, opcode uint8) {
+				got = append(got, fmt.Sprintf("before $%04X $%02X", pc, opcode))
+			},
+			AfterInstruction: func(e InstructionEvent) {
+				got = append(got, fmt.Sprintf("after $%04X $%02X %d A=$%02X->$%02X",
+					e.PC, e.Opcode, e.Cycles, e.Before.A(), e.After.A()))
+			},
+		})
+		c.Step()
+		c.Step()
+
+		want := []string{
+			"before $0400 $A9",
+			"after $0400 $A9 2 A=$00->$42",
+			"before $0402 $85",
+			"after $0402 $85 3 A=$42->$42",
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
+
+func TestAccessHooks(t *testing.T) {
+	// STA $10
+	c, _ := newInterruptCPU([]uint8{0x85, 0x10}, WithCycleExact())
+	c.SetA(0x42)
+
+	// Two hooks see the same accesses
+	var first, second []Access
+	id := c.AddHooks(Hooks{Access: func(a Access) { first = append(first, a) }})
+	c.AddHooks(Hooks{Access: func(a Access) { second = append(second, a) }})
+	c.Step()
+
+	want := []Access{
+		{Addr: 0x0400, Value: 0x85, Cycle: 1},
+		{Addr: 0x0401, Value: 0x10, Cycle: 2},
+		{Addr: 0x0010, Value: 0x42, Write: true, Cycle: 3},
+	}
+	if fmt.Sprint(first) != fmt.Sprint(want) || fmt.Sprint(second) != fmt.Sprint(want) {
+		t.Errorf("got %v and %v, want %v", first, second, want)
+	}
+
+	// A removed hook sees nothing more
+	c.RemoveHooks(id)
+	c.Step()
+	if len(first) != len(want) {
+		t.Errorf("removed hook saw %d more accesses", len(first)-len(want))
+	}
+	if len(second) != len(want)+2 {
+		t.Errorf("remaining hook saw %d accesses for NOP, want 2", len(second)-len(want))
+	}
+}
@@ -0,0 +1,122 @@
+package goemu6502
+
+import "fmt"
+
+// IllegalOpcodePolicy decides what the CPU does when it fetches an opcode that
+// isn't part of the documented instruction set
+type IllegalOpcodePolicy uint8
+
+const (
+	// IllegalExecute runs the opcode the way the real chip does: undocumented
+	// NMOS opcodes do their undocumented thing, JAM halts and the 65C02's
+	// unused opcodes are NOPs. This is the default.
+	IllegalExecute IllegalOpcodePolicy = iota
+
+	// IllegalNop treats the opcode as a NOP. It still uses the addressing mode
+	// and cycle count listed in Instructions, so it skips the same number of
+	// operand bytes and takes as long as the real opcode would.
+	IllegalNop
+
+	// IllegalHalt halts the CPU as if it had executed a JAM opcode
+	IllegalHalt
+
+	// IllegalTrap calls the handler registered with OnIllegalOpcode, then
+	// carries on as for IllegalNop
+	IllegalTrap
+
+	// IllegalError stops the CPU in front of the opcode. TryStep and RunUntil
+	// return an *IllegalOpcodeError; every further tick fetches the opcode
+	// again and fails the same way until PC is moved or the CPU is reset.
+	IllegalError
+)
+
+// IllegalOpcodeError reports an opcode the CPU refused to execute
+type IllegalOpcodeError struct {
+	Opcode uint8  // The offending opcode
+	PC     uint16 // Address the opcode was fetched from
+	Cycle  uint64 // Cycles the CPU had run when it fetched the opcode
+}
+
+func (e *IllegalOpcodeError) Error() string {
+	return fmt.Sprintf("goemu6502: illegal opcode $%02X at $%04X (cycle %d)", e.Opcode, e.PC, e.Cycle)
+}
+
+// WithIllegalOpcodePolicy sets what happens when the CPU fetches an opcode
+// that isn't part of the documented instruction set
+func WithIllegalOpcodePolicy(policy IllegalOpcodePolicy) Option {
+	return func(c *CPU) {
+		c.policy = policy
+	}
+}
+
+// OnIllegalOpcode registers the handler called by the IllegalTrap policy. The
+// handler runs while the CPU is locked, so it must not call back into the CPU.
+func (c *CPU) OnIllegalOpcode(handler func(err *IllegalOpcodeError)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.onIllegal = handler
+}
+
+// Documented reports whether the instruction is part of the documented
+// instruction set
+func (info InstructionInfo) Documented() bool {
+	switch info.Instruction {
+	case nop:
+		// Only $EA is the official NOP
+		return info.Opcode == 0xEA
+	case sbc:
+		// $EB is an undocumented copy of SBC #
+		return info.Opcode != 0xEB
+	default:
+		return info.Instruction >= adc && info.Instruction <= tya ||
+			info.Instruction >= bra && info.Instruction <= xce
+	}
+}
+
+// illegalOpcode applies the illegal opcode policy to the opcode that was just
+// fetched. The current instruction is replaced by the one to execute in its
+// place, so that cycle-exact mode makes the accesses of the replacement. NOPs
+// keep the addressing mode and cycle count of the opcode. It returns false if the opcode must not be
+// executed at all.
+func (c *CPU) illegalOpcode() bool {
+	err := &IllegalOpcodeError{
+		Opcode: c.i.opcode,
+		PC:     c.r.pc - 1,
+		Cycle:  c.status.totalCycles - 1, // The fetch cycle is already counted
+	}
+
+	// The NOP standing in still pays for crossing a page only if the opcode
+	// would have
+	info := &c.status.currentInstruction
+	nopExecute := (*CPU).nop
+	if accessOf(info.Instruction) != accessRead {
+		nopExecute = (*CPU).nopNoPageCycle
+	}
+
+	switch c.policy {
+	case IllegalHalt:
+		// Nothing but the opcode is read, as for a real JAM
+		*info = InstructionInfo{jam, info.Opcode, Implied, 2, (*CPU).jam}
+	case IllegalTrap:
+		if c.onIllegal != nil {
+			c.onIllegal(err)
+		}
+		info.Instruction, info.Execute = nop, nopExecute
+	case IllegalError:
+		// Leave PC pointing at the opcode
+		c.r.pc = err.PC
+		c.status.err = err
+		return false
+	default:
+		info.Instruction, info.Execute = nop, nopExecute
+	}
+	return true
+}
+
+// nopNoPageCycle is a NOP standing in for an opcode that takes as long
+// whether or not indexing crosses a page
+func (c *CPU) nopNoPageCycle() uint8 {
+	c.nop()
+	return 0
+}
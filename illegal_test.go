@@ -0,0 +1,107 @@
+package goemu6502
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// illegalTestProgram is LAX $10; LDA #$01
+var illegalTestProgram = []uint8{0xA7, 0x10, 0xA9, 0x01}
+
+func newIllegalTestCPU(policy IllegalOpcodePolicy) (*CPU, *flatBus) {
+	bus := &flatBus{}
+	copy(bus.memory[0x0400:], illegalTestProgram)
+	bus.memory[0x10] = 0x42
+
+	c := NewCPU(bus, WithIllegalOpcodePolicy(policy))
+	c.SetPC(0x0400)
+	return c, bus
+}
+
+func TestIllegalExecute(t *testing.T) {
+	c, _ := newIllegalTestCPU(IllegalExecute)
+
+	if _, err := c.TryStep(); err != nil {
+		t.Fatal(err)
+	}
+	if c.X() != 0x42 {
+		t.Errorf("X = $%02X, want LAX to load $42", c.X())
+	}
+}
+
+func TestIllegalNop(t *testing.T) {
+	c, _ := newIllegalTestCPU(IllegalNop)
+
+	cycles, err := c.TryStep()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cycles != 3 || c.PC() != 0x0402 || c.X() != 0 {
+		t.Errorf("got %d cycles, PC=$%04X, X=$%02X; want 3 cycles, PC=$0402, X=$00", cycles, c.PC(), c.X())
+	}
+}
+
+func TestIllegalHalt(t *testing.T) {
+	c, _ := newIllegalTestCPU(IllegalHalt)
+
+	c.Step()
+	if !c.Halted() {
+		t.Error("CPU not halted")
+	}
+}
+
+func TestIllegalTrap(t *testing.T) {
+	c, _ := newIllegalTestCPU(IllegalTrap)
+
+	var trapped *IllegalOpcodeError
+	c.OnIllegalOpcode(func(err *IllegalOpcodeError) {
+		trapped = err
+	})
+
+	c.Step()
+	if trapped == nil || trapped.Opcode != 0xA7 || trapped.PC != 0x0400 {
+		t.Fatalf("trap handler got %v, want opcode $A7 at $0400", trapped)
+	}
+	if c.PC() != 0x0402 {
+		t.Errorf("PC = $%04X, want $0402", c.PC())
+	}
+}
+
+func TestIllegalError(t *testing.T) {
+	c, _ := newIllegalTestCPU(IllegalError)
+
+	// Get some cycles on the clock first
+	c.SetPC(0x0402)
+	c.Step()
+	c.SetPC(0x0400)
+
+	_, err := c.TryStep()
+	var illegal *IllegalOpcodeError
+	if !errors.As(err, &illegal) {
+		t.Fatalf("got error %v, want *IllegalOpcodeError", err)
+	}
+	if illegal.Opcode != 0xA7 || illegal.PC != 0x0400 || illegal.Cycle != 2 {
+		t.Errorf("got %+v, want opcode $A7 at $0400 on cycle 2", *illegal)
+	}
+	if c.PC() != 0x0400 || c.X() != 0 {
+		t.Errorf("illegal opcode was executed: PC=$%04X X=$%02X", c.PC(), c.X())
+	}
+
+	_, err = c.RunUntil(context.Background(), func(*CPU) bool { return false })
+	if !errors.As(err, &illegal) {
+		t.Errorf("RunUntil got error %v, want *IllegalOpcodeError", err)
+	}
+}
+
+func TestDocumented(t *testing.T) {
+	documented := 0
+	for _, info := range Instructions {
+		if info.Documented() {
+			documented++
+		}
+	}
+	if documented != 151 {
+		t.Errorf("%d documented opcodes, want 151", documented)
+	}
+}
@@ -63,11 +63,103 @@ const (
 	txs
 	tya
 
-	xxx
+	// Undocumented NMOS instructions
+	alr
+	anc
+	ane
+	arr
+	dcp
+	isc
+	las
+	lax
+	lxa
+	rla
+	rra
+	sax
+	sbx
+	sha
+	shx
+	shy
+	slo
+	sre
+	tas
+	jam
+
+	// 65C02 instructions
+	bra
+	phx
+	phy
+	plx
+	ply
+	stz
+	trb
+	tsb
+	rmb0
+	rmb1
+	rmb2
+	rmb3
+	rmb4
+	rmb5
+	rmb6
+	rmb7
+	smb0
+	smb1
+	smb2
+	smb3
+	smb4
+	smb5
+	smb6
+	smb7
+	bbr0
+	bbr1
+	bbr2
+	bbr3
+	bbr4
+	bbr5
+	bbr6
+	bbr7
+	bbs0
+	bbs1
+	bbs2
+	bbs3
+	bbs4
+	bbs5
+	bbs6
+	bbs7
+	wai
+	stp
+
+	// 65C816 instructions
+	brl
+	cop
+	jml
+	jsl
+	mvn
+	mvp
+	pea
+	pei
+	per
+	phb
+	phd
+	phk
+	plb
+	pld
+	rep
+	rtl
+	sep
+	tcd
+	tcs
+	tdc
+	tsc
+	txy
+	tyx
+	wdm
+	xba
+	xce
 )
 
-// InstructionNames is a map of instruction names
-var InstructionNames = map[Instruction]string{
+// InstructionNames holds the name of every instruction
+var InstructionNames = [...]string{
 	adc: "adc",
 	and: "and",
 	asl: "asl",
@@ -125,7 +217,96 @@ var InstructionNames = map[Instruction]string{
 	txs: "txs",
 	tya: "tya",
 
-	xxx: "xxx",
+	alr: "alr",
+	anc: "anc",
+	ane: "ane",
+	arr: "arr",
+	dcp: "dcp",
+	isc: "isc",
+	las: "las",
+	lax: "lax",
+	lxa: "lxa",
+	rla: "rla",
+	rra: "rra",
+	sax: "sax",
+	sbx: "sbx",
+	sha: "sha",
+	shx: "shx",
+	shy: "shy",
+	slo: "slo",
+	sre: "sre",
+	tas: "tas",
+	jam: "jam",
+
+	bra:  "bra",
+	phx:  "phx",
+	phy:  "phy",
+	plx:  "plx",
+	ply:  "ply",
+	stz:  "stz",
+	trb:  "trb",
+	tsb:  "tsb",
+	rmb0: "rmb0",
+	rmb1: "rmb1",
+	rmb2: "rmb2",
+	rmb3: "rmb3",
+	rmb4: "rmb4",
+	rmb5: "rmb5",
+	rmb6: "rmb6",
+	rmb7: "rmb7",
+	smb0: "smb0",
+	smb1: "smb1",
+	smb2: "smb2",
+	smb3: "smb3",
+	smb4: "smb4",
+	smb5: "smb5",
+	smb6: "smb6",
+	smb7: "smb7",
+	bbr0: "bbr0",
+	bbr1: "bbr1",
+	bbr2: "bbr2",
+	bbr3: "bbr3",
+	bbr4: "bbr4",
+	bbr5: "bbr5",
+	bbr6: "bbr6",
+	bbr7: "bbr7",
+	bbs0: "bbs0",
+	bbs1: "bbs1",
+	bbs2: "bbs2",
+	bbs3: "bbs3",
+	bbs4: "bbs4",
+	bbs5: "bbs5",
+	bbs6: "bbs6",
+	bbs7: "bbs7",
+	wai:  "wai",
+	stp:  "stp",
+
+	brl: "brl",
+	cop: "cop",
+	jml: "jml",
+	jsl: "jsl",
+	mvn: "mvn",
+	mvp: "mvp",
+	pea: "pea",
+	pei: "pei",
+	per: "per",
+	phb: "phb",
+	phd: "phd",
+	phk: "phk",
+	plb: "plb",
+	pld: "pld",
+	rep: "rep",
+	rtl: "rtl",
+	sep: "sep",
+	tcd: "tcd",
+	tcs: "tcs",
+	tdc: "tdc",
+	tsc: "tsc",
+	txy: "txy",
+	tyx: "tyx",
+	wdm: "wdm",
+	xba: "xba",
+	xce: "xce",
 }
 
 // InstructionInfo contains information about an instruction
@@ -137,8 +318,18 @@ type InstructionInfo struct {
 	Execute     func(*CPU) uint8
 }
 
-// Instructions is a map of instruction infos
-var Instructions = map[uint8]InstructionInfo{
+// Name returns the mnemonic of the instruction, e.g. "lda"
+func (info InstructionInfo) Name() string {
+	return InstructionNames[info.Instruction]
+}
+
+// Length returns how many bytes the instruction takes, opcode included
+func (info InstructionInfo) Length() int {
+	return 1 + operandSizes[info.Mode]
+}
+
+// Instructions is the instruction table for the NMOS 6502, indexed by opcode
+var Instructions = [256]InstructionInfo{
 	0x69: {adc, 0x69, Immediate, 2, (*CPU).adc},
 	0x65: {adc, 0x65, ZeroPage, 3, (*CPU).adc},
 	0x75: {adc, 0x75, ZeroPageX, 4, (*CPU).adc},
@@ -291,184 +482,140 @@ var Instructions = map[uint8]InstructionInfo{
 	0x9A: {txs, 0x9A, Implied, 2, (*CPU).txs},
 	0x98: {tya, 0x98, Implied, 2, (*CPU).tya},
 
-	// Anything else is illegal
-	0x80: {xxx, 0x80, Implied, 0, (*CPU).xxx},
-	0x02: {xxx, 0x02, Implied, 0, (*CPU).xxx},
-	0x12: {xxx, 0x12, Implied, 0, (*CPU).xxx},
-	0x22: {xxx, 0x22, Implied, 0, (*CPU).xxx},
-	0x32: {xxx, 0x32, Implied, 0, (*CPU).xxx},
-	0x42: {xxx, 0x42, Implied, 0, (*CPU).xxx},
-	0x52: {xxx, 0x52, Implied, 0, (*CPU).xxx},
-	0x62: {xxx, 0x62, Implied, 0, (*CPU).xxx},
-	0x72: {xxx, 0x72, Implied, 0, (*CPU).xxx},
-	0x92: {xxx, 0x92, Implied, 0, (*CPU).xxx},
-	0xB2: {xxx, 0xB2, Implied, 0, (*CPU).xxx},
-	0xD2: {xxx, 0xD2, Implied, 0, (*CPU).xxx},
-	0xF2: {xxx, 0xF2, Implied, 0, (*CPU).xxx},
-
-	0x03: {xxx, 0x03, Implied, 0, (*CPU).xxx},
-	0x13: {xxx, 0x13, Implied, 0, (*CPU).xxx},
-	0x23: {xxx, 0x23, Implied, 0, (*CPU).xxx},
-	0x33: {xxx, 0x33, Implied, 0, (*CPU).xxx},
-	0x43: {xxx, 0x43, Implied, 0, (*CPU).xxx},
-	0x53: {xxx, 0x53, Implied, 0, (*CPU).xxx},
-	0x63: {xxx, 0x63, Implied, 0, (*CPU).xxx},
-	0x73: {xxx, 0x73, Implied, 0, (*CPU).xxx},
-	0x83: {xxx, 0x83, Implied, 0, (*CPU).xxx},
-	0x93: {xxx, 0x93, Implied, 0, (*CPU).xxx},
-	0xA3: {xxx, 0xA3, Implied, 0, (*CPU).xxx},
-	0xB3: {xxx, 0xB3, Implied, 0, (*CPU).xxx},
-	0xC3: {xxx, 0xC3, Implied, 0, (*CPU).xxx},
-	0xD3: {xxx, 0xD3, Implied, 0, (*CPU).xxx},
-	0xE3: {xxx, 0xE3, Implied, 0, (*CPU).xxx},
-	0xF3: {xxx, 0xF3, Implied, 0, (*CPU).xxx},
-
-	0x04: {xxx, 0x04, Implied, 0, (*CPU).xxx},
-	0x14: {xxx, 0x14, Implied, 0, (*CPU).xxx},
-	0x34: {xxx, 0x34, Implied, 0, (*CPU).xxx},
-	0x44: {xxx, 0x44, Implied, 0, (*CPU).xxx},
-	0x54: {xxx, 0x54, Implied, 0, (*CPU).xxx},
-	0x64: {xxx, 0x64, Implied, 0, (*CPU).xxx},
-	0x74: {xxx, 0x74, Implied, 0, (*CPU).xxx},
-	0xD4: {xxx, 0xD4, Implied, 0, (*CPU).xxx},
-	0xF4: {xxx, 0xF4, Implied, 0, (*CPU).xxx},
-
-	0x07: {xxx, 0x07, Implied, 0, (*CPU).xxx},
-	0x17: {xxx, 0x17, Implied, 0, (*CPU).xxx},
-	0x27: {xxx, 0x27, Implied, 0, (*CPU).xxx},
-	0x37: {xxx, 0x37, Implied, 0, (*CPU).xxx},
-	0x47: {xxx, 0x47, Implied, 0, (*CPU).xxx},
-	0x57: {xxx, 0x57, Implied, 0, (*CPU).xxx},
-	0x67: {xxx, 0x67, Implied, 0, (*CPU).xxx},
-	0x77: {xxx, 0x77, Implied, 0, (*CPU).xxx},
-	0x87: {xxx, 0x87, Implied, 0, (*CPU).xxx},
-	0x97: {xxx, 0x97, Implied, 0, (*CPU).xxx},
-	0xA7: {xxx, 0xA7, Implied, 0, (*CPU).xxx},
-	0xB7: {xxx, 0xB7, Implied, 0, (*CPU).xxx},
-	0xC7: {xxx, 0xC7, Implied, 0, (*CPU).xxx},
-	0xD7: {xxx, 0xD7, Implied, 0, (*CPU).xxx},
-	0xE7: {xxx, 0xE7, Implied, 0, (*CPU).xxx},
-	0xF7: {xxx, 0xF7, Implied, 0, (*CPU).xxx},
-
-	0x89: {xxx, 0x88, Implied, 0, (*CPU).xxx},
-
-	0x1A: {xxx, 0x1A, Implied, 0, (*CPU).xxx},
-	0x3A: {xxx, 0x3A, Implied, 0, (*CPU).xxx},
-	0x5A: {xxx, 0x5A, Implied, 0, (*CPU).xxx},
-	0x7A: {xxx, 0x7A, Implied, 0, (*CPU).xxx},
-	0xDA: {xxx, 0xDA, Implied, 0, (*CPU).xxx},
-	0xFA: {xxx, 0xFA, Implied, 0, (*CPU).xxx},
-
-	0x0B: {xxx, 0x0B, Implied, 0, (*CPU).xxx},
-	0x1B: {xxx, 0x1B, Implied, 0, (*CPU).xxx},
-	0x2B: {xxx, 0x2B, Implied, 0, (*CPU).xxx},
-	0x3B: {xxx, 0x3B, Implied, 0, (*CPU).xxx},
-	0x4B: {xxx, 0x4B, Implied, 0, (*CPU).xxx},
-	0x5B: {xxx, 0x5B, Implied, 0, (*CPU).xxx},
-	0x6B: {xxx, 0x6B, Implied, 0, (*CPU).xxx},
-	0x7B: {xxx, 0x7B, Implied, 0, (*CPU).xxx},
-	0x8B: {xxx, 0x8B, Implied, 0, (*CPU).xxx},
-	0x9B: {xxx, 0x9B, Implied, 0, (*CPU).xxx},
-	0xAB: {xxx, 0xAB, Implied, 0, (*CPU).xxx},
-	0xBB: {xxx, 0xBB, Implied, 0, (*CPU).xxx},
-	0xCB: {xxx, 0xCB, Implied, 0, (*CPU).xxx},
-	0xDB: {xxx, 0xDB, Implied, 0, (*CPU).xxx},
-	0xEB: {xxx, 0xEB, Implied, 0, (*CPU).xxx},
-	0xFB: {xxx, 0xFB, Implied, 0, (*CPU).xxx},
-
-	0x0C: {xxx, 0x0C, Implied, 0, (*CPU).xxx},
-	0x1C: {xxx, 0x1C, Implied, 0, (*CPU).xxx},
-	0x3C: {xxx, 0x3C, Implied, 0, (*CPU).xxx},
-	0x5C: {xxx, 0x5C, Implied, 0, (*CPU).xxx},
-	0x7C: {xxx, 0x7C, Implied, 0, (*CPU).xxx},
-	0x9C: {xxx, 0x9C, Implied, 0, (*CPU).xxx},
-	0xDC: {xxx, 0xDC, Implied, 0, (*CPU).xxx},
-	0xFC: {xxx, 0xFC, Implied, 0, (*CPU).xxx},
-
-	0x9E: {xxx, 0x9E, Implied, 0, (*CPU).xxx},
-
-	0x0F: {xxx, 0x0F, Implied, 0, (*CPU).xxx},
-	0x1F: {xxx, 0x1F, Implied, 0, (*CPU).xxx},
-	0x2F: {xxx, 0x2F, Implied, 0, (*CPU).xxx},
-	0x3F: {xxx, 0x3F, Implied, 0, (*CPU).xxx},
-	0x4F: {xxx, 0x4F, Implied, 0, (*CPU).xxx},
-	0x5F: {xxx, 0x5F, Implied, 0, (*CPU).xxx},
-	0x6F: {xxx, 0x6F, Implied, 0, (*CPU).xxx},
-	0x7F: {xxx, 0x7F, Implied, 0, (*CPU).xxx},
-	0x8F: {xxx, 0x8F, Implied, 0, (*CPU).xxx},
-	0x9F: {xxx, 0x9F, Implied, 0, (*CPU).xxx},
-	0xAF: {xxx, 0xAF, Implied, 0, (*CPU).xxx},
-	0xBF: {xxx, 0xBF, Implied, 0, (*CPU).xxx},
-	0xCF: {xxx, 0xCF, Implied, 0, (*CPU).xxx},
-	0xDF: {xxx, 0xDF, Implied, 0, (*CPU).xxx},
-	0xEF: {xxx, 0xEF, Implied, 0, (*CPU).xxx},
-	0xFF: {xxx, 0xFF, Implied, 0, (*CPU).xxx},
-}
-
-// placeholder for illegal instructions
-func (c *CPU) xxx() uint8 {
-	// Panic
-	panic("Illegal or unsupported instruction")
+	// Undocumented NMOS instructions
+	0x4B: {alr, 0x4B, Immediate, 2, (*CPU).alr},
+	0x0B: {anc, 0x0B, Immediate, 2, (*CPU).anc},
+	0x2B: {anc, 0x2B, Immediate, 2, (*CPU).anc},
+	0x8B: {ane, 0x8B, Immediate, 2, (*CPU).ane},
+	0x6B: {arr, 0x6B, Immediate, 2, (*CPU).arr},
+	0xC7: {dcp, 0xC7, ZeroPage, 5, (*CPU).dcp},
+	0xD7: {dcp, 0xD7, ZeroPageX, 6, (*CPU).dcp},
+	0xCF: {dcp, 0xCF, Absolute, 6, (*CPU).dcp},
+	0xDF: {dcp, 0xDF, AbsoluteX, 7, (*CPU).dcp},
+	0xDB: {dcp, 0xDB, AbsoluteY, 7, (*CPU).dcp},
+	0xC3: {dcp, 0xC3, IndexedIndirect, 8, (*CPU).dcp},
+	0xD3: {dcp, 0xD3, IndirectIndexed, 8, (*CPU).dcp},
+	0xE7: {isc, 0xE7, ZeroPage, 5, (*CPU).isc},
+	0xF7: {isc, 0xF7, ZeroPageX, 6, (*CPU).isc},
+	0xEF: {isc, 0xEF, Absolute, 6, (*CPU).isc},
+	0xFF: {isc, 0xFF, AbsoluteX, 7, (*CPU).isc},
+	0xFB: {isc, 0xFB, AbsoluteY, 7, (*CPU).isc},
+	0xE3: {isc, 0xE3, IndexedIndirect, 8, (*CPU).isc},
+	0xF3: {isc, 0xF3, IndirectIndexed, 8, (*CPU).isc},
+	0xBB: {las, 0xBB, AbsoluteY, 4, (*CPU).las},
+	0xA7: {lax, 0xA7, ZeroPage, 3, (*CPU).lax},
+	0xB7: {lax, 0xB7, ZeroPageY, 4, (*CPU).lax},
+	0xAF: {lax, 0xAF, Absolute, 4, (*CPU).lax},
+	0xBF: {lax, 0xBF, AbsoluteY, 4, (*CPU).lax},
+	0xA3: {lax, 0xA3, IndexedIndirect, 6, (*CPU).lax},
+	0xB3: {lax, 0xB3, IndirectIndexed, 5, (*CPU).lax},
+	0xAB: {lxa, 0xAB, Immediate, 2, (*CPU).lxa},
+	0x27: {rla, 0x27, ZeroPage, 5, (*CPU).rla},
+	0x37: {rla, 0x37, ZeroPageX, 6, (*CPU).rla},
+	0x2F: {rla, 0x2F, Absolute, 6, (*CPU).rla},
+	0x3F: {rla, 0x3F, AbsoluteX, 7, (*CPU).rla},
+	0x3B: {rla, 0x3B, AbsoluteY, 7, (*CPU).rla},
+	0x23: {rla, 0x23, IndexedIndirect, 8, (*CPU).rla},
+	0x33: {rla, 0x33, IndirectIndexed, 8, (*CPU).rla},
+	0x67: {rra, 0x67, ZeroPage, 5, (*CPU).rra},
+	0x77: {rra, 0x77, ZeroPageX, 6, (*CPU).rra},
+	0x6F: {rra, 0x6F, Absolute, 6, (*CPU).rra},
+	0x7F: {rra, 0x7F, AbsoluteX, 7, (*CPU).rra},
+	0x7B: {rra, 0x7B, AbsoluteY, 7, (*CPU).rra},
+	0x63: {rra, 0x63, IndexedIndirect, 8, (*CPU).rra},
+	0x73: {rra, 0x73, IndirectIndexed, 8, (*CPU).rra},
+	0x87: {sax, 0x87, ZeroPage, 3, (*CPU).sax},
+	0x97: {sax, 0x97, ZeroPageY, 4, (*CPU).sax},
+	0x8F: {sax, 0x8F, Absolute, 4, (*CPU).sax},
+	0x83: {sax, 0x83, IndexedIndirect, 6, (*CPU).sax},
+	0xEB: {sbc, 0xEB, Immediate, 2, (*CPU).sbc},
+	0xCB: {sbx, 0xCB, Immediate, 2, (*CPU).sbx},
+	0x9F: {sha, 0x9F, AbsoluteY, 5, (*CPU).sha},
+	0x93: {sha, 0x93, IndirectIndexed, 6, (*CPU).sha},
+	0x9E: {shx, 0x9E, AbsoluteY, 5, (*CPU).shx},
+	0x9C: {shy, 0x9C, AbsoluteX, 5, (*CPU).shy},
+	0x07: {slo, 0x07, ZeroPage, 5, (*CPU).slo},
+	0x17: {slo, 0x17, ZeroPageX, 6, (*CPU).slo},
+	0x0F: {slo, 0x0F, Absolute, 6, (*CPU).slo},
+	0x1F: {slo, 0x1F, AbsoluteX, 7, (*CPU).slo},
+	0x1B: {slo, 0x1B, AbsoluteY, 7, (*CPU).slo},
+	0x03: {slo, 0x03, IndexedIndirect, 8, (*CPU).slo},
+	0x13: {slo, 0x13, IndirectIndexed, 8, (*CPU).slo},
+	0x47: {sre, 0x47, ZeroPage, 5, (*CPU).sre},
+	0x57: {sre, 0x57, ZeroPageX, 6, (*CPU).sre},
+	0x4F: {sre, 0x4F, Absolute, 6, (*CPU).sre},
+	0x5F: {sre, 0x5F, AbsoluteX, 7, (*CPU).sre},
+	0x5B: {sre, 0x5B, AbsoluteY, 7, (*CPU).sre},
+	0x43: {sre, 0x43, IndexedIndirect, 8, (*CPU).sre},
+	0x53: {sre, 0x53, IndirectIndexed, 8, (*CPU).sre},
+	0x9B: {tas, 0x9B, AbsoluteY, 5, (*CPU).tas},
+
+	// Undocumented NOPs, which still read their operands
+	0x1A: {nop, 0x1A, Implied, 2, (*CPU).nop},
+	0x3A: {nop, 0x3A, Implied, 2, (*CPU).nop},
+	0x5A: {nop, 0x5A, Implied, 2, (*CPU).nop},
+	0x7A: {nop, 0x7A, Implied, 2, (*CPU).nop},
+	0xDA: {nop, 0xDA, Implied, 2, (*CPU).nop},
+	0xFA: {nop, 0xFA, Implied, 2, (*CPU).nop},
+	0x80: {nop, 0x80, Immediate, 2, (*CPU).nop},
+	0x82: {nop, 0x82, Immediate, 2, (*CPU).nop},
+	0x89: {nop, 0x89, Immediate, 2, (*CPU).nop},
+	0xC2: {nop, 0xC2, Immediate, 2, (*CPU).nop},
+	0xE2: {nop, 0xE2, Immediate, 2, (*CPU).nop},
+	0x04: {nop, 0x04, ZeroPage, 3, (*CPU).nop},
+	0x44: {nop, 0x44, ZeroPage, 3, (*CPU).nop},
+	0x64: {nop, 0x64, ZeroPage, 3, (*CPU).nop},
+	0x14: {nop, 0x14, ZeroPageX, 4, (*CPU).nop},
+	0x34: {nop, 0x34, ZeroPageX, 4, (*CPU).nop},
+	0x54: {nop, 0x54, ZeroPageX, 4, (*CPU).nop},
+	0x74: {nop, 0x74, ZeroPageX, 4, (*CPU).nop},
+	0xD4: {nop, 0xD4, ZeroPageX, 4, (*CPU).nop},
+	0xF4: {nop, 0xF4, ZeroPageX, 4, (*CPU).nop},
+	0x0C: {nop, 0x0C, Absolute, 4, (*CPU).nop},
+	0x1C: {nop, 0x1C, AbsoluteX, 4, (*CPU).nop},
+	0x3C: {nop, 0x3C, AbsoluteX, 4, (*CPU).nop},
+	0x5C: {nop, 0x5C, AbsoluteX, 4, (*CPU).nop},
+	0x7C: {nop, 0x7C, AbsoluteX, 4, (*CPU).nop},
+	0xDC: {nop, 0xDC, AbsoluteX, 4, (*CPU).nop},
+	0xFC: {nop, 0xFC, AbsoluteX, 4, (*CPU).nop},
+
+	// JAM locks up the CPU until it is reset
+	0x02: {jam, 0x02, Implied, 2, (*CPU).jam},
+	0x12: {jam, 0x12, Implied, 2, (*CPU).jam},
+	0x22: {jam, 0x22, Implied, 2, (*CPU).jam},
+	0x32: {jam, 0x32, Implied, 2, (*CPU).jam},
+	0x42: {jam, 0x42, Implied, 2, (*CPU).jam},
+	0x52: {jam, 0x52, Implied, 2, (*CPU).jam},
+	0x62: {jam, 0x62, Implied, 2, (*CPU).jam},
+	0x72: {jam, 0x72, Implied, 2, (*CPU).jam},
+	0x92: {jam, 0x92, Implied, 2, (*CPU).jam},
+	0xB2: {jam, 0xB2, Implied, 2, (*CPU).jam},
+	0xD2: {jam, 0xD2, Implied, 2, (*CPU).jam},
+	0xF2: {jam, 0xF2, Implied, 2, (*CPU).jam},
 }
 
-// adc adds with carry
-func (c *CPU) adc() uint8 {
-	var extraCycle uint8 = 0
-
-	// Fetch the next byte
-	c.fetchByte()
+// jam halts the CPU until it is reset
+func (c *CPU) jam() uint8 {
+	// Stop fetching instructions
+	c.status.halted = true
 
-	// Add the fetched byte to the accumulator
-	c.i.temp = uint16(c.r.a) + uint16(c.i.fetched)
-
-	// Add the carry flag
-	if c.getFlag(Carry) {
-		c.i.temp++
+	// Let anyone interested know
+	if c.onHalt != nil {
+		c.onHalt(c.r.pc-1, c.i.opcode)
 	}
 
-	// Set the Z flag if the result is zero
-	c.setFlag(Zero, c.i.temp&0x00FF == 0)
-
-	// Are we in decimal mode?
-	if c.getFlag(Decimal) {
-		// If the result is greater than 99, add 96 to it
-		if (c.r.a&0x0F)+(c.i.fetched&0x0F)+uint8(func() uint8 {
-			if c.getFlag(Carry) {
-				return 1
-			}
-			return 0
-		}()) > 9 {
-			c.i.temp += 96 + uint16(func() uint8 {
-				if c.getFlag(Carry) {
-					return 1
-				}
-				return 0
-			}())
-		}
-
-		// Set the negative flag if the result is negative
-		c.setFlag(Negative, c.i.temp&0x80 != 0)
-
-		// Set the overflow flag if the result is greater than 127 or less than -128
-		c.setFlag(Overflow, (c.r.a^uint8(c.i.temp))&(uint8(c.i.fetched)^uint8(c.i.temp))&0x80 != 0)
-
-		// If the result is greater than 99, add 96 to it
-		if c.i.temp > 99 {
-			c.i.temp += 96
-		}
-
-		// Set the carry flag if the result is greater than 99
-		c.setFlag(Carry, c.i.temp > 99)
+	return 0
+}
 
-		// We used an extra cycle
-		extraCycle++
-	}
+// adc adds with carry
+func (c *CPU) adc() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
 
-	// Store the result in the accumulator
-	c.r.a = uint8(c.i.temp & 0x00FF)
+	// Add the fetched byte and the carry flag to the accumulator
+	c.addWithCarry(c.i.fetched)
 
-	// Return the number of extra cycles
-	return extraCycle
+	return 1
 }
 
 // and ands with accumulator
@@ -515,75 +662,45 @@ func (c *CPU) asl() uint8 {
 	return 0
 }
 
-// bcc branches if carry clear
-func (c *CPU) bcc() uint8 {
-	extraCycles := uint8(0)
-	// If the carry flag is not set, branch
-	if !c.getFlag(Carry) {
+// branch moves the program counter to the relative address if condition
+// holds. A taken branch costs an extra cycle, plus another if it lands on a
+// different page, which are added to the instruction directly.
+func (c *CPU) branch(condition bool) uint8 {
+	if condition {
 		// We branched, so add a cycle
-		extraCycles++
+		c.status.Cycles++
 
 		// Calculate the new address
 		c.i.addr_absolute = c.r.pc + c.i.addr_relative
 
-		// If the new address crosses a page boundary, add another cycle
+		// If the new address crosses a page boundary, add another cycle.
+		// Otherwise the branch skips its last interrupt poll.
 		if (c.i.addr_absolute & 0xFF00) != (c.r.pc & 0xFF00) {
-			extraCycles++
+			c.status.Cycles++
+		} else {
+			c.i.branchDelay = true
 		}
 
 		// Set the program counter to the new address
 		c.r.pc = c.i.addr_absolute
 	}
 
-	return extraCycles
+	return 0
+}
+
+// bcc branches if carry clear
+func (c *CPU) bcc() uint8 {
+	return c.branch(!c.getFlag(Carry))
 }
 
 // bcs branches if carry set
 func (c *CPU) bcs() uint8 {
-	extraCycles := uint8(0)
-
-	// If the carry flag is set, branch
-	if c.getFlag(Carry) {
-		// We branched, so add a cycle
-		extraCycles++
-
-		// Calculate the new address
-		c.i.addr_absolute = c.r.pc + c.i.addr_relative
-
-		// If the new address crosses a page boundary, add another cycle
-		if (c.i.addr_absolute & 0xFF00) != (c.r.pc & 0xFF00) {
-			extraCycles++
-		}
-
-		// Set the program counter to the new address
-		c.r.pc = c.i.addr_absolute
-	}
-
-	return extraCycles
+	return c.branch(c.getFlag(Carry))
 }
 
 // beq branches if equal
 func (c *CPU) beq() uint8 {
-	extraCycles := uint8(0)
-
-	// If the zero flag is set, branch
-	if c.getFlag(Zero) {
-		// We branched, so add a cycle
-		extraCycles++
-
-		// Calculate the new address
-		c.i.addr_absolute = c.r.pc + c.i.addr_relative
-
-		// If the new address crosses a page boundary, add another cycle
-		if (c.i.addr_absolute & 0xFF00) != (c.r.pc & 0xFF00) {
-			extraCycles++
-		}
-
-		// Set the program counter to the new address
-		c.r.pc = c.i.addr_absolute
-	}
-
-	return extraCycles
+	return c.branch(c.getFlag(Zero))
 }
 
 // bit tests bits in memory with accumulator
@@ -597,159 +714,62 @@ func (c *CPU) bit() uint8 {
 	// Set the zero flag if the result is zero
 	c.setFlag(Zero, c.i.temp&0x00FF == 0)
 
+	// The 65C02's BIT # only affects the zero flag
+	if c.status.currentInstruction.Mode == Immediate {
+		return 1
+	}
+
 	// Set the negative flag if the 7th bit of the fetched byte is set
 	c.setFlag(Negative, c.i.fetched&(1<<7) != 0)
 
 	// Set the overflow flag if the 6th bit of the fetched byte is set
 	c.setFlag(Overflow, c.i.fetched&(1<<6) != 0)
 
-	return 0
+	return 1
 }
 
 // bmi branches if minus
 func (c *CPU) bmi() uint8 {
-	extraCycles := uint8(0)
-
-	// If the negative flag is set, branch
-	if c.getFlag(Negative) {
-		// We branched, so add a cycle
-		extraCycles++
-
-		// Calculate the new address
-		c.i.addr_absolute = c.r.pc + c.i.addr_relative
-
-		// If the new address crosses a page boundary, add another cycle
-		if (c.i.addr_absolute & 0xFF00) != (c.r.pc & 0xFF00) {
-			extraCycles++
-		}
-
-		// Set the program counter to the new address
-		c.r.pc = c.i.addr_absolute
-	}
-
-	return extraCycles
+	return c.branch(c.getFlag(Negative))
 }
 
 // bne branches if not equal
 func (c *CPU) bne() uint8 {
-	extraCycles := uint8(0)
-
-	// If the zero flag is not set, branch
-	if !c.getFlag(Zero) {
-		// We branched, so add a cycle
-		extraCycles++
-
-		// Calculate the new address
-		c.i.addr_absolute = c.r.pc + c.i.addr_relative
-
-		// If the new address crosses a page boundary, add another cycle
-		if (c.i.addr_absolute & 0xFF00) != (c.r.pc & 0xFF00) {
-			extraCycles++
-		}
-
-		// Set the program counter to the new address
-		c.r.pc = c.i.addr_absolute
-	}
-
-	return extraCycles
+	return c.branch(!c.getFlag(Zero))
 }
 
 // bpl branches if positive
 func (c *CPU) bpl() uint8 {
-	extraCycles := uint8(0)
-
-	// If the negative flag is not set, branch
-	if !c.getFlag(Negative) {
-		// We branched, so add a cycle
-		extraCycles++
-
-		// Calculate the new address
-		c.i.addr_absolute = c.r.pc + c.i.addr_relative
-
-		// If the new address crosses a page boundary, add another cycle
-		if (c.i.addr_absolute & 0xFF00) != (c.r.pc & 0xFF00) {
-			extraCycles++
-		}
-
-		// Set the program counter to the new address
-		c.r.pc = c.i.addr_absolute
-	}
-
-	return extraCycles
+	return c.branch(!c.getFlag(Negative))
 }
 
 // brk forces an interrupt
 func (c *CPU) brk() uint8 {
-	// increment the program counter
+	// Skip the padding byte following the opcode
 	c.r.pc++
 
-	// Set the interrupt disable flag to 1
-	c.setFlag(InterruptDisable, true)
-
 	// Push the PC to the stack
 	c.pushWord(c.r.pc)
 
-	// Set the break flag
-	c.setFlag(Break, true)
-
-	// Push the processor status to the stack
-	c.pushByte(c.r.p)
-
-	// Clear the break flag
-	c.setFlag(Break, false)
+	// Push the processor status to the stack with the break flag set, which
+	// also disables interrupts
+	c.i.vector = vectorIRQ
+	c.pushStatus(true)
 
 	// Set the PC to the data at the interrupt vector
-	c.r.pc = uint16(c.bus.Read(0xFFFE)) | uint16(c.bus.Read(0xFFFF))<<8
+	c.r.pc = uint16(c.bus.Read(c.i.vector)) | uint16(c.bus.Read(c.i.vector+1))<<8
 
 	return 0
 }
 
 // bvc branches if overflow clear
 func (c *CPU) bvc() uint8 {
-	extraCycles := uint8(0)
-
-	// If the overflow flag is not set, branch
-	if !c.getFlag(Overflow) {
-		// We branched, so add a cycle
-		extraCycles++
-
-		// Calculate the new address
-		c.i.addr_absolute = c.r.pc + c.i.addr_relative
-
-		// If the new address crosses a page boundary, add another cycle
-		if (c.i.addr_absolute & 0xFF00) != (c.r.pc & 0xFF00) {
-			extraCycles++
-		}
-
-		// Set the program counter to the new address
-		c.r.pc = c.i.addr_absolute
-	}
-
-	return extraCycles
+	return c.branch(!c.getFlag(Overflow))
 }
 
 // bvs branches if overflow set
 func (c *CPU) bvs() uint8 {
-	extraCycles := uint8(0)
-
-	// If the overflow flag is set, branch
-	if c.getFlag(Overflow) {
-		// We branched, so add a cycle
-		extraCycles++
-
-		// Calculate the new address
-		c.i.addr_absolute = c.r.pc + c.i.addr_relative
-
-		// If the new address crosses a page boundary, add another cycle
-		if (c.i.addr_absolute & 0xFF00) != (c.r.pc & 0xFF00) {
-			extraCycles++
-		}
-
-		// Set the program counter to the new address
-		c.r.pc = c.i.addr_absolute
-	}
-
-	return extraCycles
+	return c.branch(c.getFlag(Overflow))
 }
 
 // clc clears carry flag
@@ -852,8 +872,13 @@ func (c *CPU) dec() uint8 {
 	// decrement the fetched byte
 	c.i.temp = uint16(c.i.fetched) - 1
 
-	// Store the result in memory
-	c.bus.Write(c.i.addr_absolute, uint8(c.i.temp&0x00FF))
+	// If we are in accumulator mode (65C02), store the result in the accumulator
+	if c.status.currentInstruction.Mode == Accumulator {
+		c.r.a = uint8(c.i.temp & 0x00FF)
+	} else {
+		// Otherwise, store the result in memory
+		c.bus.Write(c.i.addr_absolute, uint8(c.i.temp&0x00FF))
+	}
 
 	// Set the zero flag if the result is zero
 	c.setFlag(Zero, c.i.temp&0x00FF == 0)
@@ -917,8 +942,13 @@ func (c *CPU) inc() uint8 {
 	// increment the fetched byte
 	c.i.temp = uint16(c.i.fetched) + 1
 
-	// Store the result in memory
-	c.bus.Write(c.i.addr_absolute, uint8(c.i.temp&0x00FF))
+	// If we are in accumulator mode (65C02), store the result in the accumulator
+	if c.status.currentInstruction.Mode == Accumulator {
+		c.r.a = uint8(c.i.temp & 0x00FF)
+	} else {
+		// Otherwise, store the result in memory
+		c.bus.Write(c.i.addr_absolute, uint8(c.i.temp&0x00FF))
+	}
 
 	// Set the zero flag if the result is zero
 	c.setFlag(Zero, c.i.temp&0x00FF == 0)
@@ -1042,8 +1072,10 @@ func (c *CPU) lsr() uint8 {
 
 // nop no operation
 func (c *CPU) nop() uint8 {
-	// Perform no operation
-	return 0
+	// Undocumented NOPs with an operand still read it
+	c.fetchByte()
+
+	return 1
 }
 
 // ora ors accumulator
@@ -1103,6 +1135,9 @@ func (c *CPU) plp() uint8 {
 	// Pop the status flags from the stack
 	c.r.p = c.popByte()
 
+	// The break flag only exists on the stack, so clear it
+	c.setFlag(Break, false)
+
 	// Set the unused flag
 	c.setFlag(Unused, true)
 
@@ -1193,8 +1228,8 @@ func (c *CPU) rti() uint8 {
 	// Clear the break flag
 	c.setFlag(Break, false)
 
-	// Clear the unused flag
-	c.setFlag(Unused, false)
+	// Set the unused flag
+	c.setFlag(Unused, true)
 
 	// Pop the program counter from the stack
 	c.r.pc = c.popWord()
@@ -1212,62 +1247,134 @@ func (c *CPU) rts() uint8 {
 
 // sbc subtracts with carry
 func (c *CPU) sbc() uint8 {
-	var extraCycle uint8 = 0
-
 	// Fetch the next byte
 	c.fetchByte()
 
-	// Subtract the fetched byte from the accumulator
-	c.i.temp = uint16(c.r.a) - uint16(c.i.fetched)
+	// Subtract the fetched byte and the borrow from the accumulator
+	c.subtractWithBorrow(c.i.fetched)
+
+	return 1
+}
 
-	// Subtract the carry flag
+// addWithCarry adds value and the carry flag to the accumulator, in BCD if the
+// decimal flag is set. On the NMOS 6502, decimal mode computes Z from the
+// binary sum and N and V from the high nibble before it is adjusted. The 65C02
+// takes an extra cycle to compute N and Z from the decimal result instead.
+func (c *CPU) addWithCarry(value uint8) {
+	var carry uint16 = 0
 	if c.getFlag(Carry) {
-		c.i.temp--
+		carry = 1
 	}
 
-	// Set the Z flag if the result is zero
+	// The zero flag always comes from the binary sum
+	c.i.temp = uint16(c.r.a) + uint16(value) + carry
 	c.setFlag(Zero, c.i.temp&0x00FF == 0)
 
-	// Are we in decimal mode?
-	if c.getFlag(Decimal) {
-		// If the result is greater than 99, subtract 96 from it
-		if (c.r.a&0x0F)-(c.i.fetched&0x0F)-uint8(func() uint8 {
-			if c.getFlag(Carry) {
-				return 0
-			}
-			return 1
-		}()) < uint8(0) {
-			c.i.temp -= 96 - uint16(func() uint8 {
-				if c.getFlag(Carry) {
-					return 0
-				}
-				return 1
-			}())
-		}
-
-		// Set the negative flag if the result is negative
+	// Binary mode is a plain 8-bit add
+	if !c.getFlag(Decimal) || !c.variant.decimalMode() {
+		c.setFlag(Carry, c.i.temp > 0xFF)
+		c.setFlag(Overflow, ^(uint16(c.r.a)^uint16(value))&(uint16(c.r.a)^c.i.temp)&0x80 != 0)
 		c.setFlag(Negative, c.i.temp&0x80 != 0)
+		c.r.a = uint8(c.i.temp & 0x00FF)
+		return
+	}
+
+	// Add the low nibbles, adjusting if the result isn't a decimal digit
+	var lo uint16 = uint16(c.r.a&0x0F) + uint16(value&0x0F) + carry
+	if lo > 0x09 {
+		lo += 0x06
+	}
 
-		// Set the overflow flag if the result is greater than 127 or less than -128
-		c.setFlag(Overflow, (c.r.a^uint8(c.i.temp))&(uint8(c.i.fetched)^uint8(c.i.temp))&0x80 != 0)
+	// Add the high nibbles and the carry out of the low nibble
+	var hi uint16 = uint16(c.r.a>>4) + uint16(value>>4)
+	if lo > 0x0F {
+		hi++
+	}
+
+	// N and V see the high nibble before it is adjusted
+	c.setFlag(Negative, hi&0x08 != 0)
+	c.setFlag(Overflow, ^(uint16(c.r.a)^uint16(value))&(uint16(c.r.a)^(hi<<4))&0x80 != 0)
+
+	// Adjust the high nibble, which also produces the carry
+	if hi > 0x09 {
+		hi += 0x06
+	}
+	c.setFlag(Carry, hi > 0x0F)
 
-		// Adjust the result for BCD
-		if c.i.temp > 99 {
-			c.i.temp -= 96
+	// Store the result in the accumulator
+	c.r.a = uint8(hi<<4 | lo&0x0F)
+
+	if c.variant.cmos() {
+		c.decimalFlags()
+	}
+}
+
+// subtractWithBorrow subtracts value and the inverted carry flag from the
+// accumulator, in BCD if the decimal flag is set. On the NMOS 6502, all flags
+// come from the binary difference even in decimal mode. The 65C02 adjusts the
+// difference slightly differently and takes an extra cycle to compute N and Z
+// from the decimal result.
+func (c *CPU) subtractWithBorrow(value uint8) {
+	var borrow uint16 = 0
+	if !c.getFlag(Carry) {
+		borrow = 1
+	}
+
+	// Binary difference, which wraps around on a borrow
+	c.i.temp = uint16(c.r.a) - uint16(value) - borrow
+
+	// Set the flags from the binary difference
+	c.setFlag(Carry, c.i.temp < 0x100)
+	c.setFlag(Zero, c.i.temp&0x00FF == 0)
+	c.setFlag(Overflow, (uint16(c.r.a)^uint16(value))&(uint16(c.r.a)^c.i.temp)&0x80 != 0)
+	c.setFlag(Negative, c.i.temp&0x80 != 0)
+
+	// Binary mode is a plain 8-bit subtract
+	if !c.getFlag(Decimal) || !c.variant.decimalMode() {
+		c.r.a = uint8(c.i.temp & 0x00FF)
+		return
+	}
+
+	if c.variant.cmos() {
+		// Adjust the whole difference, then the low nibble if it borrowed
+		var lo int = int(c.r.a&0x0F) - int(value&0x0F) - int(borrow)
+		var result int = int(c.r.a) - int(value) - int(borrow)
+		if result < 0 {
+			result -= 0x60
+		}
+		if lo < 0 {
+			result -= 0x06
 		}
 
-		// Set the carry flag if the result is greater than 99
-		c.setFlag(Carry, c.i.temp > 99)
+		// Store the result in the accumulator
+		c.r.a = uint8(result)
 
-		// We used an extra cycle
-		extraCycle++
+		c.decimalFlags()
+		return
+	}
+
+	// Subtract nibble by nibble, adjusting each one that borrowed
+	var lo int = int(c.r.a&0x0F) - int(value&0x0F) - int(borrow)
+	var hi int = int(c.r.a>>4) - int(value>>4)
+	if lo < 0 {
+		lo -= 0x06
+		hi--
+	}
+	if hi < 0 {
+		hi -= 0x06
 	}
 
 	// Store the result in the accumulator
-	c.r.a = uint8(c.i.temp & 0x00FF)
+	c.r.a = uint8(hi<<4 | lo&0x0F)
+}
+
+// decimalFlags sets N and Z from the accumulator after a 65C02 decimal mode
+// ADC or SBC, which costs the extra cycle the 65C02 needs to do so
+func (c *CPU) decimalFlags() {
+	c.setFlag(Zero, c.r.a == 0)
+	c.setFlag(Negative, c.r.a&0x80 != 0)
 
-	// Return the number of extra cycles
-	return extraCycle
+	c.status.Cycles++
 }
 
 // sec sets carry flag
@@ -1323,6 +1430,10 @@ func (c *CPU) tax() uint8 {
 	// Load the accumulator into the X register
 	c.r.x = c.r.a
 
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.x == 0)
+	c.setFlag(Negative, c.r.x&0x80 != 0)
+
 	return 0
 }
 
@@ -1331,6 +1442,10 @@ func (c *CPU) tay() uint8 {
 	// Load the accumulator into the Y register
 	c.r.y = c.r.a
 
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.y == 0)
+	c.setFlag(Negative, c.r.y&0x80 != 0)
+
 	return 0
 }
 
@@ -1339,6 +1454,10 @@ func (c *CPU) tsx() uint8 {
 	// Load the stack pointer into the X register
 	c.r.x = c.r.sp
 
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.x == 0)
+	c.setFlag(Negative, c.r.x&0x80 != 0)
+
 	return 0
 }
 
@@ -1347,6 +1466,10 @@ func (c *CPU) txa() uint8 {
 	// Load the X register into the accumulator
 	c.r.a = c.r.x
 
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.a == 0)
+	c.setFlag(Negative, c.r.a&0x80 != 0)
+
 	return 0
 }
 
@@ -1363,5 +1486,9 @@ func (c *CPU) tya() uint8 {
 	// Load the Y register into the accumulator
 	c.r.a = c.r.y
 
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.a == 0)
+	c.setFlag(Negative, c.r.a&0x80 != 0)
+
 	return 0
 }
@@ -0,0 +1,1247 @@
+package goemu6502
+
+// InstructionInfo816 contains information about a 65C816 instruction. Cycles
+// is the count with 8-bit registers and memory, a page aligned direct page and
+// no page crossings; the instructions add whatever their operands cost.
+type InstructionInfo816 struct {
+	Instruction Instruction
+	Opcode      uint8
+	Mode        AddressingMode
+	Cycles      uint8
+	Execute     func(*CPU816) uint8
+}
+
+// Instructions816 is the instruction table for the 65C816. Every opcode
+// is documented. The 65C02 addressing mode names are reused for their direct
+// page counterparts: ZeroPage is dp, ZeroPageIndirect is (dp) and so on.
+var Instructions816 = [256]InstructionInfo816{
+	0x61: {adc, 0x61, IndexedIndirect, 6, (*CPU816).adc},
+	0x63: {adc, 0x63, StackRelative, 4, (*CPU816).adc},
+	0x65: {adc, 0x65, ZeroPage, 3, (*CPU816).adc},
+	0x67: {adc, 0x67, DirectIndirectLong, 6, (*CPU816).adc},
+	0x69: {adc, 0x69, Immediate, 2, (*CPU816).adc},
+	0x6D: {adc, 0x6D, Absolute, 4, (*CPU816).adc},
+	0x6F: {adc, 0x6F, AbsoluteLong, 5, (*CPU816).adc},
+	0x71: {adc, 0x71, IndirectIndexed, 5, (*CPU816).adc},
+	0x72: {adc, 0x72, ZeroPageIndirect, 5, (*CPU816).adc},
+	0x73: {adc, 0x73, StackRelativeIndirectY, 7, (*CPU816).adc},
+	0x75: {adc, 0x75, ZeroPageX, 4, (*CPU816).adc},
+	0x77: {adc, 0x77, DirectIndirectLongY, 6, (*CPU816).adc},
+	0x79: {adc, 0x79, AbsoluteY, 4, (*CPU816).adc},
+	0x7D: {adc, 0x7D, AbsoluteX, 4, (*CPU816).adc},
+	0x7F: {adc, 0x7F, AbsoluteLongX, 5, (*CPU816).adc},
+	0x21: {and, 0x21, IndexedIndirect, 6, (*CPU816).and},
+	0x23: {and, 0x23, StackRelative, 4, (*CPU816).and},
+	0x25: {and, 0x25, ZeroPage, 3, (*CPU816).and},
+	0x27: {and, 0x27, DirectIndirectLong, 6, (*CPU816).and},
+	0x29: {and, 0x29, Immediate, 2, (*CPU816).and},
+	0x2D: {and, 0x2D, Absolute, 4, (*CPU816).and},
+	0x2F: {and, 0x2F, AbsoluteLong, 5, (*CPU816).and},
+	0x31: {and, 0x31, IndirectIndexed, 5, (*CPU816).and},
+	0x32: {and, 0x32, ZeroPageIndirect, 5, (*CPU816).and},
+	0x33: {and, 0x33, StackRelativeIndirectY, 7, (*CPU816).and},
+	0x35: {and, 0x35, ZeroPageX, 4, (*CPU816).and},
+	0x37: {and, 0x37, DirectIndirectLongY, 6, (*CPU816).and},
+	0x39: {and, 0x39, AbsoluteY, 4, (*CPU816).and},
+	0x3D: {and, 0x3D, AbsoluteX, 4, (*CPU816).and},
+	0x3F: {and, 0x3F, AbsoluteLongX, 5, (*CPU816).and},
+	0x06: {asl, 0x06, ZeroPage, 5, (*CPU816).asl},
+	0x0A: {asl, 0x0A, Accumulator, 2, (*CPU816).asl},
+	0x0E: {asl, 0x0E, Absolute, 6, (*CPU816).asl},
+	0x16: {asl, 0x16, ZeroPageX, 6, (*CPU816).asl},
+	0x1E: {asl, 0x1E, AbsoluteX, 7, (*CPU816).asl},
+	0x90: {bcc, 0x90, Relative, 2, (*CPU816).bcc},
+	0xB0: {bcs, 0xB0, Relative, 2, (*CPU816).bcs},
+	0xF0: {beq, 0xF0, Relative, 2, (*CPU816).beq},
+	0x24: {bit, 0x24, ZeroPage, 3, (*CPU816).bit},
+	0x2C: {bit, 0x2C, Absolute, 4, (*CPU816).bit},
+	0x34: {bit, 0x34, ZeroPageX, 4, (*CPU816).bit},
+	0x3C: {bit, 0x3C, AbsoluteX, 4, (*CPU816).bit},
+	0x89: {bit, 0x89, Immediate, 2, (*CPU816).bit},
+	0x30: {bmi, 0x30, Relative, 2, (*CPU816).bmi},
+	0xD0: {bne, 0xD0, Relative, 2, (*CPU816).bne},
+	0x10: {bpl, 0x10, Relative, 2, (*CPU816).bpl},
+	0x80: {bra, 0x80, Relative, 2, (*CPU816).bra},
+	0x00: {brk, 0x00, Immediate, 7, (*CPU816).brk},
+	0x82: {brl, 0x82, RelativeLong, 4, (*CPU816).brl},
+	0x50: {bvc, 0x50, Relative, 2, (*CPU816).bvc},
+	0x70: {bvs, 0x70, Relative, 2, (*CPU816).bvs},
+	0x18: {clc, 0x18, Implied, 2, (*CPU816).clc},
+	0xD8: {cld, 0xD8, Implied, 2, (*CPU816).cld},
+	0x58: {cli, 0x58, Implied, 2, (*CPU816).cli},
+	0xB8: {clv, 0xB8, Implied, 2, (*CPU816).clv},
+	0xC1: {cmp, 0xC1, IndexedIndirect, 6, (*CPU816).cmp},
+	0xC3: {cmp, 0xC3, StackRelative, 4, (*CPU816).cmp},
+	0xC5: {cmp, 0xC5, ZeroPage, 3, (*CPU816).cmp},
+	0xC7: {cmp, 0xC7, DirectIndirectLong, 6, (*CPU816).cmp},
+	0xC9: {cmp, 0xC9, Immediate, 2, (*CPU816).cmp},
+	0xCD: {cmp, 0xCD, Absolute, 4, (*CPU816).cmp},
+	0xCF: {cmp, 0xCF, AbsoluteLong, 5, (*CPU816).cmp},
+	0xD1: {cmp, 0xD1, IndirectIndexed, 5, (*CPU816).cmp},
+	0xD2: {cmp, 0xD2, ZeroPageIndirect, 5, (*CPU816).cmp},
+	0xD3: {cmp, 0xD3, StackRelativeIndirectY, 7, (*CPU816).cmp},
+	0xD5: {cmp, 0xD5, ZeroPageX, 4, (*CPU816).cmp},
+	0xD7: {cmp, 0xD7, DirectIndirectLongY, 6, (*CPU816).cmp},
+	0xD9: {cmp, 0xD9, AbsoluteY, 4, (*CPU816).cmp},
+	0xDD: {cmp, 0xDD, AbsoluteX, 4, (*CPU816).cmp},
+	0xDF: {cmp, 0xDF, AbsoluteLongX, 5, (*CPU816).cmp},
+	0x02: {cop, 0x02, Immediate, 7, (*CPU816).cop},
+	0xE0: {cpx, 0xE0, Immediate, 2, (*CPU816).cpx},
+	0xE4: {cpx, 0xE4, ZeroPage, 3, (*CPU816).cpx},
+	0xEC: {cpx, 0xEC, Absolute, 4, (*CPU816).cpx},
+	0xC0: {cpy, 0xC0, Immediate, 2, (*CPU816).cpy},
+	0xC4: {cpy, 0xC4, ZeroPage, 3, (*CPU816).cpy},
+	0xCC: {cpy, 0xCC, Absolute, 4, (*CPU816).cpy},
+	0x3A: {dec, 0x3A, Accumulator, 2, (*CPU816).dec},
+	0xC6: {dec, 0xC6, ZeroPage, 5, (*CPU816).dec},
+	0xCE: {dec, 0xCE, Absolute, 6, (*CPU816).dec},
+	0xD6: {dec, 0xD6, ZeroPageX, 6, (*CPU816).dec},
+	0xDE: {dec, 0xDE, AbsoluteX, 7, (*CPU816).dec},
+	0xCA: {dex, 0xCA, Implied, 2, (*CPU816).dex},
+	0x88: {dey, 0x88, Implied, 2, (*CPU816).dey},
+	0x41: {eor, 0x41, IndexedIndirect, 6, (*CPU816).eor},
+	0x43: {eor, 0x43, StackRelative, 4, (*CPU816).eor},
+	0x45: {eor, 0x45, ZeroPage, 3, (*CPU816).eor},
+	0x47: {eor, 0x47, DirectIndirectLong, 6, (*CPU816).eor},
+	0x49: {eor, 0x49, Immediate, 2, (*CPU816).eor},
+	0x4D: {eor, 0x4D, Absolute, 4, (*CPU816).eor},
+	0x4F: {eor, 0x4F, AbsoluteLong, 5, (*CPU816).eor},
+	0x51: {eor, 0x51, IndirectIndexed, 5, (*CPU816).eor},
+	0x52: {eor, 0x52, ZeroPageIndirect, 5, (*CPU816).eor},
+	0x53: {eor, 0x53, StackRelativeIndirectY, 7, (*CPU816).eor},
+	0x55: {eor, 0x55, ZeroPageX, 4, (*CPU816).eor},
+	0x57: {eor, 0x57, DirectIndirectLongY, 6, (*CPU816).eor},
+	0x59: {eor, 0x59, AbsoluteY, 4, (*CPU816).eor},
+	0x5D: {eor, 0x5D, AbsoluteX, 4, (*CPU816).eor},
+	0x5F: {eor, 0x5F, AbsoluteLongX, 5, (*CPU816).eor},
+	0x1A: {inc, 0x1A, Accumulator, 2, (*CPU816).inc},
+	0xE6: {inc, 0xE6, ZeroPage, 5, (*CPU816).inc},
+	0xEE: {inc, 0xEE, Absolute, 6, (*CPU816).inc},
+	0xF6: {inc, 0xF6, ZeroPageX, 6, (*CPU816).inc},
+	0xFE: {inc, 0xFE, AbsoluteX, 7, (*CPU816).inc},
+	0xE8: {inx, 0xE8, Implied, 2, (*CPU816).inx},
+	0xC8: {iny, 0xC8, Implied, 2, (*CPU816).iny},
+	0x5C: {jml, 0x5C, AbsoluteLong, 4, (*CPU816).jml},
+	0xDC: {jml, 0xDC, AbsoluteIndirectLong, 6, (*CPU816).jml},
+	0x4C: {jmp, 0x4C, Absolute, 3, (*CPU816).jmp},
+	0x6C: {jmp, 0x6C, Indirect, 5, (*CPU816).jmp},
+	0x7C: {jmp, 0x7C, AbsoluteIndexedIndirect, 6, (*CPU816).jmp},
+	0x22: {jsl, 0x22, AbsoluteLong, 8, (*CPU816).jsl},
+	0x20: {jsr, 0x20, Absolute, 6, (*CPU816).jsr},
+	0xFC: {jsr, 0xFC, AbsoluteIndexedIndirect, 8, (*CPU816).jsr},
+	0xA1: {lda, 0xA1, IndexedIndirect, 6, (*CPU816).lda},
+	0xA3: {lda, 0xA3, StackRelative, 4, (*CPU816).lda},
+	0xA5: {lda, 0xA5, ZeroPage, 3, (*CPU816).lda},
+	0xA7: {lda, 0xA7, DirectIndirectLong, 6, (*CPU816).lda},
+	0xA9: {lda, 0xA9, Immediate, 2, (*CPU816).lda},
+	0xAD: {lda, 0xAD, Absolute, 4, (*CPU816).lda},
+	0xAF: {lda, 0xAF, AbsoluteLong, 5, (*CPU816).lda},
+	0xB1: {lda, 0xB1, IndirectIndexed, 5, (*CPU816).lda},
+	0xB2: {lda, 0xB2, ZeroPageIndirect, 5, (*CPU816).lda},
+	0xB3: {lda, 0xB3, StackRelativeIndirectY, 7, (*CPU816).lda},
+	0xB5: {lda, 0xB5, ZeroPageX, 4, (*CPU816).lda},
+	0xB7: {lda, 0xB7, DirectIndirectLongY, 6, (*CPU816).lda},
+	0xB9: {lda, 0xB9, AbsoluteY, 4, (*CPU816).lda},
+	0xBD: {lda, 0xBD, AbsoluteX, 4, (*CPU816).lda},
+	0xBF: {lda, 0xBF, AbsoluteLongX, 5, (*CPU816).lda},
+	0xA2: {ldx, 0xA2, Immediate, 2, (*CPU816).ldx},
+	0xA6: {ldx, 0xA6, ZeroPage, 3, (*CPU816).ldx},
+	0xAE: {ldx, 0xAE, Absolute, 4, (*CPU816).ldx},
+	0xB6: {ldx, 0xB6, ZeroPageY, 4, (*CPU816).ldx},
+	0xBE: {ldx, 0xBE, AbsoluteY, 4, (*CPU816).ldx},
+	0xA0: {ldy, 0xA0, Immediate, 2, (*CPU816).ldy},
+	0xA4: {ldy, 0xA4, ZeroPage, 3, (*CPU816).ldy},
+	0xAC: {ldy, 0xAC, Absolute, 4, (*CPU816).ldy},
+	0xB4: {ldy, 0xB4, ZeroPageX, 4, (*CPU816).ldy},
+	0xBC: {ldy, 0xBC, AbsoluteX, 4, (*CPU816).ldy},
+	0x46: {lsr, 0x46, ZeroPage, 5, (*CPU816).lsr},
+	0x4A: {lsr, 0x4A, Accumulator, 2, (*CPU816).lsr},
+	0x4E: {lsr, 0x4E, Absolute, 6, (*CPU816).lsr},
+	0x56: {lsr, 0x56, ZeroPageX, 6, (*CPU816).lsr},
+	0x5E: {lsr, 0x5E, AbsoluteX, 7, (*CPU816).lsr},
+	0x54: {mvn, 0x54, BlockMove, 7, (*CPU816).mvn},
+	0x44: {mvp, 0x44, BlockMove, 7, (*CPU816).mvp},
+	0xEA: {nop, 0xEA, Implied, 2, (*CPU816).nop},
+	0x01: {ora, 0x01, IndexedIndirect, 6, (*CPU816).ora},
+	0x03: {ora, 0x03, StackRelative, 4, (*CPU816).ora},
+	0x05: {ora, 0x05, ZeroPage, 3, (*CPU816).ora},
+	0x07: {ora, 0x07, DirectIndirectLong, 6, (*CPU816).ora},
+	0x09: {ora, 0x09, Immediate, 2, (*CPU816).ora},
+	0x0D: {ora, 0x0D, Absolute, 4, (*CPU816).ora},
+	0x0F: {ora, 0x0F, AbsoluteLong, 5, (*CPU816).ora},
+	0x11: {ora, 0x11, IndirectIndexed, 5, (*CPU816).ora},
+	0x12: {ora, 0x12, ZeroPageIndirect, 5, (*CPU816).ora},
+	0x13: {ora, 0x13, StackRelativeIndirectY, 7, (*CPU816).ora},
+	0x15: {ora, 0x15, ZeroPageX, 4, (*CPU816).ora},
+	0x17: {ora, 0x17, DirectIndirectLongY, 6, (*CPU816).ora},
+	0x19: {ora, 0x19, AbsoluteY, 4, (*CPU816).ora},
+	0x1D: {ora, 0x1D, AbsoluteX, 4, (*CPU816).ora},
+	0x1F: {ora, 0x1F, AbsoluteLongX, 5, (*CPU816).ora},
+	0xF4: {pea, 0xF4, Absolute, 5, (*CPU816).pea},
+	0xD4: {pei, 0xD4, ZeroPage, 6, (*CPU816).pei},
+	0x62: {per, 0x62, RelativeLong, 6, (*CPU816).per},
+	0x48: {pha, 0x48, Implied, 3, (*CPU816).pha},
+	0x8B: {phb, 0x8B, Implied, 3, (*CPU816).phb},
+	0x0B: {phd, 0x0B, Implied, 4, (*CPU816).phd},
+	0x4B: {phk, 0x4B, Implied, 3, (*CPU816).phk},
+	0x08: {php, 0x08, Implied, 3, (*CPU816).php},
+	0xDA: {phx, 0xDA, Implied, 3, (*CPU816).phx},
+	0x5A: {phy, 0x5A, Implied, 3, (*CPU816).phy},
+	0x68: {pla, 0x68, Implied, 4, (*CPU816).pla},
+	0xAB: {plb, 0xAB, Implied, 4, (*CPU816).plb},
+	0x2B: {pld, 0x2B, Implied, 5, (*CPU816).pld},
+	0x28: {plp, 0x28, Implied, 4, (*CPU816).plp},
+	0xFA: {plx, 0xFA, Implied, 4, (*CPU816).plx},
+	0x7A: {ply, 0x7A, Implied, 4, (*CPU816).ply},
+	0xC2: {rep, 0xC2, Immediate, 3, (*CPU816).rep},
+	0x26: {rol, 0x26, ZeroPage, 5, (*CPU816).rol},
+	0x2A: {rol, 0x2A, Accumulator, 2, (*CPU816).rol},
+	0x2E: {rol, 0x2E, Absolute, 6, (*CPU816).rol},
+	0x36: {rol, 0x36, ZeroPageX, 6, (*CPU816).rol},
+	0x3E: {rol, 0x3E, AbsoluteX, 7, (*CPU816).rol},
+	0x66: {ror, 0x66, ZeroPage, 5, (*CPU816).ror},
+	0x6A: {ror, 0x6A, Accumulator, 2, (*CPU816).ror},
+	0x6E: {ror, 0x6E, Absolute, 6, (*CPU816).ror},
+	0x76: {ror, 0x76, ZeroPageX, 6, (*CPU816).ror},
+	0x7E: {ror, 0x7E, AbsoluteX, 7, (*CPU816).ror},
+	0x40: {rti, 0x40, Implied, 6, (*CPU816).rti},
+	0x6B: {rtl, 0x6B, Implied, 6, (*CPU816).rtl},
+	0x60: {rts, 0x60, Implied, 6, (*CPU816).rts},
+	0xE1: {sbc, 0xE1, IndexedIndirect, 6, (*CPU816).sbc},
+	0xE3: {sbc, 0xE3, StackRelative, 4, (*CPU816).sbc},
+	0xE5: {sbc, 0xE5, ZeroPage, 3, (*CPU816).sbc},
+	0xE7: {sbc, 0xE7, DirectIndirectLong, 6, (*CPU816).sbc},
+	0xE9: {sbc, 0xE9, Immediate, 2, (*CPU816).sbc},
+	0xED: {sbc, 0xED, Absolute, 4, (*CPU816).sbc},
+	0xEF: {sbc, 0xEF, AbsoluteLong, 5, (*CPU816).sbc},
+	0xF1: {sbc, 0xF1, IndirectIndexed, 5, (*CPU816).sbc},
+	0xF2: {sbc, 0xF2, ZeroPageIndirect, 5, (*CPU816).sbc},
+	0xF3: {sbc, 0xF3, StackRelativeIndirectY, 7, (*CPU816).sbc},
+	0xF5: {sbc, 0xF5, ZeroPageX, 4, (*CPU816).sbc},
+	0xF7: {sbc, 0xF7, DirectIndirectLongY, 6, (*CPU816).sbc},
+	0xF9: {sbc, 0xF9, AbsoluteY, 4, (*CPU816).sbc},
+	0xFD: {sbc, 0xFD, AbsoluteX, 4, (*CPU816).sbc},
+	0xFF: {sbc, 0xFF, AbsoluteLongX, 5, (*CPU816).sbc},
+	0x38: {sec, 0x38, Implied, 2, (*CPU816).sec},
+	0xF8: {sed, 0xF8, Implied, 2, (*CPU816).sed},
+	0x78: {sei, 0x78, Implied, 2, (*CPU816).sei},
+	0xE2: {sep, 0xE2, Immediate, 3, (*CPU816).sep},
+	0x81: {sta, 0x81, IndexedIndirect, 6, (*CPU816).sta},
+	0x83: {sta, 0x83, StackRelative, 4, (*CPU816).sta},
+	0x85: {sta, 0x85, ZeroPage, 3, (*CPU816).sta},
+	0x87: {sta, 0x87, DirectIndirectLong, 6, (*CPU816).sta},
+	0x8D: {sta, 0x8D, Absolute, 4, (*CPU816).sta},
+	0x8F: {sta, 0x8F, AbsoluteLong, 5, (*CPU816).sta},
+	0x91: {sta, 0x91, IndirectIndexed, 6, (*CPU816).sta},
+	0x92: {sta, 0x92, ZeroPageIndirect, 5, (*CPU816).sta},
+	0x93: {sta, 0x93, StackRelativeIndirectY, 7, (*CPU816).sta},
+	0x95: {sta, 0x95, ZeroPageX, 4, (*CPU816).sta},
+	0x97: {sta, 0x97, DirectIndirectLongY, 6, (*CPU816).sta},
+	0x99: {sta, 0x99, AbsoluteY, 5, (*CPU816).sta},
+	0x9D: {sta, 0x9D, AbsoluteX, 5, (*CPU816).sta},
+	0x9F: {sta, 0x9F, AbsoluteLongX, 5, (*CPU816).sta},
+	0xDB: {stp, 0xDB, Implied, 3, (*CPU816).stp},
+	0x86: {stx, 0x86, ZeroPage, 3, (*CPU816).stx},
+	0x8E: {stx, 0x8E, Absolute, 4, (*CPU816).stx},
+	0x96: {stx, 0x96, ZeroPageY, 4, (*CPU816).stx},
+	0x84: {sty, 0x84, ZeroPage, 3, (*CPU816).sty},
+	0x8C: {sty, 0x8C, Absolute, 4, (*CPU816).sty},
+	0x94: {sty, 0x94, ZeroPageX, 4, (*CPU816).sty},
+	0x64: {stz, 0x64, ZeroPage, 3, (*CPU816).stz},
+	0x74: {stz, 0x74, ZeroPageX, 4, (*CPU816).stz},
+	0x9C: {stz, 0x9C, Absolute, 4, (*CPU816).stz},
+	0x9E: {stz, 0x9E, AbsoluteX, 5, (*CPU816).stz},
+	0xAA: {tax, 0xAA, Implied, 2, (*CPU816).tax},
+	0xA8: {tay, 0xA8, Implied, 2, (*CPU816).tay},
+	0x5B: {tcd, 0x5B, Implied, 2, (*CPU816).tcd},
+	0x1B: {tcs, 0x1B, Implied, 2, (*CPU816).tcs},
+	0x7B: {tdc, 0x7B, Implied, 2, (*CPU816).tdc},
+	0x14: {trb, 0x14, ZeroPage, 5, (*CPU816).trb},
+	0x1C: {trb, 0x1C, Absolute, 6, (*CPU816).trb},
+	0x04: {tsb, 0x04, ZeroPage, 5, (*CPU816).tsb},
+	0x0C: {tsb, 0x0C, Absolute, 6, (*CPU816).tsb},
+	0x3B: {tsc, 0x3B, Implied, 2, (*CPU816).tsc},
+	0xBA: {tsx, 0xBA, Implied, 2, (*CPU816).tsx},
+	0x8A: {txa, 0x8A, Implied, 2, (*CPU816).txa},
+	0x9A: {txs, 0x9A, Implied, 2, (*CPU816).txs},
+	0x9B: {txy, 0x9B, Implied, 2, (*CPU816).txy},
+	0x98: {tya, 0x98, Implied, 2, (*CPU816).tya},
+	0xBB: {tyx, 0xBB, Implied, 2, (*CPU816).tyx},
+	0xCB: {wai, 0xCB, Implied, 3, (*CPU816).wai},
+	0x42: {wdm, 0x42, Immediate, 2, (*CPU816).wdm},
+	0xEB: {xba, 0xEB, Implied, 3, (*CPU816).xba},
+	0xFB: {xce, 0xFB, Implied, 2, (*CPU816).xce},
+}
+
+// widthMask returns the mask and sign bit of an 8 or 16-bit value
+func widthMask(wide bool) (mask, sign uint16) {
+	if wide {
+		return 0xFFFF, 0x8000
+	}
+	return 0x00FF, 0x0080
+}
+
+// setA sets the accumulator, leaving the high byte alone when it is 8 bits
+// wide
+func (c *CPU816) setA(value uint16) {
+	if c.m16() {
+		c.r.a = value
+	} else {
+		c.r.a = c.r.a&0xFF00 | value&0x00FF
+	}
+}
+
+// setX sets the X register, clearing the high byte when it is 8 bits wide
+func (c *CPU816) setX(value uint16) {
+	if c.x16() {
+		c.r.x = value
+	} else {
+		c.r.x = value & 0x00FF
+	}
+}
+
+// setY sets the Y register, clearing the high byte when it is 8 bits wide
+func (c *CPU816) setY(value uint16) {
+	if c.x16() {
+		c.r.y = value
+	} else {
+		c.r.y = value & 0x00FF
+	}
+}
+
+// modify applies f to the accumulator, or to the operand in memory, at the
+// width of the accumulator. This is the read-modify-write of ASL, INC, TSB
+// and friends.
+func (c *CPU816) modify(f func(value uint16, wide bool) uint16) {
+	var wide = c.m16()
+	var mask, _ = widthMask(wide)
+
+	if c.i.addr_mode == Accumulator {
+		c.setA(f(c.r.a&mask, wide))
+		return
+	}
+
+	c.store(f(c.load(wide), wide), wide)
+}
+
+// branch moves the program counter by the relative offset if condition
+// holds. A taken branch costs an extra cycle, plus another in emulation mode
+// if it lands on a different page.
+func (c *CPU816) branch(condition bool) uint8 {
+	if condition {
+		// We branched, so add a cycle
+		c.status.Cycles++
+
+		// Calculate the new address, which stays in the program bank
+		var target uint16 = c.r.pc + c.i.addr_relative
+
+		// Only emulation mode pays for crossing a page
+		if c.r.e && target&0xFF00 != c.r.pc&0xFF00 {
+			c.status.Cycles++
+		}
+
+		c.r.pc = target
+	}
+
+	return 0
+}
+
+// addWithCarry adds value and the carry flag to the accumulator, in BCD if
+// the decimal flag is set. Unlike the 6502, all flags are valid in decimal
+// mode and it costs no extra cycle.
+func (c *CPU816) addWithCarry(value uint16, wide bool) {
+	var mask, sign = widthMask(wide)
+	var a uint32 = uint32(c.r.a & mask)
+	var v uint32 = uint32(value & mask)
+
+	var carry uint32 = 0
+	if c.getFlag(Carry) {
+		carry = 1
+	}
+
+	var result uint32
+	if !c.getFlag(Decimal) {
+		result = a + v + carry
+	} else {
+		// Add digit by digit, adjusting each one that isn't a decimal digit
+		var digits int = 2
+		if wide {
+			digits = 4
+		}
+		for i := 0; i < digits; i++ {
+			var shift int = 4 * i
+			var digit uint32 = (a>>shift)&0x0F + (v>>shift)&0x0F + carry
+			carry = 0
+			if digit > 0x09 {
+				digit += 0x06
+				carry = 1
+			}
+			result |= (digit & 0x0F) << shift
+		}
+		result |= carry << (4 * digits)
+	}
+
+	c.setFlag(Carry, result > uint32(mask))
+	c.setFlag(Overflow, ^(a^v)&(a^result)&uint32(sign) != 0)
+	c.setA(uint16(result))
+	c.setNZ(uint16(result), wide)
+}
+
+// subtractWithBorrow subtracts value and the inverted carry flag from the
+// accumulator, in BCD if the decimal flag is set. V comes from the binary
+// difference; the other flags are valid in decimal mode too.
+func (c *CPU816) subtractWithBorrow(value uint16, wide bool) {
+	var mask, sign = widthMask(wide)
+	var a int = int(c.r.a & mask)
+	var v int = int(value & mask)
+
+	var borrow int = 0
+	if !c.getFlag(Carry) {
+		borrow = 1
+	}
+
+	// The binary difference gives the overflow flag either way
+	var binary int = a - v - borrow
+	c.setFlag(Overflow, (a^v)&(a^binary)&int(sign) != 0)
+
+	var result int
+	if !c.getFlag(Decimal) {
+		result = binary
+		borrow = 0
+		if binary < 0 {
+			borrow = 1
+		}
+	} else {
+		// Subtract digit by digit, adjusting each one that borrowed
+		var digits int = 2
+		if wide {
+			digits = 4
+		}
+		for i := 0; i < digits; i++ {
+			var shift int = 4 * i
+			var digit int = (a>>shift)&0x0F - (v>>shift)&0x0F - borrow
+			borrow = 0
+			if digit < 0 {
+				digit += 10
+				borrow = 1
+			}
+			result |= (digit & 0x0F) << shift
+		}
+	}
+
+	c.setFlag(Carry, borrow == 0)
+	c.setA(uint16(result))
+	c.setNZ(uint16(result), wide)
+}
+
+// compare compares a register with the operand
+func (c *CPU816) compare(register uint16, wide bool) {
+	var mask, _ = widthMask(wide)
+	var value uint16 = c.load(wide)
+
+	c.setFlag(Carry, register&mask >= value)
+	c.setNZ(register-value, wide)
+}
+
+// moveByte moves one byte of an MVN or MVP block move, stepping X and Y by
+// step. The instruction repeats until the count in A runs out, which lets
+// interrupts in between bytes.
+func (c *CPU816) moveByte(step uint16) {
+	var destination uint8 = uint8(c.i.temp)
+	var source uint8 = uint8(c.i.temp >> 8)
+
+	// The data bank ends up pointing at the destination
+	c.r.dbr = destination
+
+	var value uint8 = c.read(uint32(source)<<16 | uint32(c.r.x))
+	c.write(uint32(destination)<<16|uint32(c.r.y), value)
+
+	c.setX(c.r.x + step)
+	c.setY(c.r.y + step)
+
+	// Run the instruction again until the count wraps
+	c.r.a--
+	if c.r.a != 0xFFFF {
+		c.r.pc -= 3
+	}
+}
+
+// adc adds with carry
+func (c *CPU816) adc() uint8 {
+	var wide = c.m16()
+	c.addWithCarry(c.load(wide), wide)
+
+	return 1
+}
+
+// and ands with accumulator
+func (c *CPU816) and() uint8 {
+	c.setA(c.r.a & c.load(c.m16()))
+	c.setNZ(c.r.a, c.m16())
+
+	return 1
+}
+
+// asl shifts left one bit
+func (c *CPU816) asl() uint8 {
+	c.modify(func(value uint16, wide bool) uint16 {
+		var mask, sign = widthMask(wide)
+		c.setFlag(Carry, value&sign != 0)
+		value = value << 1 & mask
+		c.setNZ(value, wide)
+		return value
+	})
+
+	return 0
+}
+
+// bcc branches if carry clear
+func (c *CPU816) bcc() uint8 {
+	return c.branch(!c.getFlag(Carry))
+}
+
+// bcs branches if carry set
+func (c *CPU816) bcs() uint8 {
+	return c.branch(c.getFlag(Carry))
+}
+
+// beq branches if equal
+func (c *CPU816) beq() uint8 {
+	return c.branch(c.getFlag(Zero))
+}
+
+// bit tests bits in memory with accumulator
+func (c *CPU816) bit() uint8 {
+	var wide = c.m16()
+	var _, sign = widthMask(wide)
+	var value uint16 = c.load(wide)
+
+	c.setNZ(c.r.a&value, wide)
+
+	// BIT # only affects the zero flag
+	if c.i.addr_mode != Immediate {
+		c.setFlag(Negative, value&sign != 0)
+		c.setFlag(Overflow, value&(sign>>1) != 0)
+	}
+
+	return 1
+}
+
+// bmi branches if minus
+func (c *CPU816) bmi() uint8 {
+	return c.branch(c.getFlag(Negative))
+}
+
+// bne branches if not equal
+func (c *CPU816) bne() uint8 {
+	return c.branch(!c.getFlag(Zero))
+}
+
+// bpl branches if positive
+func (c *CPU816) bpl() uint8 {
+	return c.branch(!c.getFlag(Negative))
+}
+
+// bra branches always
+func (c *CPU816) bra() uint8 {
+	return c.branch(true)
+}
+
+// brk forces an interrupt
+func (c *CPU816) brk() uint8 {
+	c.interrupt(vectorNativeBRK, vectorEmulationBRK, true)
+
+	return 0
+}
+
+// brl branches always, with a 16-bit offset
+func (c *CPU816) brl() uint8 {
+	c.r.pc += c.i.addr_relative
+
+	return 0
+}
+
+// bvc branches if overflow clear
+func (c *CPU816) bvc() uint8 {
+	return c.branch(!c.getFlag(Overflow))
+}
+
+// bvs branches if overflow set
+func (c *CPU816) bvs() uint8 {
+	return c.branch(c.getFlag(Overflow))
+}
+
+// clc clears carry flag
+func (c *CPU816) clc() uint8 {
+	c.setFlag(Carry, false)
+
+	return 0
+}
+
+// cld clears decimal mode
+func (c *CPU816) cld() uint8 {
+	c.setFlag(Decimal, false)
+
+	return 0
+}
+
+// cli clears interrupt disable
+func (c *CPU816) cli() uint8 {
+	c.setFlag(InterruptDisable, false)
+
+	return 0
+}
+
+// clv clears overflow flag
+func (c *CPU816) clv() uint8 {
+	c.setFlag(Overflow, false)
+
+	return 0
+}
+
+// cmp compares accumulator with memory
+func (c *CPU816) cmp() uint8 {
+	c.compare(c.r.a, c.m16())
+
+	return 1
+}
+
+// cop forces a co-processor interrupt
+func (c *CPU816) cop() uint8 {
+	c.interrupt(vectorNativeCOP, vectorEmulationCOP, true)
+
+	return 0
+}
+
+// cpx compares X register
+func (c *CPU816) cpx() uint8 {
+	c.compare(c.r.x, c.x16())
+
+	return 0
+}
+
+// cpy compares Y register
+func (c *CPU816) cpy() uint8 {
+	c.compare(c.r.y, c.x16())
+
+	return 0
+}
+
+// dec decrements accumulator or memory
+func (c *CPU816) dec() uint8 {
+	c.modify(func(value uint16, wide bool) uint16 {
+		var mask, _ = widthMask(wide)
+		value = (value - 1) & mask
+		c.setNZ(value, wide)
+		return value
+	})
+
+	return 0
+}
+
+// dex decrements X register
+func (c *CPU816) dex() uint8 {
+	c.setX(c.r.x - 1)
+	c.setNZ(c.r.x, c.x16())
+
+	return 0
+}
+
+// dey decrements Y register
+func (c *CPU816) dey() uint8 {
+	c.setY(c.r.y - 1)
+	c.setNZ(c.r.y, c.x16())
+
+	return 0
+}
+
+// eor exclusive ors accumulator
+func (c *CPU816) eor() uint8 {
+	c.setA(c.r.a ^ c.load(c.m16()))
+	c.setNZ(c.r.a, c.m16())
+
+	return 1
+}
+
+// inc increments accumulator or memory
+func (c *CPU816) inc() uint8 {
+	c.modify(func(value uint16, wide bool) uint16 {
+		var mask, _ = widthMask(wide)
+		value = (value + 1) & mask
+		c.setNZ(value, wide)
+		return value
+	})
+
+	return 0
+}
+
+// inx increments X register
+func (c *CPU816) inx() uint8 {
+	c.setX(c.r.x + 1)
+	c.setNZ(c.r.x, c.x16())
+
+	return 0
+}
+
+// iny increments Y register
+func (c *CPU816) iny() uint8 {
+	c.setY(c.r.y + 1)
+	c.setNZ(c.r.y, c.x16())
+
+	return 0
+}
+
+// jml jumps to a location in any bank
+func (c *CPU816) jml() uint8 {
+	c.r.pbr = uint8(c.i.addr_absolute >> 16)
+	c.r.pc = uint16(c.i.addr_absolute)
+
+	return 0
+}
+
+// jmp jumps to a location in the program bank
+func (c *CPU816) jmp() uint8 {
+	c.r.pc = uint16(c.i.addr_absolute)
+
+	return 0
+}
+
+// jsl jumps to a subroutine in any bank
+func (c *CPU816) jsl() uint8 {
+	c.pushByte(c.r.pbr)
+	c.pushWord(c.r.pc - 1)
+
+	c.r.pbr = uint8(c.i.addr_absolute >> 16)
+	c.r.pc = uint16(c.i.addr_absolute)
+
+	return 0
+}
+
+// jsr jumps to a subroutine in the program bank
+func (c *CPU816) jsr() uint8 {
+	c.pushWord(c.r.pc - 1)
+
+	c.r.pc = uint16(c.i.addr_absolute)
+
+	return 0
+}
+
+// lda loads accumulator
+func (c *CPU816) lda() uint8 {
+	c.setA(c.load(c.m16()))
+	c.setNZ(c.r.a, c.m16())
+
+	return 1
+}
+
+// ldx loads X register
+func (c *CPU816) ldx() uint8 {
+	c.setX(c.load(c.x16()))
+	c.setNZ(c.r.x, c.x16())
+
+	return 1
+}
+
+// ldy loads Y register
+func (c *CPU816) ldy() uint8 {
+	c.setY(c.load(c.x16()))
+	c.setNZ(c.r.y, c.x16())
+
+	return 1
+}
+
+// lsr shifts right one bit
+func (c *CPU816) lsr() uint8 {
+	c.modify(func(value uint16, wide bool) uint16 {
+		c.setFlag(Carry, value&0x0001 != 0)
+		value = value >> 1
+		c.setNZ(value, wide)
+		return value
+	})
+
+	return 0
+}
+
+// mvn moves a block of memory upwards, incrementing X and Y
+func (c *CPU816) mvn() uint8 {
+	c.moveByte(1)
+
+	return 0
+}
+
+// mvp moves a block of memory downwards, decrementing X and Y
+func (c *CPU816) mvp() uint8 {
+	c.moveByte(0xFFFF)
+
+	return 0
+}
+
+// nop no operation
+func (c *CPU816) nop() uint8 {
+	return 0
+}
+
+// ora ors accumulator
+func (c *CPU816) ora() uint8 {
+	c.setA(c.r.a | c.load(c.m16()))
+	c.setNZ(c.r.a, c.m16())
+
+	return 1
+}
+
+// pea pushes effective absolute address
+func (c *CPU816) pea() uint8 {
+	c.pushWord(uint16(c.i.addr_absolute))
+
+	return 0
+}
+
+// pei pushes effective indirect address
+func (c *CPU816) pei() uint8 {
+	var low uint16 = uint16(c.read(c.i.addr_absolute))
+	var high uint16 = uint16(c.read(c.next(c.i.addr_absolute)))
+	c.pushWord(high<<8 | low)
+
+	return 0
+}
+
+// per pushes effective program counter relative address
+func (c *CPU816) per() uint8 {
+	c.pushWord(c.r.pc + c.i.addr_relative)
+
+	return 0
+}
+
+// pha pushes accumulator
+func (c *CPU816) pha() uint8 {
+	if c.m16() {
+		c.pushWord(c.r.a)
+		c.status.Cycles++
+	} else {
+		c.pushByte(uint8(c.r.a))
+	}
+
+	return 0
+}
+
+// phb pushes data bank register
+func (c *CPU816) phb() uint8 {
+	c.pushByte(c.r.dbr)
+
+	return 0
+}
+
+// phd pushes direct page register
+func (c *CPU816) phd() uint8 {
+	c.pushWord(c.r.d)
+
+	return 0
+}
+
+// phk pushes program bank register
+func (c *CPU816) phk() uint8 {
+	c.pushByte(c.r.pbr)
+
+	return 0
+}
+
+// php pushes processor status
+func (c *CPU816) php() uint8 {
+	c.pushByte(c.r.p)
+
+	return 0
+}
+
+// phx pushes X register
+func (c *CPU816) phx() uint8 {
+	if c.x16() {
+		c.pushWord(c.r.x)
+		c.status.Cycles++
+	} else {
+		c.pushByte(uint8(c.r.x))
+	}
+
+	return 0
+}
+
+// phy pushes Y register
+func (c *CPU816) phy() uint8 {
+	if c.x16() {
+		c.pushWord(c.r.y)
+		c.status.Cycles++
+	} else {
+		c.pushByte(uint8(c.r.y))
+	}
+
+	return 0
+}
+
+// pla pulls accumulator
+func (c *CPU816) pla() uint8 {
+	if c.m16() {
+		c.setA(c.popWord())
+		c.status.Cycles++
+	} else {
+		c.setA(uint16(c.popByte()))
+	}
+	c.setNZ(c.r.a, c.m16())
+
+	return 0
+}
+
+// plb pulls data bank register
+func (c *CPU816) plb() uint8 {
+	c.r.dbr = c.popByte()
+	c.setNZ(uint16(c.r.dbr), false)
+
+	return 0
+}
+
+// pld pulls direct page register
+func (c *CPU816) pld() uint8 {
+	c.r.d = c.popWord()
+	c.setNZ(c.r.d, true)
+
+	return 0
+}
+
+// plp pulls processor status
+func (c *CPU816) plp() uint8 {
+	c.setP(c.popByte())
+
+	return 0
+}
+
+// plx pulls X register
+func (c *CPU816) plx() uint8 {
+	if c.x16() {
+		c.setX(c.popWord())
+		c.status.Cycles++
+	} else {
+		c.setX(uint16(c.popByte()))
+	}
+	c.setNZ(c.r.x, c.x16())
+
+	return 0
+}
+
+// ply pulls Y register
+func (c *CPU816) ply() uint8 {
+	if c.x16() {
+		c.setY(c.popWord())
+		c.status.Cycles++
+	} else {
+		c.setY(uint16(c.popByte()))
+	}
+	c.setNZ(c.r.y, c.x16())
+
+	return 0
+}
+
+// rep resets status bits
+func (c *CPU816) rep() uint8 {
+	c.setP(c.r.p &^ uint8(c.load(false)))
+
+	return 0
+}
+
+// rol rotates left one bit
+func (c *CPU816) rol() uint8 {
+	c.modify(func(value uint16, wide bool) uint16 {
+		var mask, sign = widthMask(wide)
+		var carry uint16 = 0
+		if c.getFlag(Carry) {
+			carry = 1
+		}
+		c.setFlag(Carry, value&sign != 0)
+		value = (value<<1 | carry) & mask
+		c.setNZ(value, wide)
+		return value
+	})
+
+	return 0
+}
+
+// ror rotates right one bit
+func (c *CPU816) ror() uint8 {
+	c.modify(func(value uint16, wide bool) uint16 {
+		var _, sign = widthMask(wide)
+		var carry uint16 = 0
+		if c.getFlag(Carry) {
+			carry = sign
+		}
+		c.setFlag(Carry, value&0x0001 != 0)
+		value = value>>1 | carry
+		c.setNZ(value, wide)
+		return value
+	})
+
+	return 0
+}
+
+// rti returns from interrupt
+func (c *CPU816) rti() uint8 {
+	c.setP(c.popByte())
+	c.r.pc = c.popWord()
+
+	// Native mode interrupts pushed the program bank too
+	if !c.r.e {
+		c.r.pbr = c.popByte()
+		c.status.Cycles++
+	}
+
+	return 0
+}
+
+// rtl returns from subroutine long
+func (c *CPU816) rtl() uint8 {
+	c.r.pc = c.popWord() + 1
+	c.r.pbr = c.popByte()
+
+	return 0
+}
+
+// rts returns from subroutine
+func (c *CPU816) rts() uint8 {
+	c.r.pc = c.popWord() + 1
+
+	return 0
+}
+
+// sbc subtracts with carry
+func (c *CPU816) sbc() uint8 {
+	var wide = c.m16()
+	c.subtractWithBorrow(c.load(wide), wide)
+
+	return 1
+}
+
+// sec sets carry flag
+func (c *CPU816) sec() uint8 {
+	c.setFlag(Carry, true)
+
+	return 0
+}
+
+// sed sets decimal mode
+func (c *CPU816) sed() uint8 {
+	c.setFlag(Decimal, true)
+
+	return 0
+}
+
+// sei sets interrupt disable
+func (c *CPU816) sei() uint8 {
+	c.setFlag(InterruptDisable, true)
+
+	return 0
+}
+
+// sep sets status bits
+func (c *CPU816) sep() uint8 {
+	c.setP(c.r.p | uint8(c.load(false)))
+
+	return 0
+}
+
+// sta stores accumulator
+func (c *CPU816) sta() uint8 {
+	c.store(c.r.a, c.m16())
+
+	return 0
+}
+
+// stp stops the clock until the CPU is reset
+func (c *CPU816) stp() uint8 {
+	c.status.halted = true
+
+	if c.onHalt != nil {
+		c.onHalt(uint32(c.r.pbr)<<16|uint32(c.r.pc-1), c.i.opcode)
+	}
+
+	return 0
+}
+
+// stx stores X register
+func (c *CPU816) stx() uint8 {
+	c.store(c.r.x, c.x16())
+
+	return 0
+}
+
+// sty stores Y register
+func (c *CPU816) sty() uint8 {
+	c.store(c.r.y, c.x16())
+
+	return 0
+}
+
+// stz stores zero
+func (c *CPU816) stz() uint8 {
+	c.store(0x0000, c.m16())
+
+	return 0
+}
+
+// tax transfers accumulator to X register
+func (c *CPU816) tax() uint8 {
+	c.setX(c.r.a)
+	c.setNZ(c.r.x, c.x16())
+
+	return 0
+}
+
+// tay transfers accumulator to Y register
+func (c *CPU816) tay() uint8 {
+	c.setY(c.r.a)
+	c.setNZ(c.r.y, c.x16())
+
+	return 0
+}
+
+// tcd transfers 16-bit accumulator to direct page register
+func (c *CPU816) tcd() uint8 {
+	c.r.d = c.r.a
+	c.setNZ(c.r.d, true)
+
+	return 0
+}
+
+// tcs transfers 16-bit accumulator to stack pointer
+func (c *CPU816) tcs() uint8 {
+	c.r.sp = c.r.a
+
+	// The emulation mode stack stays in page 1
+	if c.r.e {
+		c.r.sp = emulationStackPage | c.r.sp&0x00FF
+	}
+
+	return 0
+}
+
+// tdc transfers direct page register to 16-bit accumulator
+func (c *CPU816) tdc() uint8 {
+	c.r.a = c.r.d
+	c.setNZ(c.r.a, true)
+
+	return 0
+}
+
+// trb tests and resets memory bits with accumulator
+func (c *CPU816) trb() uint8 {
+	c.modify(func(value uint16, wide bool) uint16 {
+		var mask, _ = widthMask(wide)
+		c.setFlag(Zero, c.r.a&mask&value == 0)
+		return value &^ c.r.a
+	})
+
+	return 0
+}
+
+// tsb tests and sets memory bits with accumulator
+func (c *CPU816) tsb() uint8 {
+	c.modify(func(value uint16, wide bool) uint16 {
+		var mask, _ = widthMask(wide)
+		c.setFlag(Zero, c.r.a&mask&value == 0)
+		return (value | c.r.a) & mask
+	})
+
+	return 0
+}
+
+// tsc transfers stack pointer to 16-bit accumulator
+func (c *CPU816) tsc() uint8 {
+	c.r.a = c.r.sp
+	c.setNZ(c.r.a, true)
+
+	return 0
+}
+
+// tsx transfers stack pointer to X register
+func (c *CPU816) tsx() uint8 {
+	c.setX(c.r.sp)
+	c.setNZ(c.r.x, c.x16())
+
+	return 0
+}
+
+// txa transfers X register to accumulator
+func (c *CPU816) txa() uint8 {
+	c.setA(c.r.x)
+	c.setNZ(c.r.a, c.m16())
+
+	return 0
+}
+
+// txs transfers X register to stack pointer
+func (c *CPU816) txs() uint8 {
+	c.r.sp = c.r.x
+
+	// The emulation mode stack stays in page 1
+	if c.r.e {
+		c.r.sp = emulationStackPage | c.r.sp&0x00FF
+	}
+
+	return 0
+}
+
+// txy transfers X register to Y register
+func (c *CPU816) txy() uint8 {
+	c.setY(c.r.x)
+	c.setNZ(c.r.y, c.x16())
+
+	return 0
+}
+
+// tya transfers Y register to accumulator
+func (c *CPU816) tya() uint8 {
+	c.setA(c.r.y)
+	c.setNZ(c.r.a, c.m16())
+
+	return 0
+}
+
+// tyx transfers Y register to X register
+func (c *CPU816) tyx() uint8 {
+	c.setX(c.r.y)
+	c.setNZ(c.r.x, c.x16())
+
+	return 0
+}
+
+// wai waits for an interrupt
+func (c *CPU816) wai() uint8 {
+	c.status.waiting = true
+
+	return 0
+}
+
+// wdm is reserved for future expansion, and does nothing but skip its operand
+func (c *CPU816) wdm() uint8 {
+	return 0
+}
+
+// xba exchanges the bytes of the accumulator
+func (c *CPU816) xba() uint8 {
+	c.r.a = c.r.a<<8 | c.r.a>>8
+
+	// The flags follow the new low byte
+	c.setNZ(c.r.a, false)
+
+	return 0
+}
+
+// xce exchanges the carry and emulation flags
+func (c *CPU816) xce() uint8 {
+	var emulation bool = c.getFlag(Carry)
+	c.setFlag(Carry, c.r.e)
+	c.r.e = emulation
+
+	// Entering emulation mode forces 8-bit registers and a page 1 stack
+	if c.r.e {
+		c.setP(c.r.p)
+		c.r.sp = emulationStackPage | c.r.sp&0x00FF
+	}
+
+	return 0
+}
@@ -0,0 +1,173 @@
+package goemu6502
+
+import "testing"
+
+// instructionTest runs program from $0400 for one instruction, starting from
+// the given registers and memory, and checks the registers and cycles after.
+// PC is only checked if wantPC is set.
+type instructionTest struct {
+	name    string
+	program []uint8
+	memory  map[uint16]uint8
+	a, x, y uint8
+	p       uint8
+
+	wantA, wantX, wantY uint8
+	wantP               uint8
+	wantMemory          map[uint16]uint8
+	wantPC              uint16
+	wantCycles          int
+}
+
+func (tt instructionTest) run(t *testing.T, options ...Option) {
+	t.Helper()
+
+	bus := &flatBus{}
+	copy(bus.memory[0x0400:], tt.program)
+	for addr, value := range tt.memory {
+		bus.memory[addr] = value
+	}
+
+	c := NewCPU(bus, options...)
+	c.SetRegisters(NewRegisters(tt.a, tt.x, tt.y, tt.p|Unused, 0xFD, 0x0400))
+
+	cycles := c.Step()
+
+	r := c.Registers()
+	if r.A() != tt.wantA || r.X() != tt.wantX || r.Y() != tt.wantY || r.P() != tt.wantP|Unused {
+		t.Errorf("%s: got A=%02X X=%02X Y=%02X P=%s, want A=%02X X=%02X Y=%02X P=%s", tt.name,
+			r.A(), r.X(), r.Y(), r.Flags(), tt.wantA, tt.wantX, tt.wantY, Flags(tt.wantP|Unused))
+	}
+	for addr, value := range tt.wantMemory {
+		if bus.memory[addr] != value {
+			t.Errorf("%s: $%04X = %02X, want %02X", tt.name, addr, bus.memory[addr], value)
+		}
+	}
+	if tt.wantPC != 0 && r.PC() != tt.wantPC {
+		t.Errorf("%s: PC = $%04X, want $%04X", tt.name, r.PC(), tt.wantPC)
+	}
+	if tt.wantCycles != 0 && cycles != tt.wantCycles {
+		t.Errorf("%s: took %d cycles, want %d", tt.name, cycles, tt.wantCycles)
+	}
+}
+
+func TestDecimalMode(t *testing.T) {
+	for _, tt := range []instructionTest{
+		{name: "ADC 12+34", program: []uint8{0x69, 0x34}, a: 0x12, p: Decimal,
+			wantA: 0x46, wantP: Decimal, wantCycles: 2},
+		{name: "ADC 58+46+C", program: []uint8{0x69, 0x46}, a: 0x58, p: Decimal | Carry,
+			wantA: 0x05, wantP: Decimal | Carry | Negative | Overflow},
+		{name: "ADC 99+01", program: []uint8{0x69, 0x01}, a: 0x99, p: Decimal,
+			wantA: 0x00, wantP: Decimal | Carry | Negative},
+		{name: "SBC 46-12", program: []uint8{0xE9, 0x12}, a: 0x46, p: Decimal | Carry,
+			wantA: 0x34, wantP: Decimal | Carry, wantCycles: 2},
+		{name: "SBC 40-13", program: []uint8{0xE9, 0x13}, a: 0x40, p: Decimal | Carry,
+			wantA: 0x27, wantP: Decimal | Carry},
+		{name: "SBC 32-02-B", program: []uint8{0xE9, 0x02}, a: 0x32, p: Decimal,
+			wantA: 0x29, wantP: Decimal | Carry},
+		{name: "SBC 12-21", program: []uint8{0xE9, 0x21}, a: 0x12, p: Decimal | Carry,
+			wantA: 0x91, wantP: Decimal | Negative},
+	} {
+		tt.run(t)
+	}
+}
+
+func TestUndocumented(t *testing.T) {
+	for _, tt := range []instructionTest{
+		{name: "LAX zp", program: []uint8{0xA7, 0x10}, memory: map[uint16]uint8{0x10: 0x80},
+			wantA: 0x80, wantX: 0x80, wantP: Negative, wantCycles: 3},
+		{name: "LAX (zp),Y page cross", program: []uint8{0xB3, 0x10}, y: 0x01,
+			memory: map[uint16]uint8{0x10: 0xFF, 0x11: 0x20, 0x2100: 0x42},
+			wantA:  0x42, wantX: 0x42, wantY: 0x01, wantCycles: 6},
+		{name: "SAX zp", program: []uint8{0x87, 0x10}, a: 0xF0, x: 0x3C,
+			wantA: 0xF0, wantX: 0x3C, wantMemory: map[uint16]uint8{0x10: 0x30}, wantCycles: 3},
+		{name: "DCP abs,X", program: []uint8{0xDF, 0x00, 0x20}, a: 0x41, x: 0x01,
+			memory: map[uint16]uint8{0x2001: 0x42},
+			wantA:  0x41, wantX: 0x01, wantP: Zero | Carry, wantMemory: map[uint16]uint8{0x2001: 0x41}, wantCycles: 7},
+		{name: "ISC zp", program: []uint8{0xE7, 0x10}, a: 0x10, p: Carry,
+			memory: map[uint16]uint8{0x10: 0x0F},
+			wantA:  0x00, wantP: Zero | Carry, wantMemory: map[uint16]uint8{0x10: 0x10}, wantCycles: 5},
+		{name: "SLO (zp,X)", program: []uint8{0x03, 0x0F}, a: 0x01, x: 0x01,
+			memory: map[uint16]uint8{0x10: 0x00, 0x11: 0x30, 0x3000: 0x81},
+			wantA:  0x03, wantX: 0x01, wantP: Carry, wantMemory: map[uint16]uint8{0x3000: 0x02}, wantCycles: 8},
+		{name: "RLA zp", program: []uint8{0x27, 0x10}, a: 0xFF, p: Carry,
+			memory: map[uint16]uint8{0x10: 0x40},
+			wantA:  0x81, wantP: Negative, wantMemory: map[uint16]uint8{0x10: 0x81}},
+		{name: "SRE zp", program: []uint8{0x47, 0x10}, a: 0x01,
+			memory: map[uint16]uint8{0x10: 0x03},
+			wantA:  0x00, wantP: Zero | Carry, wantMemory: map[uint16]uint8{0x10: 0x01}},
+		{name: "RRA zp", program: []uint8{0x67, 0x10}, a: 0x10,
+			memory: map[uint16]uint8{0x10: 0x03},
+			wantA:  0x12, wantMemory: map[uint16]uint8{0x10: 0x01}},
+		{name: "ANC #", program: []uint8{0x0B, 0x80}, a: 0xFF,
+			wantA: 0x80, wantP: Negative | Carry, wantCycles: 2},
+		{name: "ALR #", program: []uint8{0x4B, 0x03}, a: 0xFF,
+			wantA: 0x01, wantP: Carry},
+		{name: "ARR #", program: []uint8{0x6B, 0xFF}, a: 0xC0, p: Carry,
+			wantA: 0xE0, wantP: Negative | Carry},
+		{name: "SBX #", program: []uint8{0xCB, 0x01}, a: 0x0F, x: 0xF3,
+			wantA: 0x0F, wantX: 0x02, wantP: Carry},
+		{name: "LXA #", program: []uint8{0xAB, 0x0F}, a: 0x00,
+			wantA: 0x0E, wantX: 0x0E},
+		{name: "ANE #", program: []uint8{0x8B, 0xFF}, a: 0x00, x: 0x0F,
+			wantA: 0x0E, wantX: 0x0F},
+		{name: "SHX abs,Y", program: []uint8{0x9E, 0x00, 0x20}, x: 0xFF, y: 0x01,
+			wantX: 0xFF, wantY: 0x01, wantMemory: map[uint16]uint8{0x2001: 0x21}, wantCycles: 5},
+		{name: "SHY abs,X page cross", program: []uint8{0x9C, 0xFF, 0x20}, x: 0x01, y: 0x01,
+			wantX: 0x01, wantY: 0x01, wantMemory: map[uint16]uint8{0x0100: 0x01}, wantCycles: 5},
+		{name: "NOP abs,X page cross", program: []uint8{0x1C, 0xFF, 0x20}, x: 0x01,
+			wantX: 0x01, wantCycles: 5},
+		{name: "NOP #", program: []uint8{0x80, 0xFF}, wantCycles: 2},
+		{name: "SBC # ($EB)", program: []uint8{0xEB, 0x01}, a: 0x01, p: Carry,
+			wantA: 0x00, wantP: Zero | Carry, wantCycles: 2},
+	} {
+		tt.run(t)
+	}
+}
+
+func TestMagicConstant(t *testing.T) {
+	tt := instructionTest{name: "LXA # magic $FF", program: []uint8{0xAB, 0x5A}, a: 0x00,
+		wantA: 0x5A, wantX: 0x5A}
+	tt.run(t, WithMagicConstant(0xFF))
+}
+
+func TestDecode(t *testing.T) {
+	c := NewCPU(&flatBus{}, WithVariant(WDC65C02))
+	for _, tt := range []struct {
+		opcode     uint8
+		wantName   string
+		wantLength int
+	}{
+		{opcode: 0xEA, wantName: "nop", wantLength: 1},
+		{opcode: 0x0A, wantName: "asl", wantLength: 1},
+		{opcode: 0xA9, wantName: "lda", wantLength: 2},
+		{opcode: 0xD0, wantName: "bne", wantLength: 2},
+		{opcode: 0xB2, wantName: "lda", wantLength: 2},
+		{opcode: 0x6C, wantName: "jmp", wantLength: 3},
+		{opcode: 0x0F, wantName: "bbr0", wantLength: 3},
+	} {
+		info := c.Decode(tt.opcode)
+		if info.Name() != tt.wantName || info.Length() != tt.wantLength {
+			t.Errorf("$%02X is %s, %d bytes, want %s, %d bytes", tt.opcode, info.Name(), info.Length(), tt.wantName, tt.wantLength)
+		}
+	}
+}
+
+func TestDisassembleBranches(t *testing.T) {
+	for _, tt := range []struct {
+		program []uint8
+		want    string
+	}{
+		{program: []uint8{0xD0, 0xFE}, want: "bne $0400"},
+		{program: []uint8{0xF0, 0x10}, want: "beq $0412"},
+		{program: []uint8{0x90, 0x80}, want: "bcc $0382"},
+		{program: []uint8{0x0F, 0x12, 0xFD}, want: "bbr0 $12,$0400"},
+	} {
+		bus := &flatBus{}
+		copy(bus.memory[0x0400:], tt.program)
+		c := NewCPU(bus, WithVariant(WDC65C02))
+		if got := c.DisassembleAt(0x0400); got != tt.want {
+			t.Errorf("DisassembleAt(% X) = %q, want %q", tt.program, got, tt.want)
+		}
+	}
+}
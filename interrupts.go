@@ -0,0 +1,323 @@
+package goemu6502
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// --- Interrupts ---
+// IRQ and NMI are inputs, driven by the devices around the CPU. IRQ is level
+// sensitive and shared: every device gets an IRQSource of its own and the
+// line is asserted while any of them asserts it, like an open collector line
+// with a pull-up. NMI is edge triggered: asserting it latches an NMI that
+// stays pending until it is taken, however long the line is held.
+//
+// The CPU polls the inputs near the end of each instruction, on its
+// next-to-last cycle, and takes the interrupt in place of the next
+// instruction. An interrupt asserted any later than that waits for the end of
+// the following instruction.
+
+const (
+	// vectorNMI and vectorIRQ hold the addresses of the interrupt handlers.
+	// BRK shares the IRQ vector.
+	vectorNMI = 0xFFFA
+	vectorIRQ = 0xFFFE
+
+	// maxIRQSources is how many devices can share the IRQ line
+	maxIRQSources = 64
+)
+
+// Bits of inputPins.active, set while a pin needs the CPU's attention
+const (
+	activeIRQ  uint32 = 1 << iota // IRQ asserted
+	activeNMI                     // NMI pending
+	activeHold                    // RDY low or DMA under way
+	activeSO                      // SO edge pending
+)
+
+// interruptNames are shown in place of the current instruction while an
+// interrupt runs
+var interruptNames = map[uint16]string{
+	vectorNMI:   "NMI",
+	vectorReset: "RESET",
+	vectorIRQ:   "IRQ",
+}
+
+type (
+	// IRQSource identifies one of the devices sharing the IRQ line
+	IRQSource uint8
+
+	// inputPins are the inputs driven by the devices around the CPU. They
+	// have a mutex of their own so that devices can drive them from inside a
+	// bus access, while the CPU is locked. active sums them up so that the
+	// CPU can skip the mutex on the cycles where nothing is going on.
+	inputPins struct {
+		mutex      sync.Mutex
+		active     atomic.Uint32
+		irq        uint64 // IRQ sources asserting the line, one bit each
+		sources    uint8  // IRQ sources handed out by NewIRQSource
+		nmi        bool   // Level of the NMI line
+		nmiPending bool   // NMI edge latch, cleared when the NMI is taken
+		notReady   bool   // RDY pulled low
+		dma        int    // Cycles still to be taken by DMA
+		so         bool   // Level of the SO line, true when pulled low
+		soPending  bool   // SO edge latch, cleared when the overflow flag is set
+	}
+)
+
+// update recomputes the active bits after a pin changes. The mutex must be
+// held.
+func (p *inputPins) update() {
+	var active uint32
+	if p.irq != 0 {
+		active |= activeIRQ
+	}
+	if p.nmiPending {
+		active |= activeNMI
+	}
+	if p.notReady || p.dma > 0 {
+		active |= activeHold
+	}
+	if p.soPending {
+		active |= activeSO
+	}
+	p.active.Store(active)
+}
+
+// newIRQSource hands out the next IRQ source
+func (p *inputPins) newIRQSource() IRQSource {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.sources == maxIRQSources {
+		panic("goemu6502: too many IRQ sources")
+	}
+
+	source := IRQSource(p.sources)
+	p.sources++
+	return source
+}
+
+// setIRQ asserts or releases the IRQ line on behalf of source
+func (p *inputPins) setIRQ(source IRQSource, asserted bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if asserted {
+		p.irq |= 1 << source
+	} else {
+		p.irq &^= 1 << source
+	}
+	p.update()
+}
+
+// irqAsserted reports whether any source asserts the IRQ line
+func (p *inputPins) irqAsserted() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.irq != 0
+}
+
+// setNMI drives the NMI line, latching an NMI on the edge
+func (p *inputPins) setNMI(asserted bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if asserted && !p.nmi {
+		p.nmiPending = true
+	}
+	p.nmi = asserted
+	p.update()
+}
+
+// nmiAsserted reports whether the NMI line is asserted
+func (p *inputPins) nmiAsserted() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.nmi
+}
+
+// sample returns whether an NMI is pending and whether the IRQ line is
+// asserted, without taking the mutex
+func (p *inputPins) sample() (nmi, irq bool) {
+	active := p.active.Load()
+	return active&activeNMI != 0, active&activeIRQ != 0
+}
+
+// takeNMI clears the NMI latch once the NMI is on its way
+func (p *inputPins) takeNMI() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.nmiPending = false
+	p.update()
+}
+
+// NewIRQSource returns a new source for the IRQ line, for a device to assert
+// and release with SetIRQ. It panics if more than 64 are requested.
+func (c *CPU) NewIRQSource() IRQSource {
+	return c.pins.newIRQSource()
+}
+
+// SetIRQ asserts or releases the IRQ line on behalf of source. The line stays
+// asserted until every source has released it. It is safe to call from a bus
+// access.
+func (c *CPU) SetIRQ(source IRQSource, asserted bool) {
+	c.pins.setIRQ(source, asserted)
+}
+
+// IRQ reports whether any source asserts the IRQ line
+func (c *CPU) IRQ() bool {
+	return c.pins.irqAsserted()
+}
+
+// SetNMI drives the NMI line. Asserting it when it was released triggers an
+// NMI; holding it asserted doesn't trigger another. It is safe to call from a
+// bus access.
+func (c *CPU) SetNMI(asserted bool) {
+	c.pins.setNMI(asserted)
+}
+
+// NMI reports whether the NMI line is asserted
+func (c *CPU) NMI() bool {
+	return c.pins.nmiAsserted()
+}
+
+// sampleInterrupts returns whether an NMI is pending and whether the IRQ
+// line is asserted
+func (c *CPU) sampleInterrupts() (nmi, irq bool) {
+	return c.pins.sample()
+}
+
+// takeNMI clears the NMI latch once the NMI is on its way
+func (c *CPU) takeNMI() {
+	c.pins.takeNMI()
+}
+
+// startInstruction resets the per-instruction state on the first cycle of an
+// instruction or interrupt
+func (c *CPU) startInstruction() {
+	c.i.cycle = 1
+	c.i.operated = false
+	c.i.interrupting = false
+	c.i.branchDelay = false
+	c.i.disabled = c.getFlag(InterruptDisable)
+}
+
+// startInterrupt begins an interrupt instead of the next instruction if the
+// last instruction polled one, and reports whether it did. An NMI beats an
+// IRQ.
+func (c *CPU) startInterrupt() bool {
+	if !c.status.pollNMI && !c.status.pollIRQ {
+		return false
+	}
+
+	c.i.vector = vectorIRQ
+	if c.status.pollNMI {
+		c.i.vector = vectorNMI
+		c.takeNMI()
+	}
+
+	c.status.pollNMI = false
+	c.status.pollIRQ = false
+	c.i.interrupting = true
+	c.status.Cycles = 7
+	c.status.interrupts++
+
+	return true
+}
+
+// interrupt performs all of an IRQ, NMI or reset at once
+func (c *CPU) interrupt() {
+	c.pushInterrupt(uint8(c.r.pc >> 8))
+	c.pushInterrupt(uint8(c.r.pc))
+	c.pushStatus(false)
+	c.r.pc = uint16(c.bus.Read(c.i.vector)) | uint16(c.bus.Read(c.i.vector+1))<<8
+}
+
+// pushStatus pushes the processor status for BRK or an interrupt, then
+// disables interrupts. The break flag is only set in the pushed copy, and only
+// by BRK.
+func (c *CPU) pushStatus(brk bool) {
+	var p uint8 = c.r.p | Unused
+	if brk {
+		p |= Break
+	} else {
+		p &^= Break
+	}
+	c.pushInterrupt(p)
+
+	c.setFlag(InterruptDisable, true)
+
+	// The 65C02 also leaves decimal mode
+	if c.variant.cmos() {
+		c.setFlag(Decimal, false)
+	}
+
+	// An NMI that arrives by now hijacks an IRQ, or a BRK on the NMOS 6502,
+	// which then jumps to the NMI handler instead
+	if c.i.vector == vectorIRQ && (!brk || !c.variant.cmos()) {
+		if nmi, _ := c.sampleInterrupts(); nmi {
+			c.takeNMI()
+			c.i.vector = vectorNMI
+		}
+	}
+}
+
+// pushInterrupt pushes a byte for an interrupt or BRK. Reset goes through the
+// same motions, but reads the stack instead of writing it.
+func (c *CPU) pushInterrupt(data uint8) {
+	if c.i.vector != vectorReset {
+		c.pushByte(data)
+		return
+	}
+
+	c.bus.Read(0x100 + uint16(c.r.sp))
+	c.r.sp--
+}
+
+// pollInterrupts samples the interrupt inputs at the end of a cycle. The
+// instruction polls on its next-to-last cycle, and a branch also on its first
+// so that a taken branch that stays on its page, which skips its last poll,
+// still sees interrupts asserted before it started. BRK and the interrupt
+// sequence don't poll at all, so the first instruction of a handler always
+// runs.
+func (c *CPU) pollInterrupts() {
+	if c.i.cycle != 1 && (c.status.Cycles != 1 || c.i.branchDelay) {
+		return
+	}
+
+	if c.i.interrupting || c.status.currentInstruction.Instruction == brk {
+		c.status.pollNMI = false
+		c.status.pollIRQ = false
+		return
+	}
+
+	// CLI, SEI and PLP change the interrupt disable flag after the poll, and
+	// RTI before it. In cycle-exact mode the flag changes on the right cycle;
+	// otherwise the instruction has run already, so look at the flag from
+	// before.
+	var disabled bool = c.getFlag(InterruptDisable)
+	if !c.cycleExact && c.status.currentInstruction.Instruction != rti {
+		disabled = c.i.disabled
+	}
+
+	nmi, irq := c.sampleInterrupts()
+	c.status.pollNMI = nmi
+	c.status.pollIRQ = irq && !disabled
+}
+
+// wake ends a WAI when an interrupt is asserted. A masked IRQ still wakes the
+// CPU, which then carries on with the next instruction.
+func (c *CPU) wake() {
+	nmi, irq := c.sampleInterrupts()
+	if !nmi && !irq {
+		return
+	}
+
+	c.status.waiting = false
+	c.status.pollNMI = nmi
+	c.status.pollIRQ = irq && !c.getFlag(InterruptDisable)
+}
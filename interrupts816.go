@@ -0,0 +1,37 @@
+package goemu6502
+
+// --- 65C816 interrupts ---
+// The 65C816 has the same IRQ and NMI inputs as the 6502, driven the same
+// way. It samples them between instructions rather than on the
+// next-to-last cycle.
+
+// NewIRQSource returns a new source for the IRQ line, for a device to assert
+// and release with SetIRQ. It panics if more than 64 are requested.
+func (c *CPU816) NewIRQSource() IRQSource {
+	return c.pins.newIRQSource()
+}
+
+// SetIRQ asserts or releases the IRQ line on behalf of source. The line stays
+// asserted until every source has released it, and an IRQ is taken before
+// every instruction while it is asserted and interrupts are enabled. It also
+// wakes a CPU waiting in WAI. It is safe to call from a bus access.
+func (c *CPU816) SetIRQ(source IRQSource, asserted bool) {
+	c.pins.setIRQ(source, asserted)
+}
+
+// IRQ reports whether any source asserts the IRQ line
+func (c *CPU816) IRQ() bool {
+	return c.pins.irqAsserted()
+}
+
+// SetNMI drives the NMI line. Asserting it when it was released triggers an
+// NMI; holding it asserted doesn't trigger another. It is safe to call from a
+// bus access.
+func (c *CPU816) SetNMI(asserted bool) {
+	c.pins.setNMI(asserted)
+}
+
+// NMI reports whether the NMI line is asserted
+func (c *CPU816) NMI() bool {
+	return c.pins.nmiAsserted()
+}
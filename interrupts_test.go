@@ -0,0 +1,296 @@
+package goemu6502
+
+import "testing"
+
+// newInterruptCPU creates a CPU running program at $0400 with interrupts
+// enabled, whose NMI handler is at $9000 and IRQ handler at $8000. Both
+// handlers, and whatever follows the program, are NOPs.
+func newInterruptCPU(program []uint8, options ...Option) (*CPU, *flatBus) {
+	bus := &flatBus{}
+	for _, addr := range []uint16{0x0400, 0x8000, 0x9000} {
+		for i := uint16(0); i < 0x10; i++ {
+			bus.memory[addr+i] = 0xEA
+		}
+	}
+	copy(bus.memory[0x0400:], program)
+	bus.memory[vectorNMI], bus.memory[vectorNMI+1] = 0x00, 0x90
+	bus.memory[vectorIRQ], bus.memory[vectorIRQ+1] = 0x00, 0x80
+
+	c := NewCPU(bus, options...)
+	c.SetRegisters(NewRegisters(0, 0, 0, Unused, 0xFD, 0x0400))
+	return c, bus
+}
+
+// bothModes runs a test in the default mode and in cycle-exact mode
+func bothModes(t *testing.T, test func(t *testing.T, options ...Option)) {
+	t.Run("default", func(t *testing.T) { test(t) })
+	t.Run("cycle-exact", func(t *testing.T) { test(t, WithCycleExact()) })
+}
+
+func TestIRQTakenAfterInstruction(t *testing.T) {
+	bothModes(t, func(t *testing.T, options ...Option) {
+		// NOP
+		c, bus := newInterruptCPU([]uint8{0xEA}, options...)
+		c.SetIRQ(c.NewIRQSource(), true)
+
+		c.Step()
+		if c.PC() != 0x0401 {
+			t.Fatalf("PC = $%04X after NOP, want $0401", c.PC())
+		}
+
+		if cycles := c.Step(); cycles != 7 {
+			t.Errorf("IRQ took %d cycles, want 7", cycles)
+		}
+		if c.PC() != 0x8000 {
+			t.Errorf("PC = $%04X after IRQ, want $8000", c.PC())
+		}
+		if !c.Flag(InterruptDisable) {
+			t.Error("interrupts still enabled in the handler")
+		}
+
+		// The return address and the status, without the break flag
+		if got := bus.memory[0x01FB:0x01FE]; got[0] != Unused || got[1] != 0x01 || got[2] != 0x04 {
+			t.Errorf("pushed % X, want 20 01 04", got)
+		}
+	})
+}
+
+func TestIRQIsWiredOr(t *testing.T) {
+	// CLI; NOP
+	c, _ := newInterruptCPU([]uint8{0x58, 0xEA})
+	c.SetFlag(InterruptDisable, true)
+	via, timer := c.NewIRQSource(), c.NewIRQSource()
+
+	c.SetIRQ(via, true)
+	c.SetIRQ(timer, true)
+	c.SetIRQ(via, false)
+	if !c.IRQ() {
+		t.Fatal("IRQ released while the timer still asserts it")
+	}
+
+	c.SetIRQ(timer, false)
+	if c.IRQ() {
+		t.Fatal("IRQ asserted after every source released it")
+	}
+
+	// Nothing asserts the line by the time interrupts are enabled
+	c.Step()
+	c.Step()
+	c.Step()
+	if c.PC() != 0x0403 {
+		t.Errorf("PC = $%04X, want $0403", c.PC())
+	}
+}
+
+func TestNMIIsEdgeTriggered(t *testing.T) {
+	bothModes(t, func(t *testing.T, options ...Option) {
+		// NOP; NOP
+		c, _ := newInterruptCPU([]uint8{0xEA, 0xEA}, options...)
+		c.SetFlag(InterruptDisable, true)
+
+		// An NMI ignores the interrupt disable flag
+		c.SetNMI(true)
+		c.Step()
+		c.Step()
+		if c.PC() != 0x9000 {
+			t.Fatalf("PC = $%04X after NMI, want $9000", c.PC())
+		}
+
+		// Holding the line asserted doesn't trigger another
+		c.Step()
+		c.Step()
+		if c.PC() != 0x9002 {
+			t.Fatalf("PC = $%04X with NMI held, want $9002", c.PC())
+		}
+
+		// Asserting it again does
+		c.SetNMI(false)
+		c.SetNMI(true)
+		c.SetNMI(false)
+		c.Step()
+		c.Step()
+		if c.PC() != 0x9000 {
+			t.Errorf("PC = $%04X after second NMI, want $9000", c.PC())
+		}
+	})
+}
+
+func TestCLIDelaysIRQ(t *testing.T) {
+	bothModes(t, func(t *testing.T, options ...Option) {
+		// CLI; NOP; NOP
+		c, bus := newInterruptCPU([]uint8{0x58, 0xEA, 0xEA}, options...)
+		c.SetFlag(InterruptDisable, true)
+		c.SetIRQ(c.NewIRQSource(), true)
+
+		// The instruction after CLI runs before the IRQ is taken
+		c.Step()
+		c.Step()
+		c.Step()
+		if c.PC() != 0x8000 {
+			t.Fatalf("PC = $%04X, want $8000", c.PC())
+		}
+		if bus.memory[0x01FC] != 0x02 {
+			t.Errorf("returns to $04%02X, want $0402", bus.memory[0x01FC])
+		}
+	})
+}
+
+func TestSEITakesPendingIRQ(t *testing.T) {
+	bothModes(t, func(t *testing.T, options ...Option) {
+		// SEI; NOP
+		c, bus := newInterruptCPU([]uint8{0x78, 0xEA}, options...)
+		c.SetIRQ(c.NewIRQSource(), true)
+
+		// The IRQ polled before SEI took effect still happens, and the pushed
+		// status has interrupts disabled
+		c.Step()
+		c.Step()
+		if c.PC() != 0x8000 {
+			t.Fatalf("PC = $%04X, want $8000", c.PC())
+		}
+		if bus.memory[0x01FB] != InterruptDisable|Unused {
+			t.Errorf("pushed status $%02X, want $24", bus.memory[0x01FB])
+		}
+	})
+}
+
+func TestIRQPolledOnNextToLastCycle(t *testing.T) {
+	bothModes(t, func(t *testing.T, options ...Option) {
+		// LDA abs; NOP; NOP
+		c, _ := newInterruptCPU([]uint8{0xAD, 0x00, 0x20, 0xEA, 0xEA}, options...)
+		irq := c.NewIRQSource()
+
+		// Asserted on the last cycle is too late for LDA...
+		c.Tick()
+		c.Tick()
+		c.Tick()
+		c.SetIRQ(irq, true)
+		c.Tick()
+		c.Step()
+		if c.PC() != 0x0404 {
+			t.Fatalf("PC = $%04X, want $0404", c.PC())
+		}
+
+		// ...but not for the NOP after it
+		c.Step()
+		if c.PC() != 0x8000 {
+			t.Errorf("PC = $%04X, want $8000", c.PC())
+		}
+	})
+}
+
+func TestBranchDelaysIRQ(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		program []uint8
+		wantPC  uint16
+	}{
+		// A taken branch that stays on its page doesn't poll on its last
+		// cycle, so the next instruction runs first
+		{name: "BNE taken", program: []uint8{0xD0, 0x00}, wantPC: 0x0403},
+		{name: "BNE taken across a page", program: []uint8{0xD0, 0xFC}, wantPC: 0x8000},
+		{name: "LDA zp", program: []uint8{0xA5, 0x10}, wantPC: 0x8000},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			bothModes(t, func(t *testing.T, options ...Option) {
+				c, _ := newInterruptCPU(tt.program, options...)
+
+				// Assert IRQ after the first cycle
+				c.Tick()
+				c.SetIRQ(c.NewIRQSource(), true)
+				c.Step()
+				c.Step()
+				if c.PC() != tt.wantPC {
+					t.Errorf("PC = $%04X, want $%04X", c.PC(), tt.wantPC)
+				}
+			})
+		})
+	}
+}
+
+func TestNMIHijacksIRQ(t *testing.T) {
+	// NOP
+	c, bus := newInterruptCPU([]uint8{0xEA}, WithCycleExact())
+	c.SetIRQ(c.NewIRQSource(), true)
+
+	// The NMI arrives while the IRQ pushes the return address
+	c.Step()
+	c.Tick()
+	c.Tick()
+	c.Tick()
+	c.SetNMI(true)
+	c.Step()
+	if c.PC() != 0x9000 {
+		t.Errorf("PC = $%04X, want $9000", c.PC())
+	}
+	if bus.memory[0x01FB]&Break != 0 {
+		t.Error("break flag pushed by IRQ")
+	}
+
+	// The NMI has been taken
+	c.Step()
+	if c.PC() != 0x9001 {
+		t.Errorf("PC = $%04X, want $9001", c.PC())
+	}
+}
+
+func TestNMIHijacksBRK(t *testing.T) {
+	for _, tt := range []struct {
+		variant Variant
+		wantPC  uint16
+	}{
+		{variant: NMOS6502, wantPC: 0x9000},
+		// The 65C02 finishes the BRK, then takes the NMI
+		{variant: CMOS65C02, wantPC: 0x8000},
+	} {
+		t.Run(tt.variant.String(), func(t *testing.T) {
+			// BRK
+			c, bus := newInterruptCPU([]uint8{0x00, 0x00}, WithVariant(tt.variant), WithCycleExact())
+
+			c.Tick()
+			c.Tick()
+			c.Tick()
+			c.SetNMI(true)
+			c.Step()
+			if c.PC() != tt.wantPC {
+				t.Errorf("PC = $%04X, want $%04X", c.PC(), tt.wantPC)
+			}
+			if bus.memory[0x01FB]&Break == 0 {
+				t.Error("break flag not pushed by BRK")
+			}
+		})
+	}
+}
+
+func TestWAIWithIRQAsserted(t *testing.T) {
+	// WAI; NOP
+	c, _ := newInterruptCPU([]uint8{0xCB, 0xEA}, WithVariant(WDC65C02))
+	c.SetFlag(InterruptDisable, true)
+	c.SetIRQ(c.NewIRQSource(), true)
+
+	c.Step()
+	if c.Waiting() {
+		t.Fatal("WAI waits with IRQ asserted")
+	}
+	c.Step()
+	if c.PC() != 0x0402 {
+		t.Errorf("PC = $%04X, want $0402", c.PC())
+	}
+}
+
+func TestResetForgetsNMI(t *testing.T) {
+	c, _ := newInterruptCPU([]uint8{0xEA})
+	c.SetNMI(true)
+	c.Reset()
+	c.Step()
+	c.SetPC(0x0400)
+
+	c.Step()
+	c.Step()
+	if c.PC() != 0x0402 {
+		t.Errorf("PC = $%04X, want $0402", c.PC())
+	}
+	if !c.NMI() {
+		t.Error("NMI line released by reset")
+	}
+}
@@ -0,0 +1,182 @@
+package goemu6502
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// nestest.nes and its reference log are not redistributed with this package.
+// Drop them in testdata to have the NES CPU checked against the log as part of
+// `go test`. See testdata/README.md for details.
+var (
+	nestestROM = flag.String("nestest.rom", "testdata/nestest.nes", "path to the nestest ROM")
+	nestestLog = flag.String("nestest.log", "testdata/nestest.log", "path to the matching nestest log")
+)
+
+const (
+	// nestestStart is where nestest's automated mode begins
+	nestestStart = 0xC000
+
+	// nestestResults holds the error codes of the official and unofficial
+	// opcode tests, both zero on success
+	nestestResults = 0x0002
+)
+
+// nestestLine is the CPU state at the start of one instruction in the log
+type nestestLine struct {
+	pc             uint16
+	bytes          []uint8 // The instruction itself
+	a, x, y, p, sp uint8
+	cycles         uint64
+}
+
+// Log lines look like
+// "C000  4C F5 C5  JMP $C5F5       A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7"
+var nestestLinePattern = regexp.MustCompile(
+	`^([0-9A-F]{4})  ((?:[0-9A-F]{2} ){1,3}).*A:([0-9A-F]{2}) X:([0-9A-F]{2}) Y:([0-9A-F]{2}) P:([0-9A-F]{2}) SP:([0-9A-F]{2}) .*CYC:(\d+)`)
+
+// loadNestestLog parses a nestest log
+func loadNestestLog(path string) ([]nestestLine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []nestestLine
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		m := nestestLinePattern.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			return nil, fmt.Errorf("%s:%d: unrecognised line", path, n)
+		}
+
+		hex := func(s string) uint8 {
+			v, _ := strconv.ParseUint(s, 16, 8)
+			return uint8(v)
+		}
+		pc, _ := strconv.ParseUint(m[1], 16, 16)
+		cycles, _ := strconv.ParseUint(m[8], 10, 64)
+
+		line := nestestLine{
+			pc: uint16(pc),
+			a:  hex(m[3]), x: hex(m[4]), y: hex(m[5]), p: hex(m[6]), sp: hex(m[7]),
+			cycles: cycles,
+		}
+		for _, b := range strings.Fields(m[2]) {
+			line.bytes = append(line.bytes, hex(b))
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+// runNestest steps the CPU through the log, checking the registers and cycle
+// count before every instruction. It returns an error describing the first
+// line that doesn't match.
+func runNestest(c *CPU, log []nestestLine) error {
+	if len(log) == 0 {
+		return nil
+	}
+
+	cycles := log[0].cycles
+	for n, want := range log {
+		r := c.Registers()
+		if r.PC() != want.pc || r.A() != want.a || r.X() != want.x || r.Y() != want.y ||
+			r.P() != want.p || r.SP() != want.sp || cycles != want.cycles {
+			return fmt.Errorf("line %d: got PC=%04X A=%02X X=%02X Y=%02X P=%02X SP=%02X CYC=%d, "+
+				"want PC=%04X A=%02X X=%02X Y=%02X P=%02X SP=%02X CYC=%d", n+1,
+				r.PC(), r.A(), r.X(), r.Y(), r.P(), r.SP(), cycles,
+				want.pc, want.a, want.x, want.y, want.p, want.sp, want.cycles)
+		}
+
+		cycles += uint64(c.Step())
+	}
+
+	return nil
+}
+
+// newNestestCPU returns a 2A03 in the state nestest's automated mode expects
+func newNestestCPU(bus Bus) *CPU {
+	c := NewCPU(bus, WithVariant(Ricoh2A03))
+	c.SetRegisters(NewRegisters(0, 0, 0, InterruptDisable|Unused, 0xFD, nestestStart))
+	return c
+}
+
+func TestNestest(t *testing.T) {
+	rom, err := os.ReadFile(*nestestROM)
+	if os.IsNotExist(err) {
+		t.Skipf("%s not found, see testdata/README.md", *nestestROM)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := loadNestestLog(*nestestLog)
+	if err != nil {
+		t.Fatalf("reading log: %v", err)
+	}
+
+	// Skip the iNES header and map the 16K of PRG ROM at both $8000 and $C000
+	if len(rom) < 16+0x4000 || string(rom[:4]) != "NES\x1A" {
+		t.Fatalf("%s is not an iNES image", *nestestROM)
+	}
+	bus := &flatBus{}
+	copy(bus.memory[0x8000:], rom[16:16+0x4000])
+	copy(bus.memory[0xC000:], rom[16:16+0x4000])
+
+	// The APU and I/O registers read back as $FF in the reference log
+	for addr := 0x4000; addr < 0x4020; addr++ {
+		bus.memory[addr] = 0xFF
+	}
+
+	c := newNestestCPU(bus)
+	if err := runNestest(c, log); err != nil {
+		t.Fatal(err)
+	}
+
+	if bus.memory[nestestResults] != 0 || bus.memory[nestestResults+1] != 0 {
+		t.Errorf("nestest reported error codes $%02X $%02X",
+			bus.memory[nestestResults], bus.memory[nestestResults+1])
+	}
+}
+
+func TestNestestExcerpt(t *testing.T) {
+	log, err := loadNestestLog("testdata/nestest_excerpt.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The excerpt carries its own code
+	bus := &flatBus{}
+	for _, line := range log {
+		copy(bus.memory[line.pc:], line.bytes)
+	}
+
+	c := newNestestCPU(bus)
+	if err := runNestest(c, log); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRicoh2A03IgnoresDecimal(t *testing.T) {
+	for _, tt := range []instructionTest{
+		{name: "ADC 09+01", program: []uint8{0x69, 0x01}, a: 0x09, p: Decimal,
+			wantA: 0x0A, wantP: Decimal, wantCycles: 2},
+		{name: "SBC 10-01", program: []uint8{0xE9, 0x01}, a: 0x10, p: Decimal | Carry,
+			wantA: 0x0F, wantP: Decimal | Carry, wantCycles: 2},
+		{name: "SED", program: []uint8{0xF8}, wantP: Decimal},
+		{name: "ARR #", program: []uint8{0x6B, 0xFF}, a: 0xC0, p: Decimal | Carry,
+			wantA: 0xE0, wantP: Decimal | Negative | Carry},
+	} {
+		tt.run(t, WithVariant(Ricoh2A03))
+	}
+}
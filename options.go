@@ -0,0 +1,19 @@
+package goemu6502
+
+// Option configures a CPU when it is created with NewCPU
+type Option func(*CPU)
+
+const (
+	// DefaultMagicConstant is the magic constant used unless WithMagicConstant
+	// says otherwise. $EE is what most NMOS 6502s produce.
+	DefaultMagicConstant = 0xEE
+)
+
+// WithMagicConstant sets the "magic constant" that the unstable ANE ($8B) and
+// LXA ($AB) opcodes OR into the accumulator. It differs from chip to chip and
+// even with temperature; $EE, $FF and $00 are all seen in the wild.
+func WithMagicConstant(magic uint8) Option {
+	return func(c *CPU) {
+		c.magic = magic
+	}
+}
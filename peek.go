@@ -0,0 +1,118 @@
+package goemu6502
+
+import "errors"
+
+// --- Peeking ---
+// Disassembly, debuggers and tracers look at memory without the CPU reading
+// it. Reading a device register can have side effects, such as clearing a
+// VIA's interrupt flags or taking a byte from an ACIA, so they peek instead:
+// a bus that implements Peeker reads its memory without any. A bus that
+// doesn't can only be peeked by reading it, which has to be allowed with
+// WithPeekFallback.
+
+type (
+	// Peeker is implemented by a Bus that can read memory without side
+	// effects, for debuggers and disassembly
+	Peeker interface {
+		Peek(addr uint16) uint8
+	}
+
+	// Peeker24 is the 65C816's 24-bit counterpart of Peeker
+	Peeker24 interface {
+		Peek(addr uint32) uint8
+	}
+
+	// readPeeker peeks a bus that can't do it by reading it
+	readPeeker struct {
+		bus Bus
+	}
+
+	// readPeeker24 peeks a Bus24 that can't do it by reading it
+	readPeeker24 struct {
+		bus Bus24
+	}
+)
+
+// ErrNoPeek is returned when peeking a bus that doesn't implement Peeker,
+// unless reading it instead has been allowed
+var ErrNoPeek = errors.New("goemu6502: bus cannot be peeked")
+
+// unpeekable is what DisassembleAt returns when the bus can't be peeked
+const unpeekable = "???"
+
+func (p readPeeker) Peek(addr uint16) uint8 {
+	return p.bus.Read(addr)
+}
+
+func (p readPeeker24) Peek(addr uint32) uint8 {
+	return p.bus.Read(addr)
+}
+
+// WithPeekFallback lets disassembly, debuggers and tracers read a bus that
+// doesn't implement Peeker, side effects and all. Without it they can't see
+// its memory at all.
+func WithPeekFallback() Option {
+	return func(c *CPU) {
+		c.peekFallback = true
+	}
+}
+
+// newPeeker returns how to peek bus, or nil if it can't be
+func newPeeker(bus Bus, fallback bool) Peeker {
+	if peeker, ok := bus.(Peeker); ok {
+		return peeker
+	}
+	if fallback {
+		return readPeeker{bus: bus}
+	}
+	return nil
+}
+
+// Peek returns the byte at addr without side effects. It returns ErrNoPeek if
+// the bus doesn't implement Peeker and WithPeekFallback wasn't given.
+func (c *CPU) Peek(addr uint16) (uint8, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.peeker == nil {
+		return 0, ErrNoPeek
+	}
+	return c.peekByte(addr), nil
+}
+
+// peekByte returns the byte at addr without side effects, once the caller
+// knows that the bus can be peeked. The 6510's I/O port is peeked without
+// going to the bus at all.
+func (c *CPU) peekByte(addr uint16) uint8 {
+	if c.port != nil && (addr == portDirection || addr == portData) {
+		return c.port.Read(addr)
+	}
+	return c.peeker.Peek(addr)
+}
+
+// SetPeekFallback allows or forbids disassembly and debuggers to read a bus
+// that doesn't implement Peeker24, side effects and all
+func (c *CPU816) SetPeekFallback(allowed bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.peeker = nil
+	if peeker, ok := c.bus.(Peeker24); ok {
+		c.peeker = peeker
+	} else if allowed {
+		c.peeker = readPeeker24{bus: c.bus}
+	}
+}
+
+// Peek returns the byte at a 24-bit address without side effects. It returns
+// ErrNoPeek if the bus doesn't implement Peeker24 and SetPeekFallback hasn't
+// allowed reading it.
+func (c *CPU816) Peek(addr uint32) (uint8, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.peeker == nil {
+		return 0, ErrNoPeek
+	}
+	return c.peeker.Peek(addr & 0xFFFFFF), nil
+}
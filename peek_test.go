@@ -0,0 +1,101 @@
+package goemu6502
+
+import (
+	"errors"
+	"testing"
+)
+
+// aciaBus is RAM with an ACIA receive register at $D000, which hands out the
+// next byte every time it is read
+type aciaBus struct {
+	memory   [0x10000]uint8
+	received uint8
+}
+
+func (b *aciaBus) Read(addr uint16) uint8 {
+	if addr == 0xD000 {
+		b.received++
+		return b.received
+	}
+	return b.memory[addr]
+}
+
+func (b *aciaBus) Write(addr uint16, value uint8) {
+	b.memory[addr] = value
+}
+
+// peekingACIABus can also be peeked
+type peekingACIABus struct {
+	aciaBus
+}
+
+func (b *peekingACIABus) Peek(addr uint16) uint8 {
+	if addr == 0xD000 {
+		return b.received
+	}
+	return b.memory[addr]
+}
+
+func TestDisassembleAtPeeks(t *testing.T) {
+	// LDA $D000
+	bus := &peekingACIABus{}
+	copy(bus.memory[0x0400:], []uint8{0xAD, 0x00, 0xD0})
+	c := NewCPU(bus)
+
+	if got := c.DisassembleAt(0x0400); got != "lda $D000" {
+		t.Errorf("DisassembleAt = %q, want %q", got, "lda $D000")
+	}
+	if value, err := c.Peek(0xD000); err != nil || value != 0 {
+		t.Errorf("Peek = $%02X, %v, want $00", value, err)
+	}
+	if bus.received != 0 {
+		t.Errorf("ACIA read %d times", bus.received)
+	}
+}
+
+func TestPeekFallback(t *testing.T) {
+	bus := &aciaBus{}
+	copy(bus.memory[0x0400:], []uint8{0xAD, 0x00, 0xD0})
+
+	// Without a Peeker the bus can't be peeked...
+	c := NewCPU(bus)
+	if got := c.DisassembleAt(0x0400); got != "???" {
+		t.Errorf("DisassembleAt = %q, want %q", got, "???")
+	}
+	if _, err := c.Peek(0xD000); !errors.Is(err, ErrNoPeek) {
+		t.Errorf("Peek returned %v, want ErrNoPeek", err)
+	}
+	if bus.received != 0 {
+		t.Fatalf("ACIA read %d times", bus.received)
+	}
+
+	// ...unless reading it is allowed
+	c = NewCPU(bus, WithPeekFallback())
+	if got := c.DisassembleAt(0x0400); got != "lda $D000" {
+		t.Errorf("DisassembleAt = %q, want %q", got, "lda $D000")
+	}
+	if value, err := c.Peek(0xD000); err != nil || value != 1 {
+		t.Errorf("Peek = $%02X, %v, want $01", value, err)
+	}
+}
+
+func TestPeekPort(t *testing.T) {
+	bus := &flatBus{}
+	bus.memory[0x0001] = 0xAA
+	c := NewCPU(bus, WithVariant(MOS6510))
+	c.SetPortInputs(0xFF, 0x17)
+
+	// The port answers, not the RAM underneath it
+	if value, _ := c.Peek(0x0001); value != 0x17 {
+		t.Errorf("Peek($0001) = $%02X, want $17", value)
+	}
+}
+
+func TestPeek816(t *testing.T) {
+	bus := sparseBus{0x018000: 0xEA}
+	c := NewCPU816(bus)
+
+	if value, err := c.Peek(0x018000); err != nil || value != 0xEA {
+		t.Errorf("Peek = $%02X, %v, want $EA", value, err)
+	}
+}
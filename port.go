@@ -0,0 +1,179 @@
+package goemu6502
+
+const (
+	// DefaultPortFadeCycles is how long an undriven input of the 6510 I/O port
+	// holds its last value unless WithPortFadeCycles says otherwise. It is
+	// roughly what a C64 shows for bits 6 and 7 of $01.
+	DefaultPortFadeCycles = 350_000
+
+	// portDirection and portData are where the 6510 I/O port lives
+	portDirection = 0x0000
+	portData      = 0x0001
+
+	// portPins are the bits of the port that have pins on the 6510
+	portPins = 0x3F
+)
+
+// ioPort is the 6510's on-chip I/O port. It sits in front of the bus and
+// answers reads and writes of $0000 (data direction) and $0001 (data) itself;
+// everything else goes through to the bus.
+type ioPort struct {
+	bus Bus
+	cpu *CPU // For the cycle count, which times the fade of floating inputs
+
+	direction uint8 // 1 bits are outputs
+	data      uint8 // Output latch
+
+	inputMask   uint8 // Input bits driven by external hardware...
+	inputLevels uint8 // ...and the levels they are driven to
+
+	charge     uint8     // Last value of each floating input...
+	fadeAt     [8]uint64 // ...and the cycle at which it fades to 0
+	fadeCycles uint64
+	pins       uint8 // Pin levels last reported to onChange
+	onChange   func(pins uint8)
+}
+
+// WithPortFadeCycles sets how many cycles an undriven input of the 6510 I/O
+// port holds the value it was last driven to before fading to 0
+func WithPortFadeCycles(cycles uint64) Option {
+	return func(c *CPU) {
+		c.portFadeCycles = cycles
+	}
+}
+
+func newIOPort(bus Bus, c *CPU) *ioPort {
+	return &ioPort{bus: bus, cpu: c, fadeCycles: c.portFadeCycles}
+}
+
+func (p *ioPort) Read(addr uint16) uint8 {
+	switch addr {
+	case portDirection:
+		return p.direction
+	case portData:
+		return p.read()
+	default:
+		return p.bus.Read(addr)
+	}
+}
+
+func (p *ioPort) Write(addr uint16, value uint8) {
+	switch addr {
+	case portDirection:
+		// Outputs that turn into floating inputs start fading from their last value
+		now := p.cpu.status.totalCycles
+		for bit := 0; bit < 8; bit++ {
+			var mask uint8 = 1 << bit
+			if p.direction&mask != 0 && value&mask == 0 {
+				p.charge = p.charge&^mask | p.data&mask
+				p.fadeAt[bit] = now + p.fadeCycles
+			}
+		}
+		p.direction = value
+	case portData:
+		p.data = value
+	default:
+		p.bus.Write(addr, value)
+		return
+	}
+
+	p.update()
+}
+
+// read returns what the CPU sees at $0001: the output latch for outputs, the
+// external level for driven inputs and the fading charge for floating ones
+func (p *ioPort) read() uint8 {
+	now := p.cpu.status.totalCycles
+
+	var floating uint8
+	for bit := 0; bit < 8; bit++ {
+		if now < p.fadeAt[bit] {
+			floating |= p.charge & (1 << bit)
+		}
+	}
+
+	var driven uint8 = p.inputMask &^ p.direction
+	var undriven uint8 = ^p.inputMask &^ p.direction
+
+	return p.data&p.direction | p.inputLevels&driven | floating&undriven
+}
+
+// update reports the pin levels to onChange if they have changed
+func (p *ioPort) update() {
+	var pins uint8 = p.read() & portPins
+	if pins == p.pins {
+		return
+	}
+
+	p.pins = pins
+	if p.onChange != nil {
+		p.onChange(pins)
+	}
+}
+
+// reset makes every pin an input, as the 6510 does on reset
+func (p *ioPort) reset() {
+	p.direction = 0x00
+	p.data = 0x00
+	p.charge = 0x00
+	p.update()
+}
+
+// restore loads the port registers from a saved state. The charge on floating
+// inputs isn't saved, so they start fading afresh from the output latch.
+func (p *ioPort) restore(direction, data uint8) {
+	now := p.cpu.status.totalCycles
+	for bit := range p.fadeAt {
+		p.fadeAt[bit] = now + p.fadeCycles
+	}
+
+	p.direction = direction
+	p.data = data
+	p.charge = data &^ direction
+	p.update()
+}
+
+// SetPortInputs sets what external hardware drives onto the 6510 I/O port
+// pins that are inputs: bits set in mask are driven to the matching bit of
+// levels, the rest float. A C64 pulls up bits 0-2 and the cassette sense on
+// bit 4, i.e. SetPortInputs(0x17, 0x17). It does nothing on other variants.
+func (c *CPU) SetPortInputs(mask, levels uint8) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.port == nil {
+		return
+	}
+
+	c.port.inputMask = mask & portPins
+	c.port.inputLevels = levels & mask & portPins
+	c.port.update()
+}
+
+// OnPortChange registers a function to call when the levels on the 6510 I/O
+// port pins change, e.g. to switch banks on a C64. The handler runs while the
+// CPU is locked, so it must not call back into the CPU. It is never called on
+// other variants.
+func (c *CPU) OnPortChange(handler func(pins uint8)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.port == nil {
+		return
+	}
+
+	c.port.onChange = handler
+}
+
+// PortPins returns the levels on the 6510 I/O port pins, or 0 on other
+// variants
+func (c *CPU) PortPins() uint8 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.port == nil {
+		return 0
+	}
+
+	return c.port.read() & portPins
+}
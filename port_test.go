@@ -0,0 +1,98 @@
+package goemu6502
+
+import "testing"
+
+func TestPortInterceptsBus(t *testing.T) {
+	// LDA #$2F; STA $00; LDA #$37; STA $01; LDA $01
+	bus := &flatBus{}
+	copy(bus.memory[0x0400:], []uint8{0xA9, 0x2F, 0x85, 0x00, 0xA9, 0x37, 0x85, 0x01, 0xA5, 0x01})
+	bus.memory[0x0001] = 0xAA
+
+	c := NewCPU(bus, WithVariant(MOS6510))
+	c.SetPC(0x0400)
+	c.SetPortInputs(0x17, 0x17)
+
+	var changes []uint8
+	c.OnPortChange(func(pins uint8) {
+		changes = append(changes, pins)
+	})
+
+	for i := 0; i < 5; i++ {
+		c.Step()
+	}
+
+	if bus.memory[0x0000] != 0x00 || bus.memory[0x0001] != 0xAA {
+		t.Errorf("port writes reached the bus: $00=%02X $01=%02X", bus.memory[0x0000], bus.memory[0x0001])
+	}
+
+	// Bits 0-3 and 5 are outputs driven to 0111, bit 4 is pulled up
+	if c.A() != 0x37 {
+		t.Errorf("read $%02X from $01, want $37", c.A())
+	}
+	if c.PortPins() != 0x37 {
+		t.Errorf("pins = $%02X, want $37", c.PortPins())
+	}
+
+	// Making bits 0-3 and 5 outputs drove them low from the empty latch, then
+	// writing the data drove them to 0111
+	want := []uint8{0x10, 0x37}
+	if len(changes) != len(want) {
+		t.Fatalf("got changes %02X, want %02X", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d: got $%02X, want $%02X", i, changes[i], want[i])
+		}
+	}
+}
+
+func TestPortReset(t *testing.T) {
+	c := NewCPU(&flatBus{}, WithVariant(MOS6510))
+	c.SetPortInputs(0x17, 0x17)
+
+	c.bus.Write(0x0000, 0xFF)
+	c.bus.Write(0x0001, 0x00)
+	if c.PortPins() != 0x00 {
+		t.Fatalf("pins = $%02X, want $00", c.PortPins())
+	}
+
+	// After reset, every bit is an input again and the pull-ups win
+	c.Reset()
+	if c.PortPins() != 0x17 {
+		t.Errorf("pins after reset = $%02X, want $17", c.PortPins())
+	}
+}
+
+func TestPortFloatingBitsFade(t *testing.T) {
+	// NOP; JMP $0400
+	bus := &flatBus{}
+	copy(bus.memory[0x0400:], []uint8{0xEA, 0x4C, 0x00, 0x04})
+
+	c := NewCPU(bus, WithVariant(MOS6510), WithPortFadeCycles(100))
+	c.SetPC(0x0400)
+
+	// Drive bits 6 and 7 high, then let them float
+	c.bus.Write(0x0000, 0xC0)
+	c.bus.Write(0x0001, 0xC0)
+	c.bus.Write(0x0000, 0x00)
+
+	if got := c.bus.Read(0x0001); got != 0xC0 {
+		t.Errorf("read $%02X just after floating, want $C0", got)
+	}
+
+	c.Run(100)
+	if got := c.bus.Read(0x0001); got != 0x00 {
+		t.Errorf("read $%02X after fading, want $00", got)
+	}
+}
+
+func TestPortOnlyOn6510(t *testing.T) {
+	bus := &flatBus{}
+	bus.memory[0x0001] = 0x42
+
+	c := NewCPU(bus)
+	c.SetPortInputs(0x17, 0x17)
+	if c.bus.Read(0x0001) != 0x42 || c.PortPins() != 0 {
+		t.Error("NMOS 6502 has an I/O port")
+	}
+}
@@ -0,0 +1,39 @@
+package goemu6502
+
+import (
+	"fmt"
+	"os"
+)
+
+// RAM is 64K of RAM and nothing else, the bus of tools that run programs
+// without any I/O. Peeking it is the same as reading it.
+type RAM struct {
+	Memory [0x10000]uint8
+}
+
+func (r *RAM) Read(addr uint16) uint8 {
+	return r.Memory[addr]
+}
+
+func (r *RAM) Write(addr uint16, value uint8) {
+	r.Memory[addr] = value
+}
+
+func (r *RAM) Peek(addr uint16) uint8 {
+	return r.Memory[addr]
+}
+
+// LoadFile copies a file into memory at addr, and returns the address after
+// the last byte loaded
+func (r *RAM) LoadFile(name string, addr uint16) (uint16, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) > 0x10000-int(addr) {
+		return 0, fmt.Errorf("%s: %d bytes don't fit at $%04X", name, len(data), addr)
+	}
+
+	copy(r.Memory[addr:], data)
+	return addr + uint16(len(data)), nil
+}
@@ -0,0 +1,102 @@
+package goemu6502
+
+// --- RDY and DMA ---
+// Pulling RDY low holds the CPU on its next read cycle until RDY goes high
+// again; the NMOS 6502 ignores RDY on write cycles, so it finishes any writes
+// first. The 65C02 stops on writes too. Video chips and DMA controllers use
+// this to borrow the bus, e.g. NES OAM DMA or C64 badlines. Every cycle the
+// CPU spends held counts towards the cycle count, as well as towards
+// StolenCycles.
+//
+// Without cycle-exact mode an instruction makes all of its accesses on its
+// first cycle, so the CPU is held between instructions instead.
+
+// SetRDY drives the RDY line. The CPU runs while it is high and stops on its
+// next read cycle once it goes low. It is safe to call from a bus access.
+func (c *CPU) SetRDY(ready bool) {
+	c.pins.mutex.Lock()
+	defer c.pins.mutex.Unlock()
+
+	c.pins.notReady = !ready
+	c.pins.update()
+}
+
+// RDY reports whether the RDY line is high
+func (c *CPU) RDY() bool {
+	c.pins.mutex.Lock()
+	defer c.pins.mutex.Unlock()
+
+	return !c.pins.notReady
+}
+
+// StealCycles takes the bus away from the CPU for the given number of
+// cycles, as a DMA controller does by pulling RDY low. The cycles start on
+// the CPU's next read cycle, and add to any that are still to be taken. It is
+// safe to call from a bus access.
+func (c *CPU) StealCycles(cycles int) {
+	c.pins.mutex.Lock()
+	defer c.pins.mutex.Unlock()
+
+	if cycles > 0 {
+		c.pins.dma += cycles
+	}
+	c.pins.update()
+}
+
+// StolenCycles returns how many cycles the CPU has been held by RDY or DMA
+// since it was created
+func (c *CPU) StolenCycles() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.status.stolenCycles
+}
+
+// held reports whether RDY or DMA holds the CPU on this cycle, and counts the
+// cycle as stolen if so
+func (c *CPU) held() bool {
+	if c.pins.active.Load()&activeHold == 0 {
+		return false
+	}
+
+	c.pins.mutex.Lock()
+	defer c.pins.mutex.Unlock()
+
+	if !c.pins.notReady && c.pins.dma == 0 {
+		return false
+	}
+	if !c.holdable() {
+		return false
+	}
+
+	if c.pins.dma > 0 {
+		c.pins.dma--
+		c.pins.update()
+	}
+	c.status.stolenCycles++
+	return true
+}
+
+// idleDMA lets a DMA carry on while the CPU is halted or waiting, which has
+// nothing to steal
+func (c *CPU) idleDMA() {
+	if c.pins.active.Load()&activeHold == 0 {
+		return
+	}
+
+	c.pins.mutex.Lock()
+	defer c.pins.mutex.Unlock()
+
+	if c.pins.dma > 0 {
+		c.pins.dma--
+		c.pins.update()
+	}
+}
+
+// holdable reports whether RDY can stop the CPU on this cycle
+func (c *CPU) holdable() bool {
+	if !c.cycleExact {
+		return c.status.Cycles == 0
+	}
+	return c.variant.cmos() || !c.writeCycle()
+}
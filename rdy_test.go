@@ -0,0 +1,168 @@
+package goemu6502
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// oamDMABus starts a 513 cycle DMA when $4014 is written, like the NES
+type oamDMABus struct {
+	recordingBus
+	cpu *CPU
+}
+
+func (b *oamDMABus) Write(addr uint16, value uint8) {
+	b.recordingBus.Write(addr, value)
+	if addr == 0x4014 {
+		b.cpu.StealCycles(513)
+	}
+}
+
+func TestRDYWaitsForReadCycle(t *testing.T) {
+	for _, tt := range []struct {
+		variant  Variant
+		wantSeen int // Accesses made after RDY goes low
+	}{
+		// The NMOS 6502 finishes the two writes of INC first
+		{variant: NMOS6502, wantSeen: 2},
+		{variant: CMOS65C02, wantSeen: 0},
+	} {
+		t.Run(tt.variant.String(), func(t *testing.T) {
+			// INC $10
+			bus := &recordingBus{}
+			copy(bus.memory[0x0400:], []uint8{0xE6, 0x10})
+			c := NewCPU(bus, WithVariant(tt.variant), WithCycleExact())
+			c.SetPC(0x0400)
+
+			// Pull RDY low once INC has read its operand
+			c.Tick()
+			c.Tick()
+			c.Tick()
+			c.SetRDY(false)
+			before := len(bus.accesses)
+			for i := 0; i < 10; i++ {
+				c.Tick()
+			}
+			if seen := len(bus.accesses) - before; seen != tt.wantSeen {
+				t.Errorf("made %d accesses with RDY low, want %d", seen, tt.wantSeen)
+			}
+			if stolen := c.StolenCycles(); stolen != uint64(10-tt.wantSeen) {
+				t.Errorf("stole %d cycles, want %d", stolen, 10-tt.wantSeen)
+			}
+
+			// The CPU carries on where it stopped
+			c.SetRDY(true)
+			c.Step()
+			if bus.memory[0x10] != 0x01 {
+				t.Errorf("$10 = $%02X, want $01", bus.memory[0x10])
+			}
+		})
+	}
+}
+
+func TestStealCycles(t *testing.T) {
+	bothModes(t, func(t *testing.T, options ...Option) {
+		// STA $4014; NOP
+		bus := &oamDMABus{}
+		copy(bus.memory[0x0400:], []uint8{0x8D, 0x14, 0x40, 0xEA})
+		c := NewCPU(bus, options...)
+		bus.cpu = c
+		c.SetPC(0x0400)
+
+		// The DMA holds the CPU on the opcode fetch of NOP
+		cycles := 0
+		for c.PC() != 0x0404 {
+			c.Tick()
+			cycles++
+		}
+		if cycles != 4+513+1 {
+			t.Errorf("took %d cycles to fetch NOP, want %d", cycles, 4+513+1)
+		}
+		if stolen := c.StolenCycles(); stolen != 513 {
+			t.Errorf("stole %d cycles, want 513", stolen)
+		}
+	})
+}
+
+// stackRDYBus pulls RDY low when the stack at $0111 is written
+type stackRDYBus struct {
+	recordingBus
+	cpu *CPU
+}
+
+func (b *stackRDYBus) Write(addr uint16, value uint8) {
+	b.recordingBus.Write(addr, value)
+	if addr == 0x0111 {
+		b.cpu.SetRDY(false)
+	}
+}
+
+func TestStepReturnsWhenHeld(t *testing.T) {
+	// JSR $1234, which pushes its return address to $0112 and $0111
+	bus := &stackRDYBus{}
+	copy(bus.memory[0x0400:], []uint8{0x20, 0x34, 0x12})
+	c := NewCPU(bus, WithCycleExact())
+	bus.cpu = c
+	c.SetRegisters(NewRegisters(0, 0, 0, Unused, 0x12, 0x0400))
+
+	// The push pulls RDY low, which holds JSR on its last read
+	done := make(chan int)
+	go func() { done <- c.Step() }()
+	select {
+	case cycles := <-done:
+		if cycles != 6 || c.Complete() {
+			t.Errorf("Step returned after %d cycles with JSR complete %t, want 6 and false", cycles, c.Complete())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Step hung with RDY low")
+	}
+
+	// Every further Step is held straight away
+	if cycles := c.Step(); cycles != 1 || c.PC() != 0x0402 {
+		t.Errorf("Step returned after %d cycles at $%04X, want 1 at $0402", cycles, c.PC())
+	}
+
+	c.SetRDY(true)
+	if cycles := c.Step(); cycles != 1 || c.PC() != 0x1234 || !c.Complete() {
+		t.Errorf("Step returned after %d cycles at $%04X, want 1 at $1234", cycles, c.PC())
+	}
+	if stolen := c.StolenCycles(); stolen != 2 {
+		t.Errorf("stole %d cycles, want 2", stolen)
+	}
+}
+
+// TestWriteCycleMatchesBus checks that writeCycle, which decides whether RDY
+// can stop the CPU, predicts every access of every opcode
+func TestWriteCycleMatchesBus(t *testing.T) {
+	rng := rand.New(rand.NewSource(6502))
+
+	for _, variant := range []Variant{NMOS6502, CMOS65C02, WDC65C02} {
+		for opcode := 0; opcode < 0x100; opcode++ {
+			for i := 0; i < 4; i++ {
+				bus := &recordingBus{}
+				rng.Read(bus.memory[:])
+
+				var pc uint16 = uint16(rng.Intn(0x10000))
+				bus.memory[pc] = uint8(opcode)
+
+				c := NewCPU(bus, WithVariant(variant), WithCycleExact())
+				c.SetRegisters(NewRegisters(uint8(rng.Intn(0x100)), uint8(rng.Intn(0x100)), uint8(rng.Intn(0x100)),
+					uint8(rng.Intn(0x100))|Unused, uint8(rng.Intn(0x100)), pc))
+
+				for n := 1; ; n++ {
+					want := c.writeCycle()
+					before := len(bus.accesses)
+					c.Tick()
+					if len(bus.accesses) > before && bus.accesses[before].write != want {
+						t.Fatalf("%s $%02X cycle %d: predicted write %t, got %s",
+							variant, opcode, n, want, bus.accesses[before])
+					}
+					if c.Complete() {
+						break
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package goemu6502
+
+import "strings"
+
+// Flags is a read-only view over the processor status register
+type Flags uint8
+
+// flagLetters lists the status flags from bit 7 down to bit 0
+const flagLetters = "NV-BDIZC"
+
+// Has reports whether flag is set
+func (f Flags) Has(flag StatusFlag) bool {
+	return uint8(f)&uint8(flag) != 0
+}
+
+// String returns the flags in "NV-BDIZC" order, upper case when set and
+// lower case when clear.
+func (f Flags) String() string {
+	var sb strings.Builder
+	for i := 0; i < 8; i++ {
+		letter := flagLetters[i]
+		if f&(0x80>>i) == 0 && letter != '-' {
+			letter += 'a' - 'A'
+		}
+		sb.WriteByte(letter)
+	}
+	return sb.String()
+}
+
+// A returns the accumulator
+func (r Registers) A() uint8 {
+	return r.a
+}
+
+// X returns the X index register
+func (r Registers) X() uint8 {
+	return r.x
+}
+
+// Y returns the Y index register
+func (r Registers) Y() uint8 {
+	return r.y
+}
+
+// P returns the processor status register
+func (r Registers) P() uint8 {
+	return r.p
+}
+
+// SP returns the stack pointer
+func (r Registers) SP() uint8 {
+	return r.sp
+}
+
+// PC returns the program counter
+func (r Registers) PC() uint16 {
+	return r.pc
+}
+
+// Flags returns a view over the processor status register
+func (r Registers) Flags() Flags {
+	return Flags(r.p)
+}
+
+// NewRegisters builds a register set, e.g. for seeding a CPU with SetRegisters
+func NewRegisters(a, x, y, p, sp uint8, pc uint16) Registers {
+	return Registers{a: a, x: x, y: y, p: p, sp: sp, pc: pc}
+}
+
+// Registers returns a snapshot of all registers
+func (c *CPU) Registers() Registers {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.r
+}
+
+// SetRegisters replaces all registers at once
+func (c *CPU) SetRegisters(r Registers) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.r = r
+}
+
+// A returns the accumulator
+func (c *CPU) A() uint8 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.r.a
+}
+
+// SetA sets the accumulator
+func (c *CPU) SetA(value uint8) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.r.a = value
+}
+
+// X returns the X index register
+func (c *CPU) X() uint8 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.r.x
+}
+
+// SetX sets the X index register
+func (c *CPU) SetX(value uint8) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.r.x = value
+}
+
+// Y returns the Y index register
+func (c *CPU) Y() uint8 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.r.y
+}
+
+// SetY sets the Y index register
+func (c *CPU) SetY(value uint8) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.r.y = value
+}
+
+// P returns the processor status register
+func (c *CPU) P() uint8 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.r.p
+}
+
+// SetP sets the processor status register
+func (c *CPU) SetP(value uint8) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.r.p = value
+}
+
+// SP returns the stack pointer
+func (c *CPU) SP() uint8 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.r.sp
+}
+
+// SetSP sets the stack pointer
+func (c *CPU) SetSP(value uint8) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.r.sp = value
+}
+
+// PC returns the program counter
+func (c *CPU) PC() uint16 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.r.pc
+}
+
+// SetPC sets the program counter, e.g. to start execution somewhere other
+// than the reset vector. It should only be called between instructions.
+func (c *CPU) SetPC(value uint16) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.r.pc = value
+}
+
+// Flags returns a view over the processor status register
+func (c *CPU) Flags() Flags {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return Flags(c.r.p)
+}
+
+// Flag reports whether a single status flag is set
+func (c *CPU) Flag(flag StatusFlag) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.getFlag(flag)
+}
+
+// SetFlag sets or clears a single status flag
+func (c *CPU) SetFlag(flag StatusFlag, value bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.setFlag(flag, value)
+}
@@ -0,0 +1,80 @@
+package goemu6502
+
+// A returns the full 16-bit accumulator
+func (r Registers816) A() uint16 {
+	return r.a
+}
+
+// X returns the X index register
+func (r Registers816) X() uint16 {
+	return r.x
+}
+
+// Y returns the Y index register
+func (r Registers816) Y() uint16 {
+	return r.y
+}
+
+// SP returns the stack pointer
+func (r Registers816) SP() uint16 {
+	return r.sp
+}
+
+// D returns the direct page register
+func (r Registers816) D() uint16 {
+	return r.d
+}
+
+// PC returns the program counter within the program bank
+func (r Registers816) PC() uint16 {
+	return r.pc
+}
+
+// PBR returns the program bank register
+func (r Registers816) PBR() uint8 {
+	return r.pbr
+}
+
+// DBR returns the data bank register
+func (r Registers816) DBR() uint8 {
+	return r.dbr
+}
+
+// P returns the processor status register
+func (r Registers816) P() uint8 {
+	return r.p
+}
+
+// E reports whether the CPU is in emulation mode
+func (r Registers816) E() bool {
+	return r.e
+}
+
+// Flags returns a view over the processor status register. In native mode,
+// bits 4 and 5 are IndexWidth and MemoryWidth rather than B and unused.
+func (r Registers816) Flags() Flags {
+	return Flags(r.p)
+}
+
+// NewRegisters816 builds a register set, e.g. for seeding a CPU816 with
+// SetRegisters
+func NewRegisters816(a, x, y, sp, d, pc uint16, pbr, dbr, p uint8, e bool) Registers816 {
+	return Registers816{a: a, x: x, y: y, sp: sp, d: d, pc: pc, pbr: pbr, dbr: dbr, p: p, e: e}
+}
+
+// Registers returns a snapshot of all registers
+func (c *CPU816) Registers() Registers816 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.r
+}
+
+// SetRegisters replaces all registers at once. It should only be called
+// between instructions.
+func (c *CPU816) SetRegisters(r Registers816) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.r = r
+}
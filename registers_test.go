@@ -0,0 +1,107 @@
+package goemu6502
+
+import "testing"
+
+func TestFlags(t *testing.T) {
+	for _, tt := range []struct {
+		p    uint8
+		want string
+	}{
+		{p: 0x00, want: "nv-bdizc"},
+		{p: 0xFF, want: "NV-BDIZC"},
+		{p: Unused | InterruptDisable, want: "nv-bdIzc"},
+		{p: Negative | Zero | Carry, want: "Nv-bdiZC"},
+		{p: Overflow | Break | Decimal, want: "nV-BDizc"},
+	} {
+		if got := Flags(tt.p).String(); got != tt.want {
+			t.Errorf("Flags($%02X) = %q, want %q", tt.p, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		flag StatusFlag
+		want bool
+	}{
+		{flag: Carry, want: true},
+		{flag: Zero, want: false},
+		{flag: InterruptDisable, want: true},
+		{flag: Decimal, want: false},
+		{flag: Negative, want: true},
+	} {
+		if got := Flags(Carry | InterruptDisable | Negative).Has(tt.flag); got != tt.want {
+			t.Errorf("Has(%08b) = %t, want %t", tt.flag, got, tt.want)
+		}
+	}
+}
+
+func TestRegisters(t *testing.T) {
+	r := NewRegisters(0x11, 0x22, 0x33, Carry|Unused, 0xFD, 0x1234)
+	for _, tt := range []struct {
+		name      string
+		got, want int
+	}{
+		{name: "A", got: int(r.A()), want: 0x11},
+		{name: "X", got: int(r.X()), want: 0x22},
+		{name: "Y", got: int(r.Y()), want: 0x33},
+		{name: "P", got: int(r.P()), want: 0x21},
+		{name: "SP", got: int(r.SP()), want: 0xFD},
+		{name: "PC", got: int(r.PC()), want: 0x1234},
+		{name: "Flags", got: int(r.Flags()), want: 0x21},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s = $%X, want $%X", tt.name, tt.got, tt.want)
+		}
+	}
+
+	c := NewCPU(&flatBus{})
+	c.SetRegisters(r)
+	if got := c.Registers(); got != r {
+		t.Errorf("Registers = %+v, want %+v", got, r)
+	}
+}
+
+func TestRegisterSetters(t *testing.T) {
+	c := NewCPU(&flatBus{})
+	for _, tt := range []struct {
+		name string
+		set  func()
+		get  func() int
+		want int
+	}{
+		{name: "A", set: func() { c.SetA(0x41) }, get: func() int { return int(c.A()) }, want: 0x41},
+		{name: "X", set: func() { c.SetX(0x42) }, get: func() int { return int(c.X()) }, want: 0x42},
+		{name: "Y", set: func() { c.SetY(0x43) }, get: func() int { return int(c.Y()) }, want: 0x43},
+		{name: "P", set: func() { c.SetP(0xC3) }, get: func() int { return int(c.P()) }, want: 0xC3},
+		{name: "SP", set: func() { c.SetSP(0x80) }, get: func() int { return int(c.SP()) }, want: 0x80},
+		{name: "PC", set: func() { c.SetPC(0xBEEF) }, get: func() int { return int(c.PC()) }, want: 0xBEEF},
+	} {
+		tt.set()
+		if got := tt.get(); got != tt.want {
+			t.Errorf("%s = $%X, want $%X", tt.name, got, tt.want)
+		}
+	}
+
+	// The setters leave the other registers alone
+	if r := c.Registers(); r != NewRegisters(0x41, 0x42, 0x43, 0xC3, 0x80, 0xBEEF) {
+		t.Errorf("Registers = %+v", r)
+	}
+	if c.Flags() != Flags(0xC3) {
+		t.Errorf("Flags = %s, want NV----ZC", c.Flags())
+	}
+
+	c.SetFlag(Zero, false)
+	c.SetFlag(Decimal, true)
+	for _, tt := range []struct {
+		flag StatusFlag
+		want bool
+	}{
+		{flag: Zero, want: false},
+		{flag: Decimal, want: true},
+		{flag: Carry, want: true},
+		{flag: InterruptDisable, want: false},
+	} {
+		if got := c.Flag(tt.flag); got != tt.want {
+			t.Errorf("Flag(%08b) = %t, want %t", tt.flag, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package goemu6502
+
+import "math/rand"
+
+// PowerOn selects what the registers hold when the CPU is created
+type PowerOn uint8
+
+const (
+	// PowerOnZero starts A, X, Y and the flags at zero. This is the default.
+	PowerOnZero PowerOn = iota
+
+	// PowerOnRandom starts A, X, Y and the flags with random values, like a
+	// real chip, for shaking out software that forgets to initialise them
+	PowerOnRandom
+)
+
+// WithPowerOn sets what A, X, Y and the flags hold when the CPU is created.
+// Reset leaves them alone, apart from disabling interrupts.
+func WithPowerOn(registers PowerOn) Option {
+	return func(c *CPU) {
+		c.powerOn = registers
+	}
+}
+
+// WithPowerOnSP sets the stack pointer the CPU is created with, $00 unless
+// this says otherwise. Reset decrements it three times, leaving $FD.
+func WithPowerOnSP(sp uint8) Option {
+	return func(c *CPU) {
+		c.r.sp = sp
+	}
+}
+
+// powerOnRegisters fills in the registers the CPU is created with
+func (c *CPU) powerOnRegisters() {
+	if c.powerOn == PowerOnRandom {
+		c.r.a = uint8(rand.Intn(0x100))
+		c.r.x = uint8(rand.Intn(0x100))
+		c.r.y = uint8(rand.Intn(0x100))
+		c.r.p = uint8(rand.Intn(0x100))
+	}
+
+	// The unused flag always reads as 1, and the break flag only exists on
+	// the stack
+	c.r.p = c.r.p&^Break | Unused
+}
@@ -0,0 +1,66 @@
+package goemu6502
+
+import "testing"
+
+func TestResetSequence(t *testing.T) {
+	bus := &recordingBus{}
+	bus.memory[0xFFFC], bus.memory[0xFFFD] = 0x00, 0x80
+
+	c := NewCPU(bus, WithVariant(CMOS65C02), WithCycleExact())
+	c.SetA(0x42)
+	c.SetFlag(Decimal, true)
+
+	c.Reset()
+	for cycle := 1; cycle <= 7; cycle++ {
+		before := len(bus.accesses)
+		c.Tick()
+		if len(bus.accesses) != before+1 {
+			t.Fatalf("cycle %d made %d bus accesses", cycle, len(bus.accesses)-before)
+		}
+	}
+	if !c.Complete() {
+		t.Error("reset took more than 7 cycles")
+	}
+
+	// The pushes read the stack, decrementing SP from $00 to $FD
+	want := []string{"0000 00 read", "0000 00 read", "0100 00 read", "01FF 00 read", "01FE 00 read",
+		"FFFC 00 read", "FFFD 80 read"}
+	for i, a := range bus.accesses {
+		if a.String() != want[i] {
+			t.Errorf("cycle %d: got %s, want %s", i+1, a, want[i])
+		}
+	}
+
+	if r := c.Registers(); r != NewRegisters(0x42, 0, 0, InterruptDisable|Unused, 0xFD, 0x8000) {
+		t.Errorf("registers after reset = %+v", r)
+	}
+}
+
+func TestResetTakesSevenCycles(t *testing.T) {
+	bus := &flatBus{}
+	bus.memory[0xFFFC], bus.memory[0xFFFD] = 0x00, 0x04
+	c := NewCPU(bus, WithPowerOnSP(0x42))
+
+	// The vector is loaded straight away, the cycles follow
+	c.Reset()
+	if c.PC() != 0x0400 || c.SP() != 0x3F {
+		t.Errorf("PC = $%04X, SP = $%02X after reset, want $0400 and $3F", c.PC(), c.SP())
+	}
+	if cycles := c.Step(); cycles != 7 {
+		t.Errorf("reset took %d cycles, want 7", cycles)
+	}
+}
+
+func TestPowerOnRandom(t *testing.T) {
+	// Some register is bound to differ in a few tries
+	for i := 0; i < 8; i++ {
+		c := NewCPU(&flatBus{}, WithPowerOn(PowerOnRandom))
+		if c.Flags()&Unused == 0 {
+			t.Fatal("unused flag clear at power on")
+		}
+		if c.A() != 0 || c.X() != 0 || c.Y() != 0 {
+			return
+		}
+	}
+	t.Error("registers not randomised")
+}
@@ -0,0 +1,294 @@
+package goemu6502
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// SingleStepTests (formerly ProcessorTests) describe one instruction per test
+// case: the machine state before, the state after, and every bus access made
+// in between. Upstream ships 10,000 cases per opcode; testdata/singlestep holds
+// a small local set so the runner works offline. Point -singlestep.dir at a
+// checkout of https://github.com/SingleStepTests/65x02 (the 6502 directory) to
+// run the full suite.
+var (
+	singleStepDir     = flag.String("singlestep.dir", "testdata/singlestep", "directory containing one <opcode>.json file per opcode")
+	singleStepCycles  = flag.Bool("singlestep.cycles", false, "also compare the per-cycle bus activity")
+	singleStepSummary = flag.String("singlestep.summary", "", "write a conformance summary of all 256 opcodes to this file")
+)
+
+type (
+	singleStepState struct {
+		PC  uint16      `json:"pc"`
+		S   uint8       `json:"s"`
+		A   uint8       `json:"a"`
+		X   uint8       `json:"x"`
+		Y   uint8       `json:"y"`
+		P   uint8       `json:"p"`
+		RAM [][2]uint16 `json:"ram"`
+	}
+
+	// singleStepCycle is an [address, value, "read"|"write"] triple
+	singleStepCycle [3]interface{}
+
+	singleStepCase struct {
+		Name    string            `json:"name"`
+		Initial singleStepState   `json:"initial"`
+		Final   singleStepState   `json:"final"`
+		Cycles  []singleStepCycle `json:"cycles"`
+	}
+
+	// busAccess is a single bus access recorded by recordingBus
+	busAccess struct {
+		addr  uint16
+		value uint8
+		write bool
+	}
+
+	// recordingBus is 64K of RAM that remembers every access made to it
+	recordingBus struct {
+		flatBus
+		accesses []busAccess
+	}
+
+	// opcodeResult is the outcome of running every case for one opcode
+	opcodeResult struct {
+		cases  int
+		failed int
+		first  string // description of the first failure
+	}
+)
+
+func (b *recordingBus) Read(addr uint16) uint8 {
+	value := b.memory[addr]
+	b.accesses = append(b.accesses, busAccess{addr, value, false})
+	return value
+}
+
+func (b *recordingBus) Write(addr uint16, value uint8) {
+	b.memory[addr] = value
+	b.accesses = append(b.accesses, busAccess{addr, value, true})
+}
+
+func (a busAccess) String() string {
+	kind := "read"
+	if a.write {
+		kind = "write"
+	}
+	return fmt.Sprintf("%04X %02X %s", a.addr, a.value, kind)
+}
+
+// expected converts the JSON cycle list into bus accesses
+func (tc *singleStepCase) expected() ([]busAccess, error) {
+	accesses := make([]busAccess, 0, len(tc.Cycles))
+	for _, cycle := range tc.Cycles {
+		addr, ok1 := cycle[0].(float64)
+		value, ok2 := cycle[1].(float64)
+		kind, ok3 := cycle[2].(string)
+		if !ok1 || !ok2 || !ok3 {
+			return nil, fmt.Errorf("malformed cycle %v", cycle)
+		}
+		accesses = append(accesses, busAccess{uint16(addr), uint8(value), kind == "write"})
+	}
+	return accesses, nil
+}
+
+// run executes a single test case and returns a description of the first
+// mismatch, or "" if the CPU ended up in the expected state.
+func (tc *singleStepCase) run(compareCycles bool) (mismatch string) {
+	bus := &recordingBus{}
+	for _, cell := range tc.Initial.RAM {
+		bus.memory[cell[0]] = uint8(cell[1])
+	}
+
+	// Bus activity only lines up cycle by cycle in cycle-exact mode
+	var options []Option
+	if compareCycles {
+		options = append(options, WithCycleExact())
+	}
+
+	c := NewCPU(bus, options...)
+	initial := tc.Initial
+	c.SetRegisters(NewRegisters(initial.A, initial.X, initial.Y, initial.P, initial.S, initial.PC))
+
+	defer func() {
+		if r := recover(); r != nil {
+			mismatch = fmt.Sprintf("panic: %v", r)
+		}
+	}()
+
+	c.Step()
+
+	want := tc.Final
+	r := c.Registers()
+	got := singleStepState{PC: r.PC(), S: r.SP(), A: r.A(), X: r.X(), Y: r.Y(), P: r.P()}
+	if got.PC != want.PC || got.S != want.S || got.A != want.A || got.X != want.X || got.Y != want.Y || got.P != want.P {
+		return fmt.Sprintf("registers: got PC=%04X S=%02X A=%02X X=%02X Y=%02X P=%02X, want PC=%04X S=%02X A=%02X X=%02X Y=%02X P=%02X",
+			got.PC, got.S, got.A, got.X, got.Y, got.P, want.PC, want.S, want.A, want.X, want.Y, want.P)
+	}
+
+	for _, cell := range want.RAM {
+		if bus.memory[cell[0]] != uint8(cell[1]) {
+			return fmt.Sprintf("memory $%04X: got %02X, want %02X", cell[0], bus.memory[cell[0]], cell[1])
+		}
+	}
+
+	if compareCycles {
+		expected, err := tc.expected()
+		if err != nil {
+			return err.Error()
+		}
+		for i := 0; i < len(expected) || i < len(bus.accesses); i++ {
+			var got, want string = "nothing", "nothing"
+			if i < len(bus.accesses) {
+				got = bus.accesses[i].String()
+			}
+			if i < len(expected) {
+				want = expected[i].String()
+			}
+			if got != want {
+				return fmt.Sprintf("cycle %d: got %s, want %s", i+1, got, want)
+			}
+		}
+	}
+
+	return ""
+}
+
+// runSingleStepFile runs every case in one opcode's fixture file
+func runSingleStepFile(path string, compareCycles bool) (opcodeResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return opcodeResult{}, err
+	}
+
+	var cases []singleStepCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		return opcodeResult{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	result := opcodeResult{cases: len(cases)}
+	for i := range cases {
+		if mismatch := cases[i].run(compareCycles); mismatch != "" {
+			if result.failed == 0 {
+				result.first = fmt.Sprintf("%q: %s", cases[i].Name, mismatch)
+			}
+			result.failed++
+		}
+	}
+
+	return result, nil
+}
+
+// conformanceMatrix renders a 16x16 grid of opcodes, row = high nibble and
+// column = low nibble. Each cell is "ok", "XX" for a failing opcode or "." if
+// there was no fixture for it.
+func conformanceMatrix(results map[uint8]opcodeResult) string {
+	var sb strings.Builder
+
+	sb.WriteString("   ")
+	for lo := 0; lo < 16; lo++ {
+		fmt.Fprintf(&sb, "  %X", lo)
+	}
+	sb.WriteString("\n")
+
+	for hi := 0; hi < 16; hi++ {
+		fmt.Fprintf(&sb, "%X_ ", hi)
+		for lo := 0; lo < 16; lo++ {
+			result, ok := results[uint8(hi<<4|lo)]
+			switch {
+			case !ok:
+				sb.WriteString("  .")
+			case result.failed == 0:
+				sb.WriteString(" ok")
+			default:
+				sb.WriteString(" XX")
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// conformanceSummary renders a markdown summary listing every opcode
+func conformanceSummary(results map[uint8]opcodeResult, compareCycles bool) string {
+	var sb strings.Builder
+	var passed, failed int
+
+	for op := 0; op < 256; op++ {
+		if result, ok := results[uint8(op)]; ok {
+			if result.failed == 0 {
+				passed++
+			} else {
+				failed++
+			}
+		}
+	}
+
+	sb.WriteString("# SingleStepTests conformance\n\n")
+	sb.WriteString("Generated by `go test -run TestSingleStep -singlestep.summary <file>`.\n\n")
+	fmt.Fprintf(&sb, "Bus activity compared: %v\n\n", compareCycles)
+	fmt.Fprintf(&sb, "%d conformant, %d failing, %d without fixtures.\n\n", passed, failed, 256-passed-failed)
+	sb.WriteString("```\n")
+	sb.WriteString(conformanceMatrix(results))
+	sb.WriteString("```\n\n")
+	sb.WriteString("| Opcode | Instruction | Mode | Result |\n")
+	sb.WriteString("|--------|-------------|------|--------|\n")
+
+	for op := 0; op < 256; op++ {
+		info := Instructions[uint8(op)]
+		status := "no fixture"
+		if result, ok := results[uint8(op)]; ok {
+			if result.failed == 0 {
+				status = fmt.Sprintf("pass (%d cases)", result.cases)
+			} else {
+				status = fmt.Sprintf("FAIL (%d/%d cases)", result.failed, result.cases)
+			}
+		}
+		fmt.Fprintf(&sb, "| $%02X | %s | %s | %s |\n",
+			op, InstructionNames[info.Instruction], AddressingModeNames[info.Mode], status)
+	}
+
+	return sb.String()
+}
+
+func TestSingleStep(t *testing.T) {
+	results := map[uint8]opcodeResult{}
+
+	for op := 0; op < 256; op++ {
+		path := filepath.Join(*singleStepDir, fmt.Sprintf("%02x.json", op))
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+
+		t.Run(fmt.Sprintf("%02X", op), func(t *testing.T) {
+			result, err := runSingleStepFile(path, *singleStepCycles)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			results[uint8(op)] = result
+			if result.failed > 0 {
+				t.Errorf("%d of %d cases failed, first: %s", result.failed, result.cases, result.first)
+			}
+		})
+	}
+
+	if len(results) == 0 {
+		t.Skipf("no fixtures found in %s", *singleStepDir)
+	}
+
+	t.Logf("opcode conformance:\n%s", conformanceMatrix(results))
+
+	if *singleStepSummary != "" {
+		if err := os.WriteFile(*singleStepSummary, []byte(conformanceSummary(results, *singleStepCycles)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package goemu6502
+
+// SetSO drives the SO (set overflow) input of the 6502 and 65C02. The pin is
+// active low: asserting it pulls it low, and that edge sets the overflow flag
+// as the next cycle starts. Holding it asserted doesn't set the flag again.
+// Disk drives such as the Commodore 1541 use it to signal that a byte is
+// ready to a `BVC *` loop. It does nothing on the 6510 and 2A03, which lack
+// the pin. It is safe to call from a bus access.
+func (c *CPU) SetSO(asserted bool) {
+	c.pins.mutex.Lock()
+	defer c.pins.mutex.Unlock()
+
+	if c.variant == MOS6510 || c.variant == Ricoh2A03 {
+		return
+	}
+
+	if asserted && !c.pins.so {
+		c.pins.soPending = true
+	}
+	c.pins.so = asserted
+	c.pins.update()
+}
+
+// SO reports whether the SO line is asserted
+func (c *CPU) SO() bool {
+	c.pins.mutex.Lock()
+	defer c.pins.mutex.Unlock()
+
+	return c.pins.so
+}
+
+// setOverflow sets the overflow flag if SO has been asserted since the last
+// cycle
+func (c *CPU) setOverflow() {
+	if c.pins.active.Load()&activeSO == 0 {
+		return
+	}
+
+	c.pins.mutex.Lock()
+	defer c.pins.mutex.Unlock()
+
+	if c.pins.soPending {
+		c.pins.soPending = false
+		c.pins.update()
+		c.setFlag(Overflow, true)
+	}
+}
@@ -0,0 +1,40 @@
+package goemu6502
+
+import "testing"
+
+func TestSOEndsBVCLoop(t *testing.T) {
+	bothModes(t, func(t *testing.T, options ...Option) {
+		// BVC *; CLV; BVC *
+		bus := &flatBus{}
+		copy(bus.memory[0x0400:], []uint8{0x50, 0xFE, 0xB8, 0x50, 0xFE})
+		c := NewCPU(bus, options...)
+		c.SetPC(0x0400)
+
+		c.Run(20)
+		if c.PC() != 0x0400 {
+			t.Fatalf("PC = $%04X before SO, want $0400", c.PC())
+		}
+
+		// The byte is ready
+		c.SetSO(true)
+		c.Run(20)
+		if c.PC() != 0x0403 {
+			t.Fatalf("PC = $%04X after SO, want $0403", c.PC())
+		}
+
+		// Holding SO asserted doesn't set the flag again
+		c.Run(20)
+		if c.PC() != 0x0403 || c.Flag(Overflow) {
+			t.Errorf("PC = $%04X with SO held, want $0403 and V clear", c.PC())
+		}
+	})
+}
+
+func TestSOMissingOn6510(t *testing.T) {
+	c := NewCPU(&flatBus{}, WithVariant(MOS6510))
+	c.SetSO(true)
+	c.Tick()
+	if c.Flag(Overflow) {
+		t.Error("SO set the overflow flag on the 6510")
+	}
+}
@@ -0,0 +1,381 @@
+package goemu6502
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// State is a complete snapshot of a CPU, including the internal registers of
+// an instruction in progress, so that restoring it mid-instruction resumes
+// exactly where the snapshot was taken. The bus and whatever is attached to it
+// are not part of the state.
+type State struct {
+	Version uint8 `json:"version"`
+
+	// The CPU the state was taken from, which it can only be restored to
+	Variant    Variant `json:"variant"`
+	CycleExact bool    `json:"cycle_exact"`
+
+	// Registers
+	A  uint8  `json:"a"`
+	X  uint8  `json:"x"`
+	Y  uint8  `json:"y"`
+	P  uint8  `json:"p"`
+	SP uint8  `json:"sp"`
+	PC uint16 `json:"pc"`
+
+	// Internal registers
+	Fetched      uint8          `json:"fetched"`
+	Temp         uint16         `json:"temp"`
+	AddrAbsolute uint16         `json:"addr_absolute"`
+	AddrRelative uint16         `json:"addr_relative"`
+	AddrMode     AddressingMode `json:"addr_mode"`
+	Opcode       uint8          `json:"opcode"`
+
+	// Internal status
+	Cycles        uint8  `json:"cycles"`                // Cycles left in the current instruction
+	Instruction   string `json:"instruction,omitempty"` // Disassembly of the current instruction, not saved in binary or restored
+	InstructionPC uint16 `json:"instruction_pc"`        // Address of the current instruction
+	Halted        bool   `json:"halted"`                // Locked up by a JAM opcode
+	Waiting       bool   `json:"waiting"`               // Waiting for an interrupt after WAI
+
+	// The 6510 I/O port, zero on other variants
+	PortDirection uint8 `json:"port_direction"`
+	PortData      uint8 `json:"port_data"`
+
+	// Progress through the current instruction in cycle-exact mode, zero
+	// otherwise
+	Cycle        uint8 `json:"cycle"`
+	Operated     bool  `json:"operated"`
+	Interrupting bool  `json:"interrupting"`
+
+	// The interrupt inputs, and what the current instruction has made of them
+	PollIRQ           bool   `json:"poll_irq"`           // An IRQ waits for the instruction to finish
+	PollNMI           bool   `json:"poll_nmi"`           // An NMI waits for the instruction to finish
+	Vector            uint16 `json:"vector"`             // Vector of the interrupt or BRK in progress
+	BranchDelay       bool   `json:"branch_delay"`       // A taken branch skips its last poll
+	InterruptDisabled bool   `json:"interrupt_disabled"` // Interrupt disable flag before the instruction
+	IRQ               uint64 `json:"irq"`                // IRQ sources asserting the line, one bit each
+	NMI               bool   `json:"nmi"`                // Level of the NMI line
+	NMIPending        bool   `json:"nmi_pending"`        // NMI edge latch
+	SO                bool   `json:"so"`                 // Level of the SO line, true when pulled low
+	SOPending         bool   `json:"so_pending"`         // SO edge latch
+
+	// Bus mastering
+	RDYLow       bool   `json:"rdy_low"`       // RDY pulled low
+	DMA          uint32 `json:"dma"`           // Cycles still to be taken by DMA
+	StolenCycles uint64 `json:"stolen_cycles"` // Cycles the CPU was held by RDY or DMA
+
+	// Counters
+	TotalCycles        uint64 `json:"total_cycles"`        // Cycles run since the CPU was created
+	TotalInstructions  uint64 `json:"total_instructions"`  // Instructions started since the CPU was created
+	InterruptsServiced uint64 `json:"interrupts_serviced"` // IRQs and NMIs taken since the CPU was created
+}
+
+const (
+	// stateMagic starts every binary encoded State
+	stateMagic = "6502"
+
+	// StateVersion is the version of State written by this package
+	StateVersion = 1
+)
+
+var (
+	// ErrInvalidState is returned when decoding something that isn't a State
+	ErrInvalidState = errors.New("goemu6502: invalid state")
+)
+
+// SaveState returns a snapshot of the CPU
+func (c *CPU) SaveState() State {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := State{
+		Version:    StateVersion,
+		Variant:    c.variant,
+		CycleExact: c.cycleExact,
+
+		A:  c.r.a,
+		X:  c.r.x,
+		Y:  c.r.y,
+		P:  c.r.p,
+		SP: c.r.sp,
+		PC: c.r.pc,
+
+		Fetched:      c.i.fetched,
+		Temp:         c.i.temp,
+		AddrAbsolute: c.i.addr_absolute,
+		AddrRelative: c.i.addr_relative,
+		AddrMode:     c.i.addr_mode,
+		Opcode:       c.i.opcode,
+
+		Cycles:      c.status.Cycles,
+		Instruction: c.currentInstructionString(),
+
+		Halted:  c.status.halted,
+		Waiting: c.status.waiting,
+
+		Cycle:        c.i.cycle,
+		Operated:     c.i.operated,
+		Interrupting: c.i.interrupting,
+		PollIRQ:      c.status.pollIRQ,
+
+		PollNMI:           c.status.pollNMI,
+		Vector:            c.i.vector,
+		BranchDelay:       c.i.branchDelay,
+		InterruptDisabled: c.i.disabled,
+
+		StolenCycles: c.status.stolenCycles,
+
+		InstructionPC: c.status.instructionPC,
+
+		TotalCycles:        c.status.totalCycles,
+		TotalInstructions:  c.status.instructions,
+		InterruptsServiced: c.status.interrupts,
+	}
+
+	c.pins.mutex.Lock()
+	s.IRQ, s.NMI, s.NMIPending = c.pins.irq, c.pins.nmi, c.pins.nmiPending
+	s.RDYLow, s.DMA = c.pins.notReady, uint32(c.pins.dma)
+	s.SO, s.SOPending = c.pins.so, c.pins.soPending
+	c.pins.mutex.Unlock()
+
+	if c.port != nil {
+		s.PortDirection = c.port.direction
+		s.PortData = c.port.data
+	}
+
+	return s
+}
+
+// LoadState restores a snapshot taken with SaveState. The CPU must be the
+// same variant as the one the snapshot was taken from, and in the same mode.
+func (c *CPU) LoadState(s State) error {
+	if s.Version != StateVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidState, s.Version)
+	}
+	if s.Variant != c.variant {
+		return fmt.Errorf("%w: saved from a %s, not a %s", ErrInvalidState, s.Variant, c.variant)
+	}
+	if s.CycleExact != c.cycleExact {
+		return fmt.Errorf("%w: cycle-exact mode does not match", ErrInvalidState)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.r = Registers{a: s.A, x: s.X, y: s.Y, p: s.P, sp: s.SP, pc: s.PC}
+
+	c.i = InternalRegisters{
+		fetched:       s.Fetched,
+		temp:          s.Temp,
+		addr_absolute: s.AddrAbsolute,
+		addr_relative: s.AddrRelative,
+		addr_mode:     s.AddrMode,
+		opcode:        s.Opcode,
+		cycle:         s.Cycle,
+		operated:      s.Operated,
+		interrupting:  s.Interrupting,
+		vector:        s.Vector,
+		branchDelay:   s.BranchDelay,
+		disabled:      s.InterruptDisabled,
+	}
+
+	c.status = InternalStatus{
+		Cycles:             s.Cycles,
+		currentInstruction: c.instructions[s.Opcode],
+		instructionPC:      s.InstructionPC,
+		halted:             s.Halted,
+		waiting:            s.Waiting,
+		pollIRQ:            s.PollIRQ,
+		pollNMI:            s.PollNMI,
+		stolenCycles:       s.StolenCycles,
+		totalCycles:        s.TotalCycles,
+		instructions:       s.TotalInstructions,
+		interrupts:         s.InterruptsServiced,
+	}
+
+	c.pins.mutex.Lock()
+	c.pins.irq, c.pins.nmi, c.pins.nmiPending = s.IRQ, s.NMI, s.NMIPending
+	c.pins.notReady, c.pins.dma = s.RDYLow, int(s.DMA)
+	c.pins.so, c.pins.soPending = s.SO, s.SOPending
+	c.pins.update()
+	c.pins.mutex.Unlock()
+
+	if c.port != nil {
+		c.port.restore(s.PortDirection, s.PortData)
+	}
+
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler by encoding a snapshot of
+// the CPU.
+func (c *CPU) MarshalBinary() ([]byte, error) {
+	return c.SaveState().MarshalBinary()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler by restoring a
+// snapshot written by MarshalBinary.
+func (c *CPU) UnmarshalBinary(data []byte) error {
+	var s State
+	if err := s.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	return c.LoadState(s)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The encoding is the
+// magic string "6502", a version byte and then every field but Instruction
+// in declaration order, little endian.
+func (s State) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, 96)
+	buf = append(buf, stateMagic...)
+	buf = append(buf, StateVersion)
+	buf = append(buf, uint8(s.Variant), boolByte(s.CycleExact))
+
+	buf = append(buf, s.A, s.X, s.Y, s.P, s.SP)
+	buf = binary.LittleEndian.AppendUint16(buf, s.PC)
+
+	buf = append(buf, s.Fetched)
+	buf = binary.LittleEndian.AppendUint16(buf, s.Temp)
+	buf = binary.LittleEndian.AppendUint16(buf, s.AddrAbsolute)
+	buf = binary.LittleEndian.AppendUint16(buf, s.AddrRelative)
+	buf = append(buf, uint8(s.AddrMode), s.Opcode)
+
+	buf = append(buf, s.Cycles)
+	buf = binary.LittleEndian.AppendUint16(buf, s.InstructionPC)
+	buf = append(buf, boolByte(s.Halted), boolByte(s.Waiting))
+
+	buf = append(buf, s.PortDirection, s.PortData)
+
+	buf = append(buf, s.Cycle, boolByte(s.Operated), boolByte(s.Interrupting))
+
+	buf = append(buf, boolByte(s.PollIRQ), boolByte(s.PollNMI))
+	buf = binary.LittleEndian.AppendUint16(buf, s.Vector)
+	buf = append(buf, boolByte(s.BranchDelay), boolByte(s.InterruptDisabled))
+	buf = binary.LittleEndian.AppendUint64(buf, s.IRQ)
+	buf = append(buf, boolByte(s.NMI), boolByte(s.NMIPending))
+	buf = append(buf, boolByte(s.SO), boolByte(s.SOPending))
+
+	buf = append(buf, boolByte(s.RDYLow))
+	buf = binary.LittleEndian.AppendUint32(buf, s.DMA)
+	buf = binary.LittleEndian.AppendUint64(buf, s.StolenCycles)
+
+	buf = binary.LittleEndian.AppendUint64(buf, s.TotalCycles)
+	buf = binary.LittleEndian.AppendUint64(buf, s.TotalInstructions)
+	buf = binary.LittleEndian.AppendUint64(buf, s.InterruptsServiced)
+
+	return buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler
+func (s *State) UnmarshalBinary(data []byte) error {
+	d := stateDecoder{data: data}
+
+	if string(d.bytes(len(stateMagic))) != stateMagic {
+		return fmt.Errorf("%w: bad magic", ErrInvalidState)
+	}
+
+	var out State
+	out.Version = d.byte()
+	if d.err == nil && out.Version != StateVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidState, out.Version)
+	}
+	out.Variant, out.CycleExact = Variant(d.byte()), d.byte() != 0
+
+	out.A, out.X, out.Y, out.P, out.SP = d.byte(), d.byte(), d.byte(), d.byte(), d.byte()
+	out.PC = d.word()
+
+	out.Fetched = d.byte()
+	out.Temp = d.word()
+	out.AddrAbsolute = d.word()
+	out.AddrRelative = d.word()
+	out.AddrMode = AddressingMode(d.byte())
+	out.Opcode = d.byte()
+
+	out.Cycles = d.byte()
+	out.InstructionPC = d.word()
+	out.Halted, out.Waiting = d.byte() != 0, d.byte() != 0
+
+	out.PortDirection, out.PortData = d.byte(), d.byte()
+
+	out.Cycle = d.byte()
+	out.Operated, out.Interrupting = d.byte() != 0, d.byte() != 0
+
+	out.PollIRQ, out.PollNMI = d.byte() != 0, d.byte() != 0
+	out.Vector = d.word()
+	out.BranchDelay, out.InterruptDisabled = d.byte() != 0, d.byte() != 0
+	out.IRQ = d.quad()
+	out.NMI, out.NMIPending = d.byte() != 0, d.byte() != 0
+	out.SO, out.SOPending = d.byte() != 0, d.byte() != 0
+
+	out.RDYLow = d.byte() != 0
+	out.DMA = d.long()
+	out.StolenCycles = d.quad()
+
+	out.TotalCycles, out.TotalInstructions, out.InterruptsServiced = d.quad(), d.quad(), d.quad()
+
+	if d.err != nil {
+		return d.err
+	}
+
+	*s = out
+	return nil
+}
+
+// stateDecoder reads fields from a binary State, remembering the first error
+type stateDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *stateDecoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.data) < n {
+		d.err = fmt.Errorf("%w: truncated", ErrInvalidState)
+		return nil
+	}
+
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *stateDecoder) byte() uint8 {
+	if b := d.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *stateDecoder) word() uint16 {
+	if b := d.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *stateDecoder) long() uint32 {
+	if b := d.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *stateDecoder) quad() uint64 {
+	if b := d.bytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func boolByte(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,196 @@
+package goemu6502
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// stateTestProgram counts $10 up forever:
+//
+//	0400: INC $10
+//	0402: LDA $10
+//	0404: JMP $0400
+var stateTestProgram = []uint8{0xE6, 0x10, 0xA5, 0x10, 0x4C, 0x00, 0x04}
+
+// trace ticks the CPU and records PC, A and $10 after every cycle
+func trace(c *CPU, bus *flatBus, cycles int) [][3]int {
+	var out [][3]int
+	for i := 0; i < cycles; i++ {
+		c.Tick()
+		out = append(out, [3]int{int(c.PC()), int(c.A()), int(bus.memory[0x10])})
+	}
+	return out
+}
+
+func TestStateRestoresMidInstruction(t *testing.T) {
+	c, bus := newTestCPU(stateTestProgram...)
+	c.Run(20)
+
+	// Stop one cycle into INC, which takes 5
+	for c.PC() != 0x0400 {
+		c.Step()
+	}
+	c.Tick()
+	if c.Complete() {
+		t.Fatal("expected to be mid-instruction")
+	}
+
+	data, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory := *bus
+	want := trace(c, bus, 50)
+
+	restored := NewCPU(&memory)
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	got := trace(restored, &memory, 50)
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("cycle %d after restore: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestStateRestoresMidInstructionCycleExact(t *testing.T) {
+	bus := &flatBus{}
+	copy(bus.memory[0x0400:], stateTestProgram)
+	c := NewCPU(bus, WithCycleExact())
+	c.SetPC(0x0400)
+	c.Run(20)
+
+	// Stop between the read and the writes of INC
+	for c.PC() != 0x0400 {
+		c.Step()
+	}
+	c.Tick()
+	c.Tick()
+	c.Tick()
+
+	data, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory := *bus
+	want := trace(c, bus, 50)
+
+	restored := NewCPU(&memory, WithCycleExact())
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	got := trace(restored, &memory, 50)
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("cycle %d after restore: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestStateRestoresInterrupts(t *testing.T) {
+	c, bus := newTestCPU(stateTestProgram...)
+	c.SetIRQ(c.NewIRQSource(), true)
+	c.SetNMI(true)
+	c.Tick()
+
+	data, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewCPU(bus)
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := restored.SaveState(), c.SaveState(); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if !restored.IRQ() || !restored.NMI() {
+		t.Error("interrupt lines not restored")
+	}
+}
+
+func TestStateJSONRoundTrip(t *testing.T) {
+	c, _ := newTestCPU(stateTestProgram...)
+	c.Tick()
+
+	want := c.SaveState()
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got State
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestStateRejectsBadData(t *testing.T) {
+	c, _ := newTestCPU(stateTestProgram...)
+	data, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, bad := range map[string][]byte{
+		"empty":     nil,
+		"magic":     append([]byte("6503"), data[4:]...),
+		"version":   append(append([]byte("6502"), StateVersion+1), data[5:]...),
+		"truncated": data[:len(data)-1],
+	} {
+		if err := c.UnmarshalBinary(bad); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("%s: got error %v, want ErrInvalidState", name, err)
+		}
+	}
+}
+
+func TestStateRejectsOtherCPU(t *testing.T) {
+	c, bus := newTestCPU(stateTestProgram...)
+	data, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, other := range map[string]*CPU{
+		"variant":     NewCPU(bus, WithVariant(CMOS65C02)),
+		"cycle-exact": NewCPU(bus, WithCycleExact()),
+	} {
+		if err := other.UnmarshalBinary(data); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("%s: got error %v, want ErrInvalidState", name, err)
+		}
+		if err := other.LoadState(c.SaveState()); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("%s: LoadState got error %v, want ErrInvalidState", name, err)
+		}
+	}
+}
+
+func TestStateBinaryOmitsInstruction(t *testing.T) {
+	c, _ := newTestCPU(stateTestProgram...)
+	c.Tick()
+
+	want := c.SaveState()
+	if want.Instruction == "" {
+		t.Fatal("no instruction in progress")
+	}
+	data, err := want.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got State
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	want.Instruction = ""
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,260 @@
+package goemu6502
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// --- Tracing ---
+// A trace has a line for every instruction, showing the machine as it was
+// just before the instruction ran. Lines follow the logs of well known
+// emulators, so that traces can be diffed against theirs.
+
+type (
+	// TraceFormat selects the emulator whose trace lines a Tracer copies
+	TraceFormat uint8
+
+	// Tracer writes a trace line for every instruction the CPU runs. It
+	// starts with CPU.Trace and stops when it is closed.
+	Tracer struct {
+		cpu    *CPU
+		w      io.Writer
+		format TraceFormat
+		hook   HookID
+		err    error // The first write that failed
+	}
+)
+
+const (
+	// TraceNestest is the layout of nestest.log, as written by Nintendulator:
+	//
+	//	C72F  B0 04     BCS $C735                       A:00 X:00 Y:00 P:27 SP:FB PPU:  0, 93 CYC:31
+	//
+	// Memory operands are followed by the effective address and the value
+	// there, e.g. "LDA ($80),Y = 0200 @ 0234 = 5A", and undocumented opcodes
+	// are marked with a *. The PPU position is worked out from the cycle
+	// count, three dots per cycle, as it is on the NES.
+	TraceNestest TraceFormat = iota
+
+	// TraceMesen is the layout of Mesen's trace logger, without the PPU
+	// columns:
+	//
+	//	C72F  B0 04     BCS $C735                       A:00 X:00 Y:00 S:FB P:nv-bdIZC Cyc:31
+	//
+	// Memory operands are followed by the effective address in brackets and
+	// the value there, e.g. "LDA ($80),Y [$0234] = $5A".
+	TraceMesen
+
+	// TraceVICE is the layout of the chis command of VICE's monitor:
+	//
+	//	.C:c72f  B0 04       BCS $C735      - A:00 X:00 Y:00 SP:fb ..-..IZC           31
+	TraceVICE
+)
+
+// TraceFormatNames is a map of trace format names
+var TraceFormatNames = map[TraceFormat]string{
+	TraceNestest: "nestest",
+	TraceMesen:   "Mesen",
+	TraceVICE:    "VICE",
+}
+
+func (f TraceFormat) String() string {
+	if name, ok := TraceFormatNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("TraceFormat(%d)", uint8(f))
+}
+
+// nestestNames are the names nestest.log gives undocumented instructions
+// where they differ from ours
+var nestestNames = map[Instruction]string{
+	isc: "ISB",
+}
+
+// Trace writes a trace line to w before every instruction the CPU runs,
+// until the Tracer is closed. Memory is peeked for the operands, so the bus
+// should implement Peeker.
+func (c *CPU) Trace(w io.Writer, format TraceFormat) *Tracer {
+	t := &Tracer{cpu: c, w: w, format: format}
+	t.hook = c.AddHooks(Hooks{BeforeInstruction: t.beforeInstruction})
+	return t
+}
+
+// Close stops the trace, and returns the first error writing it
+func (t *Tracer) Close() error {
+	t.cpu.RemoveHooks(t.hook)
+	return t.err
+}
+
+// beforeInstruction writes the line for the instruction that was just
+// fetched. The CPU is locked and has taken the opcode fetch cycle, but
+// nothing else.
+func (t *Tracer) beforeInstruction(pc uint16, opcode uint8) {
+	if t.err != nil {
+		return
+	}
+
+	c := t.cpu
+	r := c.r
+	r.pc = pc
+	_, t.err = io.WriteString(t.w, c.traceLine(t.format, r, c.status.totalCycles-1)+"\n")
+}
+
+// TraceLine returns the trace line for the next instruction, as Trace
+// would write it
+func (c *CPU) TraceLine(format TraceFormat) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.traceLine(format, c.r, c.status.totalCycles)
+}
+
+// traceLine formats the instruction at r.pc, about to run with the registers
+// r once cycles cycles have passed. Without a Peeker it can only show the
+// registers.
+func (c *CPU) traceLine(format TraceFormat, r Registers, cycles uint64) string {
+	code, instruction, documented := "", unpeekable, true
+	if c.peeker != nil {
+		info := c.instructions[c.peekByte(r.pc)]
+
+		var sb strings.Builder
+		for i := 0; i < info.Length(); i++ {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			fmt.Fprintf(&sb, "%02X", c.peekByte(r.pc+uint16(i)))
+		}
+		code, instruction, documented = sb.String(), c.traceInstruction(format, info, r), info.Documented()
+	}
+
+	switch format {
+	case TraceMesen:
+		return fmt.Sprintf("%04X  %-9s %-31s A:%02X X:%02X Y:%02X S:%02X P:%s Cyc:%d",
+			r.pc, code, instruction, r.a, r.x, r.y, r.sp, Flags(r.p), cycles)
+
+	case TraceVICE:
+		flags := []byte(Flags(r.p).String())
+		for i, flag := range flags {
+			if flag >= 'a' && flag <= 'z' {
+				flags[i] = '.'
+			}
+		}
+		return fmt.Sprintf(".C:%04x  %-10s  %-13s  - A:%02X X:%02X Y:%02X SP:%02x %s %12d",
+			r.pc, code, instruction, r.a, r.x, r.y, r.sp, flags, cycles)
+
+	default:
+		marker := ' '
+		if !documented {
+			marker = '*'
+		}
+		dots := cycles * 3
+		return fmt.Sprintf("%04X  %-8s %c%-32sA:%02X X:%02X Y:%02X P:%02X SP:%02X PPU:%3d,%3d CYC:%d",
+			r.pc, code, marker, instruction, r.a, r.x, r.y, r.p, r.sp, dots/341%262, dots%341, cycles)
+	}
+}
+
+// traceInstruction disassembles an instruction in upper case, adding what it
+// will access in the style of the format
+func (c *CPU) traceInstruction(format TraceFormat, info InstructionInfo, r Registers) string {
+	name := strings.ToUpper(info.Name())
+	if nestestName, ok := nestestNames[info.Instruction]; ok && format == TraceNestest {
+		name = nestestName
+	}
+
+	operand := c.getOperandString(info.Mode, r.pc+1)
+	if info.Mode == Accumulator {
+		operand = "A"
+	}
+	if operand == "" {
+		return name
+	}
+	if format == TraceVICE {
+		return name + " " + operand
+	}
+
+	addr, ok := c.effectiveAddress(info.Mode, r)
+	if !ok || info.Instruction == jmp && info.Mode == Absolute || info.Instruction == jsr {
+		return name + " " + operand
+	}
+	value := c.peekByte(addr)
+
+	// JMP () goes where the pointer says, and reads nothing there
+	jump := info.Mode == Indirect || info.Mode == AbsoluteIndexedIndirect
+
+	if format == TraceMesen {
+		switch {
+		case jump:
+			return fmt.Sprintf("%s %s [$%04X]", name, operand, addr)
+		case info.Mode == ZeroPage || info.Mode == Absolute:
+			return fmt.Sprintf("%s %s = $%02X", name, operand, value)
+		default:
+			return fmt.Sprintf("%s %s [$%04X] = $%02X", name, operand, addr, value)
+		}
+	}
+
+	operandByte := c.peekByte(r.pc + 1)
+	switch info.Mode {
+	case ZeroPage, Absolute:
+		return fmt.Sprintf("%s %s = %02X", name, operand, value)
+	case ZeroPageX, ZeroPageY:
+		return fmt.Sprintf("%s %s @ %02X = %02X", name, operand, addr, value)
+	case AbsoluteX, AbsoluteY:
+		return fmt.Sprintf("%s %s @ %04X = %02X", name, operand, addr, value)
+	case IndexedIndirect:
+		return fmt.Sprintf("%s %s @ %02X = %04X = %02X", name, operand, operandByte+r.x, addr, value)
+	case IndirectIndexed:
+		return fmt.Sprintf("%s %s = %04X @ %04X = %02X", name, operand, addr-uint16(r.y), addr, value)
+	case ZeroPageIndirect:
+		return fmt.Sprintf("%s %s = %04X = %02X", name, operand, addr, value)
+	default:
+		return fmt.Sprintf("%s %s = %04X", name, operand, addr)
+	}
+}
+
+// effectiveAddress works out the address that the instruction at r.pc will
+// access, peeking any pointer it goes through. It reports false for the
+// modes that don't access memory through an address, such as immediate.
+func (c *CPU) effectiveAddress(mode AddressingMode, r Registers) (uint16, bool) {
+	operand := r.pc + 1
+	low := c.peekByte(operand)
+	word := uint16(low) | uint16(c.peekByte(operand+1))<<8
+
+	// pointer reads a pointer in zero page, which wraps around
+	pointer := func(addr uint8) uint16 {
+		return uint16(c.peekByte(uint16(addr))) | uint16(c.peekByte(uint16(addr+1)))<<8
+	}
+
+	switch mode {
+	case ZeroPage:
+		return uint16(low), true
+	case ZeroPageX:
+		return uint16(low + r.x), true
+	case ZeroPageY:
+		return uint16(low + r.y), true
+	case Absolute:
+		return word, true
+	case AbsoluteX:
+		return word + uint16(r.x), true
+	case AbsoluteY:
+		return word + uint16(r.y), true
+	case IndexedIndirect:
+		return pointer(low + r.x), true
+	case IndirectIndexed:
+		return pointer(low) + uint16(r.y), true
+	case ZeroPageIndirect:
+		return pointer(low), true
+	case Indirect:
+		// The NMOS page boundary bug, which the 65C02 fixed
+		high := word + 1
+		if word&0x00FF == 0x00FF && !c.variant.cmos() {
+			high = word & 0xFF00
+		}
+		return uint16(c.peekByte(word)) | uint16(c.peekByte(high))<<8, true
+	case AbsoluteIndexedIndirect:
+		addr := word + uint16(r.x)
+		return uint16(c.peekByte(addr)) | uint16(c.peekByte(addr+1))<<8, true
+	default:
+		return 0, false
+	}
+}
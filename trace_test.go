@@ -0,0 +1,140 @@
+package goemu6502
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTraceNestestExcerpt(t *testing.T) {
+	want, err := os.ReadFile("testdata/nestest_excerpt.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, err := loadNestestLog("testdata/nestest_excerpt.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus := &flatBus{}
+	for _, line := range log {
+		copy(bus.memory[line.pc:], line.bytes)
+	}
+
+	// The log starts after the 7 cycles of reset
+	c := NewCPU(bus, WithVariant(Ricoh2A03))
+	c.Reset()
+	c.Step()
+	c.SetRegisters(NewRegisters(0, 0, 0, InterruptDisable|Unused, 0xFD, nestestStart))
+
+	var got strings.Builder
+	tracer := c.Trace(&got, TraceNestest)
+	for range log {
+		c.Step()
+	}
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	gotLines := strings.Split(got.String(), "\n")
+	for i, wantLine := range strings.Split(strings.TrimSpace(string(want)), "\n") {
+		if i >= len(gotLines) || gotLines[i] != wantLine {
+			t.Errorf("line %d:\n got %q\nwant %q", i+1, gotLines[min(i, len(gotLines)-1)], wantLine)
+		}
+	}
+
+	// Closing stops the trace
+	c.Step()
+	if lines := strings.Count(got.String(), "\n"); lines != len(log) {
+		t.Errorf("traced %d lines, want %d", lines, len(log))
+	}
+}
+
+func TestTraceLine(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		program []uint8
+		variant Variant
+		want    [3]string // nestest, Mesen and VICE
+	}{
+		{
+			name:    "indirect indexed",
+			program: []uint8{0xB1, 0x80},
+			want: [3]string{
+				"0400  B1 80     LDA ($80),Y = 0200 @ 0234 = 5A  A:00 X:04 Y:34 P:24 SP:FD PPU:  0,  0 CYC:0",
+				"0400  B1 80     LDA ($80),Y [$0234] = $5A       A:00 X:04 Y:34 S:FD P:nv-bdIzc Cyc:0",
+				".C:0400  B1 80       LDA ($80),Y    - A:00 X:04 Y:34 SP:fd ..-..I..            0",
+			},
+		},
+		{
+			name:    "indexed indirect",
+			program: []uint8{0xA1, 0x7C},
+			want: [3]string{
+				"0400  A1 7C     LDA ($7C,X) @ 80 = 0200 = 12    A:00 X:04 Y:34 P:24 SP:FD PPU:  0,  0 CYC:0",
+				"0400  A1 7C     LDA ($7C,X) [$0200] = $12       A:00 X:04 Y:34 S:FD P:nv-bdIzc Cyc:0",
+				".C:0400  A1 7C       LDA ($7C,X)    - A:00 X:04 Y:34 SP:fd ..-..I..            0",
+			},
+		},
+		{
+			name:    "zero page indexed",
+			program: []uint8{0x95, 0xFE},
+			want: [3]string{
+				"0400  95 FE     STA $FE,X @ 02 = 00             A:00 X:04 Y:34 P:24 SP:FD PPU:  0,  0 CYC:0",
+				"0400  95 FE     STA $FE,X [$0002] = $00         A:00 X:04 Y:34 S:FD P:nv-bdIzc Cyc:0",
+				".C:0400  95 FE       STA $FE,X      - A:00 X:04 Y:34 SP:fd ..-..I..            0",
+			},
+		},
+		{
+			name:    "indirect jump with the page bug",
+			program: []uint8{0x6C, 0xFF, 0x02},
+			want: [3]string{
+				"0400  6C FF 02  JMP ($02FF) = 1234              A:00 X:04 Y:34 P:24 SP:FD PPU:  0,  0 CYC:0",
+				"0400  6C FF 02  JMP ($02FF) [$1234]             A:00 X:04 Y:34 S:FD P:nv-bdIzc Cyc:0",
+				".C:0400  6C FF 02    JMP ($02FF)    - A:00 X:04 Y:34 SP:fd ..-..I..            0",
+			},
+		},
+		{
+			name:    "backward branch",
+			program: []uint8{0xD0, 0xFE},
+			want: [3]string{
+				"0400  D0 FE     BNE $0400                       A:00 X:04 Y:34 P:24 SP:FD PPU:  0,  0 CYC:0",
+				"0400  D0 FE     BNE $0400                       A:00 X:04 Y:34 S:FD P:nv-bdIzc Cyc:0",
+				".C:0400  D0 FE       BNE $0400      - A:00 X:04 Y:34 SP:fd ..-..I..            0",
+			},
+		},
+		{
+			name:    "undocumented",
+			program: []uint8{0xE7, 0x80},
+			want: [3]string{
+				"0400  E7 80    *ISB $80 = 00                    A:00 X:04 Y:34 P:24 SP:FD PPU:  0,  0 CYC:0",
+				"0400  E7 80     ISC $80 = $00                   A:00 X:04 Y:34 S:FD P:nv-bdIzc Cyc:0",
+				".C:0400  E7 80       ISC $80        - A:00 X:04 Y:34 SP:fd ..-..I..            0",
+			},
+		},
+		{
+			name:    "accumulator",
+			program: []uint8{0x4A},
+			want: [3]string{
+				"0400  4A        LSR A                           A:00 X:04 Y:34 P:24 SP:FD PPU:  0,  0 CYC:0",
+				"0400  4A        LSR A                           A:00 X:04 Y:34 S:FD P:nv-bdIzc Cyc:0",
+				".C:0400  4A          LSR A          - A:00 X:04 Y:34 SP:fd ..-..I..            0",
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := &flatBus{}
+			copy(bus.memory[0x0400:], tt.program)
+			bus.memory[0x0080], bus.memory[0x0081] = 0x00, 0x02
+			bus.memory[0x0234] = 0x5A
+			bus.memory[0x02FF], bus.memory[0x0200] = 0x34, 0x12
+
+			c := NewCPU(bus, WithVariant(tt.variant))
+			c.SetRegisters(NewRegisters(0x00, 0x04, 0x34, InterruptDisable|Unused, 0xFD, 0x0400))
+			for i, format := range []TraceFormat{TraceNestest, TraceMesen, TraceVICE} {
+				if got := c.TraceLine(format); got != tt.want[i] {
+					t.Errorf("%s:\n got %q\nwant %q", format, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package goemu6502
+
+// --- Undocumented NMOS instructions ---
+// These opcodes fall out of the NMOS 6502's decode logic, usually running two
+// documented instructions at once. Plenty of real software relies on them.
+// ANE, LXA, SHA, SHX, SHY and TAS are unstable on real hardware; their results
+// here follow the most commonly observed behaviour.
+
+// alr ands with accumulator, then shifts the accumulator right
+func (c *CPU) alr() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// And the fetched byte with the accumulator
+	c.i.temp = uint16(c.r.a & c.i.fetched)
+
+	// Bit 0 is shifted into the carry flag
+	c.setFlag(Carry, c.i.temp&0x01 != 0)
+
+	// Shift the result right by 1 bit
+	c.r.a = uint8(c.i.temp >> 1)
+
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.a == 0)
+	c.setFlag(Negative, c.r.a&0x80 != 0)
+
+	return 0
+}
+
+// anc ands with accumulator, copying the negative flag into carry
+func (c *CPU) anc() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// Perform the bitwise and operation
+	c.r.a = c.r.a & c.i.fetched
+
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.a == 0)
+	c.setFlag(Negative, c.r.a&0x80 != 0)
+
+	// Bit 7 also goes into the carry flag
+	c.setFlag(Carry, c.r.a&0x80 != 0)
+
+	return 0
+}
+
+// ane ands X and memory into the accumulator (unstable)
+func (c *CPU) ane() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// The accumulator is ORed with the magic constant before the and
+	c.r.a = (c.r.a | c.magic) & c.r.x & c.i.fetched
+
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.a == 0)
+	c.setFlag(Negative, c.r.a&0x80 != 0)
+
+	return 0
+}
+
+// arr ands with accumulator, then rotates the accumulator right
+func (c *CPU) arr() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// And the fetched byte with the accumulator
+	var and uint8 = c.r.a & c.i.fetched
+
+	// Rotate the result right, shifting the carry flag into bit 7
+	var result uint8 = and >> 1
+	if c.getFlag(Carry) {
+		result |= 0x80
+	}
+
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, result == 0)
+	c.setFlag(Negative, result&0x80 != 0)
+
+	// Overflow is bit 6 xor bit 5 of the result
+	c.setFlag(Overflow, (and^result)&0x40 != 0)
+
+	// Binary mode: carry is bit 6 of the result
+	if !c.getFlag(Decimal) || !c.variant.decimalMode() {
+		c.setFlag(Carry, result&0x40 != 0)
+		c.r.a = result
+		return 0
+	}
+
+	// Decimal mode: fix up each nibble of the result like ADC would
+	if (and&0x0F)+(and&0x01) > 0x05 {
+		result = result&0xF0 | (result+0x06)&0x0F
+	}
+
+	c.setFlag(Carry, uint16(and&0xF0)+uint16(and&0x10) > 0x50)
+	if c.getFlag(Carry) {
+		result += 0x60
+	}
+
+	// Store the result in the accumulator
+	c.r.a = result
+
+	return 0
+}
+
+// dcp decrements memory, then compares it with the accumulator
+func (c *CPU) dcp() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// Decrement the fetched byte and store it in memory
+	c.i.fetched--
+	c.bus.Write(c.i.addr_absolute, c.i.fetched)
+
+	// Compare the accumulator with the result
+	c.i.temp = uint16(c.r.a) - uint16(c.i.fetched)
+
+	// Set the carry flag if the accumulator is greater than or equal to the result
+	c.setFlag(Carry, c.r.a >= c.i.fetched)
+
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.i.temp&0x00FF == 0)
+	c.setFlag(Negative, c.i.temp&0x80 != 0)
+
+	return 0
+}
+
+// isc increments memory, then subtracts it from the accumulator
+func (c *CPU) isc() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// Increment the fetched byte and store it in memory
+	c.i.fetched++
+	c.bus.Write(c.i.addr_absolute, c.i.fetched)
+
+	// Subtract the result from the accumulator
+	c.subtractWithBorrow(c.i.fetched)
+
+	return 0
+}
+
+// las ands memory with the stack pointer into A, X and the stack pointer
+func (c *CPU) las() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// And the fetched byte with the stack pointer
+	c.r.sp = c.r.sp & c.i.fetched
+	c.r.a = c.r.sp
+	c.r.x = c.r.sp
+
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.a == 0)
+	c.setFlag(Negative, c.r.a&0x80 != 0)
+
+	return 1
+}
+
+// lax loads accumulator and X register
+func (c *CPU) lax() uint8 {
+	// Fetch the next byte and store it in the accumulator and X register
+	c.r.a = c.fetchByte()
+	c.r.x = c.r.a
+
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.a == 0)
+	c.setFlag(Negative, c.r.a&0x80 != 0)
+
+	return 1
+}
+
+// lxa loads accumulator and X register with immediate (unstable)
+func (c *CPU) lxa() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// The accumulator is ORed with the magic constant before the and
+	c.r.a = (c.r.a | c.magic) & c.i.fetched
+	c.r.x = c.r.a
+
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.a == 0)
+	c.setFlag(Negative, c.r.a&0x80 != 0)
+
+	return 0
+}
+
+// rla rotates memory left, then ands it with the accumulator
+func (c *CPU) rla() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// Shift the fetched byte left by 1 bit, shifting the carry flag into bit 0
+	c.i.temp = uint16(c.i.fetched) << 1
+	if c.getFlag(Carry) {
+		c.i.temp |= 0x01
+	}
+
+	// Set the carry flag if the 9th bit is set
+	c.setFlag(Carry, c.i.temp&0xFF00 != 0)
+
+	// Store the result in memory
+	c.bus.Write(c.i.addr_absolute, uint8(c.i.temp&0x00FF))
+
+	// And the result with the accumulator
+	c.r.a = c.r.a & uint8(c.i.temp&0x00FF)
+
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.a == 0)
+	c.setFlag(Negative, c.r.a&0x80 != 0)
+
+	return 0
+}
+
+// rra rotates memory right, then adds it to the accumulator with carry
+func (c *CPU) rra() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// Shift the fetched byte right by 1 bit, shifting the carry flag into bit 7
+	c.i.temp = uint16(c.i.fetched) >> 1
+	if c.getFlag(Carry) {
+		c.i.temp |= 0x80
+	}
+
+	// Bit 0 goes into the carry flag, which the add then uses
+	c.setFlag(Carry, c.i.fetched&0x01 != 0)
+
+	// Store the result in memory
+	c.bus.Write(c.i.addr_absolute, uint8(c.i.temp&0x00FF))
+
+	// Add the result to the accumulator
+	c.addWithCarry(uint8(c.i.temp & 0x00FF))
+
+	return 0
+}
+
+// sax stores accumulator and X register
+func (c *CPU) sax() uint8 {
+	// Store the accumulator anded with the X register at the absolute address
+	c.bus.Write(c.i.addr_absolute, c.r.a&c.r.x)
+
+	return 0
+}
+
+// sbx subtracts from accumulator and X register into X register
+func (c *CPU) sbx() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// Subtract the fetched byte from the accumulator anded with X, without borrow
+	var and uint8 = c.r.a & c.r.x
+	c.i.temp = uint16(and) - uint16(c.i.fetched)
+
+	// Set the carry flag if no borrow was needed
+	c.setFlag(Carry, and >= c.i.fetched)
+
+	// Store the result in the X register
+	c.r.x = uint8(c.i.temp & 0x00FF)
+
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.x == 0)
+	c.setFlag(Negative, c.r.x&0x80 != 0)
+
+	return 0
+}
+
+// sha stores accumulator and X register and high byte (unstable)
+func (c *CPU) sha() uint8 {
+	c.storeUnstable(c.r.a&c.r.x, c.r.y)
+
+	return 0
+}
+
+// shx stores X register and high byte (unstable)
+func (c *CPU) shx() uint8 {
+	c.storeUnstable(c.r.x, c.r.y)
+
+	return 0
+}
+
+// shy stores Y register and high byte (unstable)
+func (c *CPU) shy() uint8 {
+	c.storeUnstable(c.r.y, c.r.x)
+
+	return 0
+}
+
+// slo shifts memory left, then ors it with the accumulator
+func (c *CPU) slo() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// Shift the fetched byte left by 1 bit
+	c.i.temp = uint16(c.i.fetched) << 1
+
+	// Set the carry flag if the 9th bit is set
+	c.setFlag(Carry, c.i.temp&0xFF00 != 0)
+
+	// Store the result in memory
+	c.bus.Write(c.i.addr_absolute, uint8(c.i.temp&0x00FF))
+
+	// Or the result with the accumulator
+	c.r.a = c.r.a | uint8(c.i.temp&0x00FF)
+
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.a == 0)
+	c.setFlag(Negative, c.r.a&0x80 != 0)
+
+	return 0
+}
+
+// sre shifts memory right, then exclusive ors it with the accumulator
+func (c *CPU) sre() uint8 {
+	// Fetch the next byte
+	c.fetchByte()
+
+	// Shift the fetched byte right by 1 bit
+	c.i.temp = uint16(c.i.fetched) >> 1
+
+	// Bit 0 goes into the carry flag
+	c.setFlag(Carry, c.i.fetched&0x01 != 0)
+
+	// Store the result in memory
+	c.bus.Write(c.i.addr_absolute, uint8(c.i.temp&0x00FF))
+
+	// Exclusive or the result with the accumulator
+	c.r.a = c.r.a ^ uint8(c.i.temp&0x00FF)
+
+	// Set the zero and negative flags as appropriate
+	c.setFlag(Zero, c.r.a == 0)
+	c.setFlag(Negative, c.r.a&0x80 != 0)
+
+	return 0
+}
+
+// tas transfers accumulator and X register to stack pointer, then stores it
+// and high byte (unstable)
+func (c *CPU) tas() uint8 {
+	// Load the accumulator anded with the X register into the stack pointer
+	c.r.sp = c.r.a & c.r.x
+
+	c.storeUnstable(c.r.sp, c.r.y)
+
+	return 0
+}
+
+// storeUnstable implements the store of SHA, SHX, SHY and TAS: value is anded
+// with the high byte of the unindexed address plus one. If indexing crossed a
+// page boundary, the stored value also replaces the high byte of the address.
+func (c *CPU) storeUnstable(value uint8, index uint8) {
+	// Work out the address before the index was added
+	var base uint16 = c.i.addr_absolute - uint16(index)
+
+	// And the value with the high byte plus one
+	value &= uint8(base>>8) + 1
+
+	// On a page crossing, the value ends up on the address bus too
+	if base&0xFF00 != c.i.addr_absolute&0xFF00 {
+		c.i.addr_absolute = uint16(value)<<8 | c.i.addr_absolute&0x00FF
+	}
+
+	// Store the value at the absolute address
+	c.bus.Write(c.i.addr_absolute, value)
+}
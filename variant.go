@@ -0,0 +1,107 @@
+package goemu6502
+
+import "strings"
+
+// Variant selects which member of the 6502 family the CPU emulates
+type Variant uint8
+
+const (
+	// NMOS6502 is the original MOS 6502, undocumented opcodes and all. This is
+	// the default.
+	NMOS6502 Variant = iota
+
+	// CMOS65C02 is the basic 65C02 as made by GTE, NCR and others: new
+	// instructions and addressing modes, bug fixes, and NOPs in place of the
+	// undocumented opcodes
+	CMOS65C02
+
+	// Rockwell65C02 adds the RMB, SMB, BBR and BBS bit instructions
+	Rockwell65C02
+
+	// WDC65C02 adds WAI and STP on top of the Rockwell instructions, as found
+	// in the W65C02S
+	WDC65C02
+
+	// Ricoh2A03 is the NES CPU: an NMOS 6502, undocumented opcodes and all,
+	// whose ADC and SBC ignore the decimal flag. SED and CLD still set and
+	// clear it.
+	Ricoh2A03
+
+	// MOS6510 is the C64 CPU: an NMOS 6502 with a 6-bit I/O port at $0000
+	// and $0001, see SetPortInputs and OnPortChange
+	MOS6510
+)
+
+// VariantNames is a map of variant names
+var VariantNames = map[Variant]string{
+	NMOS6502:      "6502",
+	CMOS65C02:     "65C02",
+	Rockwell65C02: "R65C02",
+	WDC65C02:      "W65C02S",
+	Ricoh2A03:     "2A03",
+	MOS6510:       "6510",
+}
+
+// ParseVariant looks up a variant by its name in VariantNames, ignoring case
+func ParseVariant(name string) (Variant, bool) {
+	for variant, variantName := range VariantNames {
+		if strings.EqualFold(name, variantName) {
+			return variant, true
+		}
+	}
+	return 0, false
+}
+
+func (v Variant) String() string {
+	if name, ok := VariantNames[v]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// WithVariant sets which member of the 6502 family the CPU emulates
+func WithVariant(variant Variant) Option {
+	return func(c *CPU) {
+		c.variant = variant
+	}
+}
+
+// Variant returns the member of the 6502 family the CPU emulates
+func (c *CPU) Variant() Variant {
+	return c.variant
+}
+
+// Decode returns what opcode is on the CPU's variant
+func (c *CPU) Decode(opcode uint8) InstructionInfo {
+	return c.instructions[opcode]
+}
+
+// instructions returns the instruction table for the variant
+func (v Variant) instructions() *[256]InstructionInfo {
+	switch v {
+	case CMOS65C02:
+		return &Instructions65C02
+	case Rockwell65C02:
+		return &InstructionsRockwell65C02
+	case WDC65C02:
+		return &InstructionsWDC65C02
+	default:
+		return &Instructions
+	}
+}
+
+// cmos reports whether the variant is one of the 65C02s
+func (v Variant) cmos() bool {
+	switch v {
+	case CMOS65C02, Rockwell65C02, WDC65C02:
+		return true
+	default:
+		return false
+	}
+}
+
+// decimalMode reports whether the variant does BCD arithmetic when the decimal
+// flag is set
+func (v Variant) decimalMode() bool {
+	return v != Ricoh2A03
+}
@@ -0,0 +1,17 @@
+package goemu6502
+
+import "testing"
+
+func TestParseVariant(t *testing.T) {
+	for variant, name := range VariantNames {
+		if got, ok := ParseVariant(name); !ok || got != variant {
+			t.Errorf("ParseVariant(%q) = %s, %t", name, got, ok)
+		}
+	}
+	if got, ok := ParseVariant("w65c02s"); !ok || got != WDC65C02 {
+		t.Errorf("ParseVariant is case sensitive, got %s, %t", got, ok)
+	}
+	if _, ok := ParseVariant("Z80"); ok {
+		t.Error("parsed Z80")
+	}
+}